@@ -0,0 +1,182 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	usersOpt = "users"
+	rolesOpt = "roles"
+)
+
+// checkCmd verifies whether groups or users have access to an object
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "check effective access for groups or users",
+	Long: `Check whether given groups or users have the requested access to an object.
+The check uses the same Sentry API as Hive binding, so it takes into account all
+roles granted to groups and users.
+
+Prints 'yes' followed by privileges that grant the access and exits with zero
+status, or prints 'no' and exits with status 8. Errors use the same exit codes as
+other commands.`,
+	Example: `
+  $ sentrytool check -g analysts -s server1 -d finance -t ledger -a select
+  yes
+  server=server1->db=finance->action=all
+  $ sentrytool check --users etl -s server1 -d finance -a insert
+  no
+  $ sentrytool -C solr --service solr1 check -g analysts --collection logs -a query
+  yes
+  collection=logs->action=query`,
+	RunE: checkAccess,
+}
+
+func checkAccess(cmd *cobra.Command, args []string) error {
+	granted, privileges, err := getEffectiveAccess(cmd)
+	if err != nil {
+		return err
+	}
+	if !granted {
+		fmt.Println("no")
+		exitCode = exitNotGranted
+		return nil
+	}
+	fmt.Println("yes")
+	for _, priv := range privileges {
+		fmt.Println(priv)
+	}
+	return nil
+}
+
+// getEffectiveAccess returns true if access is granted along with the list of
+// privileges granting access
+func getEffectiveAccess(cmd *cobra.Command) (bool, []string, error) {
+	groups, _ := cmd.Flags().GetStringSlice(groupOpt)
+	users, _ := cmd.Flags().GetStringSlice(usersOpt)
+	roles, _ := cmd.Flags().GetStringSlice(rolesOpt)
+	action, _ := cmd.Flags().GetString("action")
+	server, _ := cmd.Flags().GetString("server")
+	database, _ := cmd.Flags().GetString("database")
+	table, _ := cmd.Flags().GetString("table")
+	column, _ := cmd.Flags().GetString("column")
+	uri, _ := cmd.Flags().GetString("uri")
+	service, err := serviceName(cmd)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if len(groups) == 0 && len(users) == 0 {
+		return false, nil, errors.New("missing groups or users")
+	}
+
+	object := &sentryapi.Privilege{
+		Server:   server,
+		Database: database,
+		Table:    table,
+		Column:   column,
+		URI:      uri,
+		Service:  service,
+		Action:   action,
+	}
+	if err := setComponentAuthorizables(cmd, object); err != nil {
+		return false, nil, err
+	}
+	if isGeneric() {
+		if object.Service == "" {
+			return false, nil, fmt.Errorf("--%s is required for the generic model",
+				serviceOpt)
+		}
+		if len(object.Authorizables) == 0 {
+			return false, nil, errors.New("missing object")
+		}
+		if schema, ok := sentryapi.GetComponentSchema(
+			viper.GetString(componentOpt)); ok {
+			schema.FillDefaults(object)
+		}
+	}
+
+	// Usage doesn't help with Sentry failures
+	cmd.SilenceUsage = true
+	client, err := getClient()
+	if err != nil {
+		return false, nil, err
+	}
+	defer client.Close()
+
+	privileges, err := client.EffectivePrivileges(groups, users, roles, object)
+	if err != nil {
+		return false, nil, err
+	}
+
+	granting := []string{}
+	for _, priv := range privileges {
+		if providerPrivilegeCovers(priv, object) {
+			granting = append(granting, priv)
+		}
+	}
+	return len(granting) != 0, granting, nil
+}
+
+// providerPrivilegeCovers returns true if privilege in provider format
+// (e.g. server=server1->db=db1->action=select) covers the requested object and
+// action. A privilege covers an object if it is granted on the object itself or
+// any of its parents.
+func providerPrivilegeCovers(priv string, object *sentryapi.Privilege) bool {
+	var granted *sentryapi.Privilege
+	var err error
+	if object.IsGeneric() {
+		granted, err = sentryapi.ParseGenericPrivilege(priv)
+	} else {
+		granted, err = sentryapi.ParsePrivilege(priv)
+	}
+	if err != nil {
+		return false
+	}
+	requested := *object
+	if object.IsGeneric() {
+		// Provider privileges don't have service
+		granted.Service = object.Service
+	} else if requested.Server == "" {
+		// Server name is optional in the request
+		requested.Server = granted.Server
+	}
+	// Without requested action any action gives access
+	if requested.Action == "" {
+		requested.Action = granted.Action
+	}
+	return sentryapi.Implies(granted, &requested)
+}
+
+func init() {
+	checkCmd.Flags().StringSliceP(groupOpt, "g", nil, "groups to check")
+	checkCmd.Flags().StringSliceP(usersOpt, "", nil, "users to check")
+	checkCmd.Flags().StringSliceP(rolesOpt, "", nil, "active roles (all roles by default)")
+	checkCmd.Flags().StringP("action", "a", "", "requested action")
+	checkCmd.Flags().StringP("server", "s", "", "server name")
+	checkCmd.Flags().StringP("database", "d", "", "database name")
+	checkCmd.Flags().StringP("table", "t", "", "table name")
+	checkCmd.Flags().StringP("column", "c", "", "column name")
+	checkCmd.Flags().StringP("uri", "u", "", "URI")
+	addComponentFlags(checkCmd, checkCmd.Flags())
+	RootCmd.AddCommand(checkCmd)
+}
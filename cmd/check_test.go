@@ -0,0 +1,108 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/spf13/viper"
+)
+
+func TestProviderPrivilegeCovers(t *testing.T) {
+	tests := []struct {
+		priv   string
+		object sentryapi.Privilege
+		covers bool
+	}{
+		{"server=server1->db=sales->action=select",
+			sentryapi.Privilege{Database: "sales", Table: "t"}, true},
+		{"server=server1->db=sales->action=select",
+			sentryapi.Privilege{Database: "sales", Action: "select"}, true},
+		{"server=server1->db=sales->action=select",
+			sentryapi.Privilege{Database: "sales", Action: "insert"}, false},
+		{"server=server1->db=sales->table=t->action=all",
+			sentryapi.Privilege{Database: "sales"}, false},
+		{"server=server1->db=sales",
+			sentryapi.Privilege{Server: "server2", Database: "sales"}, false},
+		{"collection=logs->action=query",
+			sentryapi.Privilege{Service: "solr1", Authorizables: []sentryapi.Authorizable{
+				{Type: "collection", Name: "logs"}}}, true},
+		{"collection=logs->action=query",
+			sentryapi.Privilege{Service: "solr1", Authorizables: []sentryapi.Authorizable{
+				{Type: "collection", Name: "logs"}}, Action: "update"}, false},
+		{"collection=logs->action=query",
+			sentryapi.Privilege{Service: "solr1", Authorizables: []sentryapi.Authorizable{
+				{Type: "collection", Name: "audit"}}}, false},
+		{"collection=*->action=query",
+			sentryapi.Privilege{Service: "solr1", Authorizables: []sentryapi.Authorizable{
+				{Type: "collection", Name: "audit"}}}, true},
+		{"collection=logs->field=id->action=query",
+			sentryapi.Privilege{Service: "solr1", Authorizables: []sentryapi.Authorizable{
+				{Type: "collection", Name: "logs"}}}, false},
+	}
+	for _, tt := range tests {
+		if covers := providerPrivilegeCovers(tt.priv, &tt.object); covers != tt.covers {
+			t.Errorf("%s covers %+v: expected %v, got %v", tt.priv, tt.object,
+				tt.covers, covers)
+		}
+	}
+}
+
+func TestGetEffectiveAccess_Generic(t *testing.T) {
+	setTestServer(t)
+	defer viper.Set(componentOpt, "")
+	defer viper.Set(serviceOpt, "")
+	viper.Set(componentOpt, "solr")
+	viper.Set(serviceOpt, "solr1")
+	roleName := "sentrytool_test_check_role"
+	group := "sentrytool_test_check_group"
+	client, err := getClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.CreateRole(roleName); err != nil {
+		t.Fatal(err)
+	}
+	defer client.RemoveRole(roleName)
+	if err := client.AddGroupsToRole(roleName, []string{group}); err != nil {
+		t.Fatal(err)
+	}
+	priv, _ := sentryapi.ParseGenericPrivilege(
+		"service=solr1->collection=logs->action=query")
+	if err := client.GrantPrivilege(roleName, priv); err != nil {
+		t.Fatal(err)
+	}
+
+	setFlag(t, checkCmd, groupOpt, group)
+	setFlag(t, checkCmd, "action", "query")
+	for _, tt := range []struct {
+		collection string
+		granted    bool
+	}{
+		{"logs", true},
+		{"audit", false},
+	} {
+		setFlag(t, checkCmd, "collection", tt.collection)
+		granted, _, err := getEffectiveAccess(checkCmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if granted != tt.granted {
+			t.Errorf("%s: expected %v, got %v", tt.collection, tt.granted, granted)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+)
+
+// Exit codes. When several operations fail, the code of the last failure
+// is used.
+const (
+	exitFailure         = 1 // Generic failure, including Sentry runtime errors
+	exitTransport       = 2 // Failure communicating with Sentry
+	exitAlreadyExists   = 3 // Object already exists
+	exitNoSuchObject    = 4 // Object doesn't exist
+	exitInvalidInput    = 5 // Request rejected as invalid
+	exitAccessDenied    = 6 // Requesting user isn't allowed to do the operation
+	exitVersionMismatch = 7 // Client and server protocol versions don't match
+	exitNotGranted      = 8 // Access isn't granted (check command)
+)
+
+// exitCode is the process exit code set by printError()
+var exitCode int
+
+// printError prints the error and records exit code for it
+func printError(err error) {
+	fmt.Println(toAPIError(err))
+	exitCode = errorExitCode(err)
+}
+
+// errorExitCode returns exit code for the error
+func errorExitCode(err error) int {
+	switch {
+	case sentryapi.IsTransportError(err):
+		return exitTransport
+	case errors.Is(err, sentryapi.ErrAlreadyExists):
+		return exitAlreadyExists
+	case errors.Is(err, sentryapi.ErrNoSuchObject):
+		return exitNoSuchObject
+	case errors.Is(err, sentryapi.ErrInvalidInput):
+		return exitInvalidInput
+	case errors.Is(err, sentryapi.ErrAccessDenied):
+		return exitAccessDenied
+	case errors.Is(err, sentryapi.ErrThriftVersionMismatch):
+		return exitVersionMismatch
+	default:
+		return exitFailure
+	}
+}
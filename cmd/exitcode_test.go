@@ -0,0 +1,50 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"net"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestExitCode_ConnectionRefused(t *testing.T) {
+	// Find a port nobody listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	viper.Set(hostOpt, "127.0.0.1")
+	viper.Set(portOpt, port)
+
+	exitCode = 0
+	RootCmd.SetArgs([]string{"role", "create", "r1"})
+	if err := RootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != exitTransport {
+		t.Errorf("role create: expected exit code %d, got %d", exitTransport, exitCode)
+	}
+
+	// Commands returning errors are mapped by Execute()
+	RootCmd.SetArgs([]string{"check", "-g", "g1", "-d", "db1", "-a", "select"})
+	err = RootCmd.Execute()
+	if code := errorExitCode(err); code != exitTransport {
+		t.Errorf("check: expected exit code %d, got %d for %v", exitTransport, code, err)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/spf13/cobra"
+)
+
+const (
+	formatOpt = "format"
+	objectOpt = "object"
+
+	jsonFormat = "json"
+	yamlFormat = "yaml"
+)
+
+// exportCmd exports the whole Sentry policy
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export Sentry policy",
+	Long: `Export the complete Sentry policy (group to roles, user to roles and
+role to privileges mappings) to stdout in JSON or YAML format.
+
+If --object flag is specified, only export privileges for the given object which
+is specified as 'db' or 'db.table'.
+
+Export is only supported by the legacy (Hive) model.`,
+	Example: `
+  sentrytool export > policy.json
+  sentrytool export --format yaml --object sales.orders > orders.yaml`,
+	RunE: exportPolicy,
+}
+
+func exportPolicy(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString(formatOpt)
+	if format != jsonFormat && format != yamlFormat {
+		return fmt.Errorf("invalid format %s", format)
+	}
+	object, _ := cmd.Flags().GetString(objectOpt)
+
+	client, err := getLegacyClient()
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	defer client.Close()
+	policyIO, ok := client.(sentryapi.PolicyIO)
+	if !ok {
+		printError(fmt.Errorf("%w: export policy", sentryapi.ErrNotSupported))
+		return nil
+	}
+
+	policy, err := policyIO.ExportPolicy(toObjectPath(object))
+	if err != nil {
+		printError(err)
+		return nil
+	}
+
+	var data []byte
+	if format == yamlFormat {
+		data, err = policy.ToYAML()
+	} else {
+		data, err = policy.ToJSON()
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(strings.TrimRight(string(data), "\n"))
+	return nil
+}
+
+// toObjectPath converts object specified as db.table to the Sentry object path
+// format db=name->table=name. Objects already using Sentry format are returned
+// as is.
+func toObjectPath(object string) string {
+	if object == "" || strings.Contains(object, valSeparator) {
+		return object
+	}
+	parts := strings.SplitN(object, ".", 2)
+	path := dbKey + valSeparator + parts[0]
+	if len(parts) == 2 {
+		path += sentrySeparator + tableKey + valSeparator + parts[1]
+	}
+	return path
+}
+
+func init() {
+	exportCmd.Flags().StringP(formatOpt, "f", jsonFormat, "output format (json or yaml)")
+	exportCmd.Flags().StringP(objectOpt, "o", "", "only export privileges for db or db.table")
+	RootCmd.AddCommand(exportCmd)
+}
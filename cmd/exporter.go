@@ -0,0 +1,228 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+)
+
+const (
+	listenOpt   = "listen"
+	intervalOpt = "interval"
+
+	metricsNamespace = "sentry"
+)
+
+// exporterCmd serves Sentry policy and RPC metrics to Prometheus
+var exporterCmd = &cobra.Command{
+	Use:   "exporter",
+	Short: "export Sentry metrics to Prometheus",
+	Long: `Serve Prometheus metrics for the Sentry policy and RPC health on /metrics.
+
+The policy is walked every --interval: all roles, their groups and privileges are
+listed. The following gauges are exported:
+
+* sentry_up:                           1 if the last policy walk succeeded
+* sentry_roles:                        number of roles
+* sentry_groups:                       number of groups granted any role
+* sentry_roles_without_groups:         number of roles not granted to any group
+* sentry_role_privileges{role}:        number of privileges of each role
+* sentry_grant_option_privileges:      number of privileges with grant option
+* sentry_policy_walk_duration_seconds: duration of the last policy walk
+
+Every Sentry call is observed by the sentry_rpc_duration_seconds histogram and the
+sentry_rpc_errors_total counter, labeled with the operation and the Sentry host.
+
+When a component is specified, the service name should be specified with --service flag.`,
+	Example: `
+  sentrytool -H sentry1,sentry2 exporter --listen :9538 --interval 5m
+  sentrytool -C solr exporter --service service1`,
+	RunE: runExporter,
+}
+
+// policyExporter keeps metrics exported to Prometheus
+type policyExporter struct {
+	registry          *prometheus.Registry
+	up                prometheus.Gauge
+	roles             prometheus.Gauge
+	groups            prometheus.Gauge
+	rolesNoGroups     prometheus.Gauge
+	rolePrivileges    *prometheus.GaugeVec
+	grantOptionPrivs  prometheus.Gauge
+	walkDuration      prometheus.Gauge
+	rpcDuration       *prometheus.HistogramVec
+	rpcErrors         *prometheus.CounterVec
+	privilegeTemplate *sentryapi.Privilege
+}
+
+// newPolicyExporter creates and registers all metrics.
+//   template - template for listing role privileges, required for the
+//              generic model
+func newPolicyExporter(template *sentryapi.Privilege) *policyExporter {
+	gauge := func(name string, help string) prometheus.Gauge {
+		return prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace, Name: name, Help: help})
+	}
+	e := &policyExporter{
+		registry:      prometheus.NewRegistry(),
+		up:            gauge("up", "1 if the last policy walk succeeded"),
+		roles:         gauge("roles", "Number of roles"),
+		groups:        gauge("groups", "Number of groups granted any role"),
+		rolesNoGroups: gauge("roles_without_groups", "Number of roles not granted to any group"),
+		rolePrivileges: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "role_privileges",
+			Help:      "Number of privileges of the role",
+		}, []string{"role"}),
+		grantOptionPrivs: gauge("grant_option_privileges",
+			"Number of privileges with grant option"),
+		walkDuration: gauge("policy_walk_duration_seconds",
+			"Duration of the last policy walk"),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "rpc_duration_seconds",
+			Help:      "Duration of Sentry calls",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+		}, []string{"operation", "host"}),
+		rpcErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "rpc_errors_total",
+			Help:      "Number of failed Sentry calls",
+		}, []string{"operation", "host"}),
+		privilegeTemplate: template,
+	}
+	e.registry.MustRegister(e.up, e.roles, e.groups, e.rolesNoGroups,
+		e.rolePrivileges, e.grantOptionPrivs, e.walkDuration, e.rpcDuration,
+		e.rpcErrors)
+	return e
+}
+
+// observeCall is the interceptor recording RPC metrics
+func (e *policyExporter) observeCall(call *sentryapi.Call,
+	next sentryapi.Invoker) error {
+	start := time.Now()
+	err := next(call)
+	e.rpcDuration.WithLabelValues(call.Operation, call.Host).Observe(
+		time.Since(start).Seconds())
+	if err != nil {
+		e.rpcErrors.WithLabelValues(call.Operation, call.Host).Inc()
+	}
+	return err
+}
+
+// walk lists the whole policy and updates policy gauges. Gauges keep their
+// previous values if the walk fails.
+func (e *policyExporter) walk(client sentryapi.ClientAPI) error {
+	start := time.Now()
+	err := e.collect(client)
+	e.walkDuration.Set(time.Since(start).Seconds())
+	if err != nil {
+		e.up.Set(0)
+		return err
+	}
+	e.up.Set(1)
+	return nil
+}
+
+// collect lists roles and privileges and sets policy gauges
+func (e *policyExporter) collect(client sentryapi.ClientAPI) error {
+	_, roles, err := client.ListRoleByGroup("")
+	if err != nil {
+		return err
+	}
+	groups := make(map[string]bool)
+	noGroups := 0
+	privCount := make(map[string]int, len(roles))
+	grantOption := 0
+	for _, role := range roles {
+		if len(role.Groups) == 0 {
+			noGroups++
+		}
+		for _, group := range role.Groups {
+			groups[group] = true
+		}
+		privs, err := client.ListPrivilegesByRole(role.Name, e.privilegeTemplate)
+		if err != nil {
+			return err
+		}
+		privCount[role.Name] = len(privs)
+		for _, priv := range privs {
+			if priv.GrantOption {
+				grantOption++
+			}
+		}
+	}
+
+	e.roles.Set(float64(len(roles)))
+	e.groups.Set(float64(len(groups)))
+	e.rolesNoGroups.Set(float64(noGroups))
+	e.grantOptionPrivs.Set(float64(grantOption))
+	// Forget roles which were removed since the last walk
+	e.rolePrivileges.Reset()
+	for role, count := range privCount {
+		e.rolePrivileges.WithLabelValues(role).Set(float64(count))
+	}
+	return nil
+}
+
+func runExporter(cmd *cobra.Command, args []string) error {
+	listen, _ := cmd.Flags().GetString(listenOpt)
+	interval, _ := cmd.Flags().GetDuration(intervalOpt)
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval %v", interval)
+	}
+	template, err := listTemplate(cmd)
+	if err != nil {
+		return err
+	}
+
+	exporter := newPolicyExporter(template)
+	client, err := getClient(sentryapi.WithInterceptors(exporter.observeCall))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	go func() {
+		for {
+			if err := exporter.walk(client); err != nil {
+				logger.Println("policy walk failed:", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(exporter.registry,
+		promhttp.HandlerOpts{}))
+	logger.Println("serving metrics on", listen)
+	return http.ListenAndServe(listen, mux)
+}
+
+func init() {
+	exporterCmd.Flags().StringP(listenOpt, "", ":9538", "address to serve metrics on")
+	exporterCmd.Flags().DurationP(intervalOpt, "", time.Minute, "policy walk interval")
+	RootCmd.AddCommand(exporterCmd)
+}
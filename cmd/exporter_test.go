@@ -0,0 +1,108 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/akolb1/sentrytool/sentryapi/sentrytest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPolicyExporter(t *testing.T) {
+	server, err := sentrytest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter := newPolicyExporter(nil)
+	client, err := sentryapi.GetClient(sentryapi.PolicyProtocol, server.Host,
+		server.Port, "", "admin", sentryapi.WithInterceptors(exporter.observeCall))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for _, role := range []string{"r1", "r2"} {
+		if err := client.CreateRole(role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.AddGroupsToRole("r1", []string{"g1", "g2"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, priv := range []*sentryapi.Privilege{
+		{Server: "server1", Database: "db1", Action: "select", GrantOption: true},
+		{Server: "server1", Database: "db2", Action: "insert"},
+	} {
+		if err := client.GrantPrivilege("r1", priv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Duplicate role is counted as an RPC error
+	client.CreateRole("r1")
+
+	if err := exporter.walk(client); err != nil {
+		t.Fatal(err)
+	}
+	for _, metric := range []struct {
+		name     string
+		value    float64
+		expected float64
+	}{
+		{"up", testutil.ToFloat64(exporter.up), 1},
+		{"roles", testutil.ToFloat64(exporter.roles), 2},
+		{"groups", testutil.ToFloat64(exporter.groups), 2},
+		{"roles without groups", testutil.ToFloat64(exporter.rolesNoGroups), 1},
+		{"grant option", testutil.ToFloat64(exporter.grantOptionPrivs), 1},
+		{"r1 privileges", testutil.ToFloat64(
+			exporter.rolePrivileges.WithLabelValues("r1")), 2},
+		{"r2 privileges", testutil.ToFloat64(
+			exporter.rolePrivileges.WithLabelValues("r2")), 0},
+	} {
+		if metric.value != metric.expected {
+			t.Errorf("%s: expected %v, got %v", metric.name, metric.expected,
+				metric.value)
+		}
+	}
+	host := fmt.Sprintf("%s:%d", server.Host, server.Port)
+	if errors := testutil.ToFloat64(exporter.rpcErrors.WithLabelValues(
+		"CreateRole", host)); errors != 1 {
+		t.Errorf("expected 1 CreateRole error, got %v", errors)
+	}
+	if count := testutil.CollectAndCount(exporter.rpcDuration); count != 5 {
+		t.Errorf("expected histograms for 5 operations, got %d", count)
+	}
+
+	// Removed roles are no longer reported
+	if err := client.RemoveRole("r2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.walk(client); err != nil {
+		t.Fatal(err)
+	}
+	if count := testutil.CollectAndCount(exporter.rolePrivileges); count != 1 {
+		t.Errorf("expected privileges for 1 role, got %d", count)
+	}
+
+	server.Close()
+	if err := exporter.walk(client); err == nil {
+		t.Error("expected walk to fail without server")
+	}
+	if up := testutil.ToFloat64(exporter.up); up != 0 {
+		t.Errorf("expected up to be 0, got %v", up)
+	}
+}
@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-
-	"strconv"
+	"log"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
@@ -14,49 +17,226 @@ import (
 // from viper.
 //
 // If component is specified, it uses Generic sentry protocol, otherwise it uses legacy
-// protocol
-func getClient() (sentryapi.ClientAPI, error) {
+// protocol. If the server doesn't provide the required service, the error reports
+// which services are available. When several hosts are specified, the client fails
+// over between them. Extra options are added to the ones from viper.
+func getClient(extra ...sentryapi.ClientOption) (sentryapi.ClientAPI, error) {
 	host := viper.GetString(hostOpt)
 	user := viper.GetString(userOpt)
 	component := viper.GetString(componentOpt)
 	port := viper.GetInt(portOpt)
 
-	var errVal error
-	parts := strings.Split(host, ",")
-	for _, host := range parts {
-		if client, err := getClientForHost(host, port,
-			user, component); err == nil {
-			return client, nil
-		} else {
-			errVal = err
+	endpoints, err := sentryapi.ParseEndpoints(host, port)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := getClientOptions(user)
+	if err != nil {
+		return nil, err
+	}
+	// Report which host served each call with explicit -v. Some commands
+	// force verbose output for listing, so viper value can't be used here.
+	opts = append(opts, extra...)
+	if verboseFlag {
+		opts = append(opts, sentryapi.WithCallReporter(reportCall))
+	}
+	if viper.GetBool(debugOpt) {
+		logger := log.New(os.Stderr, "debug: ", log.Ltime|log.Lmicroseconds)
+		opts = append(opts, sentryapi.WithInterceptors(
+			sentryapi.NewTimingInterceptor(func(operation string,
+				elapsed time.Duration, err error) {
+				logger.Printf("operation=%s duration=%v", operation, elapsed)
+			}),
+			sentryapi.NewLoggingInterceptor(logger)))
+	}
+	return sentryapi.NewHAClient(sentryapi.AutoProtocol,
+		endpoints, component, user, opts...)
+}
+
+// getLegacyClient returns legacy model client connected to the first
+// available host. The client isn't wrapped for failover or debug logging, so it
+// provides legacy specific interfaces like sentryapi.PolicyIO.
+func getLegacyClient() (sentryapi.ClientAPI, error) {
+	if isGeneric() {
+		return nil, fmt.Errorf("%w: only supported by the legacy model",
+			sentryapi.ErrNotSupported)
+	}
+	user := viper.GetString(userOpt)
+	endpoints, err := sentryapi.ParseEndpoints(viper.GetString(hostOpt),
+		viper.GetInt(portOpt))
+	if err != nil {
+		return nil, err
+	}
+	opts, err := getClientOptions(user)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range endpoints {
+		var client sentryapi.ClientAPI
+		client, err = sentryapi.GetClient(sentryapi.PolicyProtocol,
+			e.Host, e.Port, "", user, opts...)
+		if err == nil || !sentryapi.IsTransportError(err) {
+			return client, err
+		}
+	}
+	return nil, err
+}
+
+// reportCall shows which host served the call
+func reportCall(operation string, host string, err error) {
+	if host == "" {
+		host = "no host"
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s failed: %v\n", host, operation, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s\n", host, operation)
+}
+
+// getClientOptions returns client options for timeouts and transport
+// security settings
+func getClientOptions(user string) ([]sentryapi.ClientOption, error) {
+	opts := []sentryapi.ClientOption{
+		sentryapi.WithConnectTimeout(viper.GetDuration(connectTimeoutOpt)),
+		sentryapi.WithTimeout(viper.GetDuration(timeoutOpt)),
+		sentryapi.WithProtocolVersion(int32(viper.GetInt(protocolVersionOpt))),
+	}
+	principal := viper.GetString(principalOpt)
+	switch mech := strings.ToLower(viper.GetString(saslMechOpt)); mech {
+	case "":
+	case "gssapi", "kerberos":
+		sasl, err := sentryapi.NewGSSAPIMechanism(principal,
+			viper.GetString(keytabOpt), "")
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sentryapi.WithSASL(sasl))
+	case "plain":
+		if principal == "" {
+			principal = user
+		}
+		opts = append(opts, sentryapi.WithSASL(
+			sentryapi.NewPlainMechanism(principal, viper.GetString(passwordOpt))))
+	default:
+		return nil, fmt.Errorf("invalid SASL mechanism %s", mech)
+	}
+
+	caCert := viper.GetString(caCertOpt)
+	clientCert := viper.GetString(clientCertOpt)
+	clientKey := viper.GetString(clientKeyOpt)
+	if viper.GetBool(tlsOpt) || caCert != "" || clientCert != "" {
+		config, err := sentryapi.NewTLSConfig(caCert, clientCert, clientKey,
+			viper.GetString(tlsServerNameOpt), viper.GetBool(tlsInsecureOpt))
+		if err != nil {
+			return nil, err
 		}
+		opts = append(opts, sentryapi.WithTLS(config))
+	}
+	recordOpts, err := getRecordOptions()
+	if err != nil {
+		return nil, err
 	}
-	return nil, errVal
+	return append(opts, recordOpts...), nil
 }
 
-// getCLientForHost gets a client for a specific host
-func getClientForHost(host string, port int, user string,
-	component string) (sentryapi.ClientAPI, error) {
-	// Allow host/port setup as host:port
-	if strings.Contains(host, ":") {
-		parts := strings.Split(host, ":")
-		if len(parts) == 2 {
-			// host:port is specified
-			host = parts[0]
-			if portVal, err := strconv.Atoi(parts[1]); err == nil {
-				port = portVal
-			} else {
-				return nil, fmt.Errorf("invalid port %s", parts[1])
+var (
+	// recorder and recording are shared by all clients of the command, so
+	// that commands using several clients record and replay all their calls
+	recorder     *sentryapi.Recorder
+	recordOutput *os.File
+	recording    *sentryapi.Recording
+)
+
+// getRecordOptions returns client options for recording Thrift calls to a
+// file or replaying them from a file
+func getRecordOptions() ([]sentryapi.ClientOption, error) {
+	recordFile := viper.GetString(recordOpt)
+	replayFile := viper.GetString(replayOpt)
+	if recordFile != "" && replayFile != "" {
+		return nil, fmt.Errorf("--%s and --%s can't be used together",
+			recordOpt, replayOpt)
+	}
+	if recordFile != "" {
+		if recorder == nil {
+			f, err := os.Create(recordFile)
+			if err != nil {
+				return nil, err
 			}
+			recordOutput = f
+			recorder = sentryapi.NewRecorder(f)
 		}
+		return []sentryapi.ClientOption{sentryapi.WithRecorder(recorder)}, nil
 	}
+	if replayFile != "" {
+		if recording == nil {
+			f, err := os.Open(replayFile)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			if recording, err = sentryapi.LoadRecording(f); err != nil {
+				return nil, err
+			}
+		}
+		return []sentryapi.ClientOption{sentryapi.WithReplay(recording)}, nil
+	}
+	return nil, nil
+}
 
-	if component == "" {
-		return sentryapi.GetClient(sentryapi.PolicyProtocol,
-			host, port, component, user)
+// finishRecording closes the file with recorded Thrift calls. It returns an
+// error if the recording could not be written completely.
+func finishRecording() error {
+	if recorder == nil {
+		return nil
+	}
+	err := recorder.Err()
+	if closeErr := recordOutput.Close(); err == nil {
+		err = closeErr
+	}
+	name := recordOutput.Name()
+	recorder, recordOutput = nil, nil
+	if err != nil {
+		return fmt.Errorf("failed to write recording %s: %w", name, err)
+	}
+	return nil
+}
+
+// isGeneric returns true if the generic Sentry model is used, which is the
+// case when a component is specified
+func isGeneric() bool {
+	return viper.GetString(componentOpt) != ""
+}
+
+// listTemplate returns privilege template for listing role privileges. The
+// generic model requires service name which is set by --service flag,
+// SENTRY_SERVICE environment variable or the config file.
+func listTemplate(cmd *cobra.Command) (*sentryapi.Privilege, error) {
+	service, err := serviceName(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if service != "" {
+		return &sentryapi.Privilege{Service: service}, nil
+	}
+	if isGeneric() {
+		return nil, fmt.Errorf("--%s is required for the generic model", serviceOpt)
+	}
+	return nil, nil
+}
+
+// serviceName returns the generic model service name. Services only exist
+// in the generic model: --service is rejected for the legacy model and the
+// service from the environment or the config file is ignored.
+func serviceName(cmd *cobra.Command) (string, error) {
+	if isGeneric() {
+		return viper.GetString(serviceOpt), nil
+	}
+	if cmd.Flags().Changed(serviceOpt) {
+		return "", fmt.Errorf("--%s requires a generic model component (-C)",
+			serviceOpt)
 	}
-	return sentryapi.GetClient(sentryapi.GenericPolicyProtocol,
-		host, port, component, user)
+	return "", nil
 }
 
 // isValidRole returns true iff role is valid
@@ -81,7 +261,8 @@ func toAPIError(err error) error {
 	if !viper.GetBool(jstackOpt) {
 		return err
 	}
-	if apiErr, ok := err.(*sentryapi.APIError); ok {
+	var apiErr *sentryapi.APIError
+	if errors.As(err, &apiErr) {
 		stack := apiErr.StackTrace
 		if stack == "" {
 			return apiErr.Err
@@ -0,0 +1,130 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/akolb1/sentrytool/sentryapi/sentrytest"
+	"github.com/spf13/viper"
+)
+
+func TestListTemplate(t *testing.T) {
+	defer viper.Set(componentOpt, "")
+	defer viper.Set(serviceOpt, "")
+
+	if template, err := listTemplate(privListCmd); err != nil || template != nil {
+		t.Errorf("legacy model: expected no template, got %v, %v", template, err)
+	}
+	viper.Set(componentOpt, "kafka")
+	if _, err := listTemplate(privListCmd); err == nil {
+		t.Error("generic model without service: expected error")
+	}
+	viper.Set(serviceOpt, "kafka1")
+	template, err := listTemplate(privListCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.Service != "kafka1" {
+		t.Errorf("expected service kafka1, got %q", template.Service)
+	}
+}
+
+func TestServiceName(t *testing.T) {
+	defer viper.Set(componentOpt, "")
+	defer viper.Set(serviceOpt, "")
+
+	viper.Set(serviceOpt, "kafka1")
+	if service, err := serviceName(privListCmd); err != nil || service != "" {
+		t.Errorf("legacy model: expected no service, got %q, %v", service, err)
+	}
+	viper.Set(componentOpt, "kafka")
+	if service, err := serviceName(privListCmd); err != nil || service != "kafka1" {
+		t.Errorf("expected service kafka1, got %q, %v", service, err)
+	}
+
+	viper.Set(componentOpt, "")
+	flag := RootCmd.PersistentFlags().Lookup(serviceOpt)
+	defer func() {
+		flag.Value.Set("")
+		flag.Changed = false
+	}()
+	RootCmd.SetArgs([]string{"privilege", "list", "--service", "kafka1"})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("legacy model: expected error for --service")
+	}
+}
+
+func TestGetLegacyClient(t *testing.T) {
+	setTestServer(t)
+	defer viper.Set(componentOpt, "")
+
+	viper.Set(componentOpt, "kafka")
+	if _, err := getLegacyClient(); !errors.Is(err, sentryapi.ErrNotSupported) {
+		t.Errorf("generic model: expected ErrNotSupported, got %v", err)
+	}
+	viper.Set(componentOpt, "")
+	client, err := getLegacyClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, ok := client.(sentryapi.PolicyIO); !ok {
+		t.Error("legacy client doesn't implement PolicyIO")
+	}
+}
+
+func TestFinishRecording(t *testing.T) {
+	server, err := sentrytest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	viper.Set(hostOpt, server.Host)
+	viper.Set(portOpt, server.Port)
+	defer viper.Set(recordOpt, "")
+
+	record := func(file string, role string) error {
+		viper.Set(recordOpt, file)
+		RootCmd.SetArgs([]string{"role", "create", role})
+		if err := RootCmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		return finishRecording()
+	}
+
+	file := filepath.Join(t.TempDir(), "calls.json")
+	if err := record(file, "r1"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(file); err != nil || info.Size() == 0 {
+		t.Errorf("expected calls recorded in %s: %v", file, err)
+	}
+
+	// Writing to /dev/full fails with ENOSPC
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full")
+	}
+	if err := record("/dev/full", "r2"); err == nil {
+		t.Error("expected error for failed recording")
+	}
+	if recorder != nil || recordOutput != nil {
+		t.Error("expected recorder to be reset")
+	}
+}
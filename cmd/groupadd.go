@@ -60,7 +60,7 @@ func addGroupsToRole(cmd *cobra.Command, args []string) error {
 	// Get Thrift client
 	client, err := getClient()
 	if err != nil {
-		fmt.Println(err)
+		printError(err)
 		return nil
 	}
 	defer client.Close()
@@ -68,7 +68,7 @@ func addGroupsToRole(cmd *cobra.Command, args []string) error {
 	// Verify that roleName is valid
 	isValid, err := isValidRole(client, roleName)
 	if err != nil {
-		fmt.Println(toAPIError(err))
+		printError(err)
 		return nil
 	}
 	if !isValid {
@@ -77,7 +77,7 @@ func addGroupsToRole(cmd *cobra.Command, args []string) error {
 
 	// Add groups to the role
 	if err = client.AddGroupsToRole(roleName, groups); err != nil {
-		fmt.Println(toAPIError(err))
+		printError(err)
 		return nil
 	}
 
@@ -47,7 +47,7 @@ type groupMap map[string][]string
 func listGroups(cmd *cobra.Command, args []string) error {
 	client, err := getClient()
 	if err != nil {
-		fmt.Println(err)
+		printError(err)
 		return nil
 	}
 	defer client.Close()
@@ -63,7 +63,7 @@ func listGroups(cmd *cobra.Command, args []string) error {
 	// Get list of all groups and their roles
 	_, roleGroups, err := getRoles(cmd, nil, true, client)
 	if err != nil {
-		fmt.Println(toAPIError(err))
+		printError(err)
 		return nil
 	}
 
@@ -52,7 +52,7 @@ func removeGroupFromRole(cmd *cobra.Command, args []string) error {
 
 	client, err := getClient()
 	if err != nil {
-		fmt.Println(err)
+		printError(err)
 		return nil
 	}
 	defer client.Close()
@@ -60,7 +60,7 @@ func removeGroupFromRole(cmd *cobra.Command, args []string) error {
 	// Verify that roleName is valid
 	isValid, err := isValidRole(client, roleName)
 	if err != nil {
-		fmt.Println(toAPIError(err))
+		printError(err)
 		return nil
 	}
 	if !isValid {
@@ -69,7 +69,7 @@ func removeGroupFromRole(cmd *cobra.Command, args []string) error {
 
 	// Remove groups to the role
 	if err = client.RemoveGroupsFromRole(roleName, groups); err != nil {
-		fmt.Println(toAPIError(err))
+		printError(err)
 		return nil
 	}
 
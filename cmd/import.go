@@ -0,0 +1,120 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const overwriteOpt = "overwrite"
+
+// importCmd imports Sentry policy
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "import Sentry policy",
+	Long: `Import Sentry policy previously created with export command.
+The policy is read from the file given as the argument or from stdin if the file
+is '-'. Files with .yaml or .yml extension are parsed as YAML, everything else
+is parsed as JSON unless --format is specified.
+
+By default imported privileges are merged with existing ones. With --overwrite
+flag privileges of existing roles are replaced by the imported ones.
+
+Import is only supported by the legacy (Hive) model.`,
+	Example: `
+  sentrytool import policy.json
+  sentrytool import --overwrite policy.yaml
+  sentrytool export | sentrytool -H backup-host import -`,
+	RunE: importPolicy,
+}
+
+func importPolicy(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("expected a single policy file")
+	}
+	fileName := args[0]
+	format, _ := cmd.Flags().GetString(formatOpt)
+	if format == "" {
+		ext := strings.ToLower(filepath.Ext(fileName))
+		if ext == ".yaml" || ext == ".yml" {
+			format = yamlFormat
+		} else {
+			format = jsonFormat
+		}
+	}
+	overwrite, _ := cmd.Flags().GetBool(overwriteOpt)
+
+	var data []byte
+	var err error
+	if fileName == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(fileName)
+	}
+	if err != nil {
+		return err
+	}
+
+	var policy *sentryapi.Policy
+	switch format {
+	case jsonFormat:
+		policy, err = sentryapi.PolicyFromJSON(data)
+	case yamlFormat:
+		policy, err = sentryapi.PolicyFromYAML(data)
+	default:
+		return fmt.Errorf("invalid format %s", format)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid policy file %s: %s", fileName, err)
+	}
+
+	client, err := getLegacyClient()
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	defer client.Close()
+	policyIO, ok := client.(sentryapi.PolicyIO)
+	if !ok {
+		printError(fmt.Errorf("%w: import policy", sentryapi.ErrNotSupported))
+		return nil
+	}
+
+	if err = policyIO.ImportPolicy(policy, overwrite); err != nil {
+		printError(err)
+		return nil
+	}
+
+	if viper.GetBool(verboseOpt) {
+		fmt.Printf("imported %d roles, %d groups, %d users\n",
+			len(policy.Privileges), len(policy.Groups), len(policy.Users))
+	}
+	return nil
+}
+
+func init() {
+	importCmd.Flags().StringP(formatOpt, "f", "", "input format (json or yaml)")
+	importCmd.Flags().BoolP(overwriteOpt, "", false, "overwrite existing role privileges")
+	RootCmd.AddCommand(importCmd)
+}
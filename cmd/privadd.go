@@ -17,6 +17,7 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/akolb1/sentrytool/sentryapi"
 	"github.com/spf13/cobra"
@@ -31,7 +32,11 @@ var privAddCmd = &cobra.Command{
 options or using sentry-style privilege specification. Any specification in the command-line
 override options.
 
-Multiple privileges may be set at the same time.`,
+Multiple privileges may be set at the same time.
+
+With --skip-covered privileges already implied by privileges of the role, e.g.
+a table privilege when the role has ALL on the database, are reported instead
+of granted.`,
 	Example: `
   $ sentrytool privilege grant -s server2 -r admin \
     'db=db4->table=mytable->action=insert' \
@@ -39,7 +44,12 @@ Multiple privileges may be set at the same time.`,
 
   $ sentrytool privileges list
   admin = server=server2->db=db4->table=mytable->action=insert,\
-          server=server2->db=db5->table=mytable->action=remove`,
+          server=server2->db=db5->table=mytable->action=remove
+
+  $ sentrytool privilege grant --skip-covered admin \
+    'server=server2->db=db4->table=mytable->column=id->action=insert'
+  server=server2->db=db4->table=mytable->column=id->action=insert is covered by \
+    server=server2->db=db4->table=mytable->action=insert`,
 }
 
 func addPrivilege(cmd *cobra.Command, args []string) error {
@@ -58,7 +68,7 @@ func addPrivilege(cmd *cobra.Command, args []string) error {
 	// Get Thrift client
 	client, err := getClient()
 	if err != nil {
-		fmt.Println(err)
+		printError(err)
 		return nil
 	}
 	defer client.Close()
@@ -81,7 +91,10 @@ func addPrivilege(cmd *cobra.Command, args []string) error {
 	uri, _ := cmd.Flags().GetString("uri")
 	scope, _ := cmd.Flags().GetString("scope")
 	grant, _ := cmd.Flags().GetBool("grantoption")
-	service, _ := cmd.Flags().GetString("service")
+	service, err := serviceName(cmd)
+	if err != nil {
+		return err
+	}
 	unsetGrant, _ := cmd.Flags().GetBool("unsetgrant")
 
 	priv := &sentryapi.Privilege{
@@ -96,19 +109,45 @@ func addPrivilege(cmd *cobra.Command, args []string) error {
 		Service:          service,
 		UnsetGrantOption: unsetGrant,
 	}
+	if err := setComponentAuthorizables(cmd, priv); err != nil {
+		return err
+	}
+
+	var granted *sentryapi.PrivilegeSet
+	if skipCovered, _ := cmd.Flags().GetBool("skip-covered"); skipCovered {
+		template, err := listTemplate(cmd)
+		if err != nil {
+			printError(err)
+			return nil
+		}
+		privList, err := client.ListPrivilegesByRole(roleName, template)
+		if err != nil {
+			printError(err)
+			return nil
+		}
+		granted = sentryapi.NewPrivilegeSet(privList...)
+	}
 
-	addPrivileges(client, roleName, priv, privs)
+	addPrivileges(client, roleName, priv, privs, granted)
 	return nil
 }
 
-// Add multiple privileges to a role
+// Add multiple privileges to a role. If granted is not nil, privileges covered
+// by it are reported and skipped.
 func addPrivileges(client sentryapi.ClientAPI, role string, template *sentryapi.Privilege,
-	args []string) {
+	args []string, granted *sentryapi.PrivilegeSet) {
 	// Without args, the template is our privilege
 	if len(args) == 0 {
+		if err := validatePrivilege(template); err != nil {
+			printError(err)
+			return
+		}
+		if isCovered(granted, template) {
+			return
+		}
 		err := client.GrantPrivilege(role, template)
 		if err != nil {
-			fmt.Println(toAPIError(err))
+			printError(err)
 		}
 	}
 	// Privileges specified at the command line, parse them, fill unset parts from
@@ -116,18 +155,48 @@ func addPrivileges(client sentryapi.ClientAPI, role string, template *sentryapi.
 	for _, privSpec := range args {
 		privilege, err := parsePrivilege(privSpec, template)
 		if err != nil {
-			fmt.Println(err)
+			printError(err)
+			continue
+		}
+		if err = validatePrivilege(privilege); err != nil {
+			printError(err)
+			continue
+		}
+		if isCovered(granted, privilege) {
 			continue
 		}
 		err = client.GrantPrivilege(role, privilege)
 		if err != nil {
-			fmt.Println(toAPIError(err))
+			printError(err)
 			continue
 		}
+		if granted != nil {
+			granted.Add(privilege)
+		}
+	}
+}
+
+// isCovered reports and returns true if the privilege is implied by
+// privileges already granted
+func isCovered(granted *sentryapi.PrivilegeSet, privilege *sentryapi.Privilege) bool {
+	if granted == nil {
+		return false
+	}
+	covering := granted.CoveredBy(privilege)
+	if len(covering) == 0 {
+		return false
+	}
+	privs := make([]string, 0, len(covering))
+	for _, priv := range covering {
+		privs = append(privs, displayPrivilege("", priv))
 	}
+	fmt.Println(displayPrivilege("", privilege), "is covered by", strings.Join(privs, ", "))
+	return true
 }
 
 func init() {
 	privAddCmd.Flags().BoolP("unsetgrant", "", false, "set grant option to 'unset")
+	privAddCmd.Flags().BoolP("skip-covered", "", false,
+		"skip privileges implied by privileges of the role")
 	privCmd.AddCommand(privAddCmd)
 }
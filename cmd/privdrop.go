@@ -0,0 +1,126 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var privDropCmd = &cobra.Command{
+	Use:     "drop-object",
+	Aliases: []string{"drop"},
+	Short:   "drop all privileges on an object",
+	Long: `Drop all privileges on the specified object from all roles.
+This is useful for cleaning up privileges after Hive object is dropped.
+The object is specified with server, database, table, column or URI flags.
+Generic model objects are specified with component flags, e.g. --topic.
+
+Unless --force flag is specified, asks for confirmation.`,
+	Example: `
+  $ sentrytool privilege drop-object -s server1 -d sales -t orders
+  $ sentrytool privilege drop-object --force -s server1 -d staging
+  $ sentrytool -C kafka --service kafka1 privilege drop-object --topic clicks`,
+	RunE: dropPrivileges,
+}
+
+func dropPrivileges(cmd *cobra.Command, args []string) error {
+	object, err := dropObject(cmd)
+	if err != nil {
+		return err
+	}
+
+	force, _ := cmd.Flags().GetBool(forceOpt)
+	if !force && !askYN(fmt.Sprintf("drop all privileges on '%s'? ",
+		displayPrivilege("", object))) {
+		return nil
+	}
+
+	client, err := getClient()
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	defer client.Close()
+
+	if err = client.DropPrivilegesOnObject(object); err != nil {
+		printError(err)
+		return nil
+	}
+
+	if viper.GetBool(verboseOpt) {
+		fmt.Println("dropped privileges on", displayPrivilege("", object))
+	}
+	return nil
+}
+
+// dropObject returns the object specified by the command flags. The legacy
+// model object requires a server and a database, table, column or URI. The
+// generic model object requires a service and at least one authorizable.
+func dropObject(cmd *cobra.Command) (*sentryapi.Privilege, error) {
+	server, _ := cmd.Flags().GetString("server")
+	database, _ := cmd.Flags().GetString("database")
+	table, _ := cmd.Flags().GetString("table")
+	column, _ := cmd.Flags().GetString("column")
+	uri, _ := cmd.Flags().GetString("uri")
+	service, err := serviceName(cmd)
+	if err != nil {
+		return nil, err
+	}
+	action, _ := cmd.Flags().GetString("action")
+
+	object := &sentryapi.Privilege{
+		Server:   server,
+		Database: database,
+		Table:    table,
+		Column:   column,
+		URI:      uri,
+		Service:  service,
+		Action:   action,
+	}
+	if err := setComponentAuthorizables(cmd, object); err != nil {
+		return nil, err
+	}
+	if !isGeneric() {
+		if object.Server == "" {
+			return nil, errors.New("missing server")
+		}
+		if object.Database == "" && object.Table == "" &&
+			object.Column == "" && object.URI == "" {
+			return nil, errors.New("missing object")
+		}
+		return object, nil
+	}
+	if object.Service == "" {
+		return nil, fmt.Errorf("--%s is required for the generic model", serviceOpt)
+	}
+	if len(object.Authorizables) == 0 {
+		return nil, errors.New("missing object")
+	}
+	if schema, ok := sentryapi.GetComponentSchema(
+		viper.GetString(componentOpt)); ok {
+		schema.FillDefaults(object)
+	}
+	return object, nil
+}
+
+func init() {
+	privDropCmd.Flags().BoolP(forceOpt, "", false, "do not ask for confirmation")
+	privCmd.AddCommand(privDropCmd)
+}
@@ -0,0 +1,55 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestDropObject(t *testing.T) {
+	setFlag(t, privDropCmd, "database", "sales")
+	if _, err := dropObject(privDropCmd); err == nil {
+		t.Error("legacy model without server: expected error")
+	}
+	setFlag(t, privDropCmd, "server", "server1")
+	object, err := dropObject(privDropCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := object.String(); s != "server=server1->db=sales" {
+		t.Errorf("expected server=server1->db=sales, got %s", s)
+	}
+}
+
+func TestDropObject_Generic(t *testing.T) {
+	defer viper.Set(componentOpt, "")
+	defer viper.Set(serviceOpt, "")
+
+	viper.Set(componentOpt, "kafka")
+	viper.Set(serviceOpt, "kafka1")
+	if _, err := dropObject(privDropCmd); err == nil {
+		t.Error("generic model without authorizables: expected error")
+	}
+	setFlag(t, privDropCmd, "topic", "clicks")
+	object, err := dropObject(privDropCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := object.String(); s != "service=kafka1->host=*->topic=clicks" {
+		t.Errorf("expected service=kafka1->host=*->topic=clicks, got %s", s)
+	}
+}
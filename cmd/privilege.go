@@ -20,6 +20,7 @@ import (
 
 	"github.com/akolb1/sentrytool/sentryapi"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -30,19 +31,14 @@ import (
  *   server=server1->db=jranalyst1->table=*->action=select
  *   server=server1->uri=hdfs://ha-nn-uri/landing/analyst1
  *
- * We parse these into a structure representation
+ * sentryapi.ParsePrivilege() parses these into a structure representation
  */
 const (
 	sentrySeparator = "->"
 	valSeparator    = "="
 
-	serverKey = "server"
-	dbKey     = "db"
-	tableKey  = "table"
-	columnKey = "column"
-	uriKey    = "uri"
-	actionKey = "action"
-	grantKey  = "grantoption"
+	dbKey    = "db"
+	tableKey = "table"
 )
 
 var privCmd = &cobra.Command{
@@ -63,48 +59,155 @@ sentrytool privilege grant -r r1 -s server1 -d db2 -t table1 -c columnt1 \
       -a insert
 sentrytool privilege list r1
 r1 = db=db1->action=all, \
-     server=server1->db=db2->table=table1->column=column1->action=insert`,
+     server=server1->db=db2->table=table1->column=column1->action=insert
+sentrytool -C kafka privilege grant --service kafka1 -r r1 'topic=clicks->action=read'`,
 }
 
-// Parse privilege in Sentry format into a Privilege object
-// E.g. server=server1=>db=mydb
+// Parse privilege in Sentry format into a Privilege object, filling unset
+// parts from the template. E.g. server=server1->db=mydb
+// When a component is specified, any type=name segment other than action,
+// service, scope, grantoption, grantor and createtime is treated as generic
+// model authorizable, e.g. topic=clicks->action=read
 func parsePrivilege(priv string,
 	template *sentryapi.Privilege) (*sentryapi.Privilege, error) {
-	parts := strings.Split(priv, sentrySeparator)
+	parse := sentryapi.ParsePrivilege
+	if isGeneric() {
+		parse = sentryapi.ParseGenericPrivilege
+	}
+	parsed, err := parse(priv)
+	if err != nil {
+		return nil, err
+	}
 	privilege := *template
-	for _, v := range parts {
-		splits := strings.Split(v, valSeparator)
-		if len(splits) != 2 {
-			return nil, fmt.Errorf("invalid perm format for '%s'", v)
+	privilege.Authorizables = append([]sentryapi.Authorizable(nil),
+		template.Authorizables...)
+	for _, field := range []struct {
+		value  string
+		target *string
+	}{
+		{parsed.Server, &privilege.Server},
+		{parsed.Database, &privilege.Database},
+		{parsed.Table, &privilege.Table},
+		{parsed.Column, &privilege.Column},
+		{parsed.URI, &privilege.URI},
+		{parsed.Service, &privilege.Service},
+		{parsed.Scope, &privilege.Scope},
+		{parsed.Action, &privilege.Action},
+	} {
+		if field.value != "" {
+			*field.target = field.value
 		}
-		name := splits[0]
-		val := splits[1]
-		switch name {
-		case serverKey:
-			privilege.Server = val
-		case dbKey:
-			privilege.Database = val
-		case tableKey:
-			privilege.Table = val
-		case columnKey:
-			privilege.Column = val
-		case uriKey:
-			privilege.URI = val
-		case actionKey:
-			privilege.Action = val
-		default:
-			return nil, fmt.Errorf("invalid scope name %s", name)
+	}
+	for _, auth := range parsed.Authorizables {
+		privilege.Authorizables = setAuthorizable(privilege.Authorizables,
+			auth.Type, auth.Name)
+	}
+	privilege.GrantOption = privilege.GrantOption || parsed.GrantOption
+	privilege.UnsetGrantOption = privilege.UnsetGrantOption ||
+		parsed.UnsetGrantOption
+	return &privilege, nil
+}
+
+// setAuthorizable replaces the value of authorizable with the given type or
+// appends a new authorizable if there is none
+func setAuthorizable(auths []sentryapi.Authorizable,
+	authType string, name string) []sentryapi.Authorizable {
+	for i, auth := range auths {
+		if strings.EqualFold(auth.Type, authType) {
+			auths[i].Name = name
+			return auths
 		}
+	}
+	return append(auths, sentryapi.Authorizable{Type: authType, Name: name})
+}
 
-		// Special case for internal purposes - allow setting grantOption to "UNSET"
-		if name == grantKey && strings.HasPrefix(strings.ToLower(val), "t") {
-			privilege.GrantOption = true
-			continue
+// setComponentAuthorizables fills privilege authorizables from
+// component-specific flags, e.g. --topic for Kafka. Flags are only valid for
+// components that have the corresponding authorizable type in their schema.
+func setComponentAuthorizables(cmd *cobra.Command,
+	priv *sentryapi.Privilege) error {
+	component := viper.GetString(componentOpt)
+	schema, ok := sentryapi.GetComponentSchema(component)
+	valid := make(map[string]bool)
+	if ok {
+		for _, auth := range schema.Types() {
+			valid[componentFlagName(schema.Component, auth.Type)] = true
 		}
 	}
-	return &privilege, nil
+	var err error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if err != nil || !flag.Changed || !componentFlags[flag.Name] ||
+			valid[flag.Name] {
+			return
+		}
+		if component == "" {
+			err = fmt.Errorf("option --%s requires a component", flag.Name)
+		} else {
+			err = fmt.Errorf("option --%s is not valid for component %s",
+				flag.Name, component)
+		}
+	})
+	if err != nil || !ok {
+		return err
+	}
+	for _, auth := range schema.Types() {
+		value, _ := cmd.Flags().GetString(
+			componentFlagName(schema.Component, auth.Type))
+		if value != "" {
+			priv.Authorizables = setAuthorizable(priv.Authorizables,
+				auth.Type, value)
+		}
+	}
+	return nil
 }
 
+// componentFlagName returns the name of the flag for the component
+// authorizable type. Types which clash with global options are prefixed with
+// the component name, e.g. --kafka-host and --solr-config.
+func componentFlagName(component string, authType string) string {
+	switch authType {
+	case hostOpt, configOpt:
+		return component + "-" + authType
+	}
+	return authType
+}
+
+// addComponentFlags registers component-specific flags for authorizable
+// types of known component schemas. Flags that already exist in the shared
+// flag set, like --server, are shared with the Hive model.
+func addComponentFlags(cmd *cobra.Command, shared *pflag.FlagSet) {
+	for _, schema := range sentryapi.ComponentSchemas() {
+		for _, auth := range schema.Types() {
+			name := componentFlagName(schema.Component, auth.Type)
+			if shared.Lookup(name) != nil || cmd.Flags().Lookup(name) != nil {
+				continue
+			}
+			cmd.Flags().StringP(name, "", "",
+				auth.Type+" name (generic model)")
+			componentFlags[name] = true
+		}
+	}
+}
+
+// validatePrivilege checks generic model privilege against the schema of the
+// current component. Default authorizables omitted by the user are added to
+// the privilege.
+func validatePrivilege(priv *sentryapi.Privilege) error {
+	if !isGeneric() {
+		return nil
+	}
+	schema, ok := sentryapi.GetComponentSchema(viper.GetString(componentOpt))
+	if !ok {
+		return nil
+	}
+	schema.FillDefaults(priv)
+	return schema.Validate(priv)
+}
+
+// componentFlags is the set of component-specific flags registered
+// for authorizable types of known component schemas.
+var componentFlags = make(map[string]bool)
+
 func init() {
 	privCmd.PersistentFlags().StringP("action", "a", "", "action")
 	privCmd.PersistentFlags().StringP("server", "s", "", "server name")
@@ -112,11 +215,18 @@ func init() {
 	privCmd.PersistentFlags().StringP("table", "t", "", "table name")
 	privCmd.PersistentFlags().StringP("column", "c", "", "column name")
 	privCmd.PersistentFlags().StringP("uri", "u", "", "URI")
-	privCmd.PersistentFlags().StringP("scope", "", "", "Scope")
-	privCmd.PersistentFlags().StringP("service", "", "", "service name")
+	privCmd.PersistentFlags().StringP("scope", "", "",
+		"privilege scope: server, database, table, column or uri")
 	privCmd.PersistentFlags().StringP("role", "r", "", "role name")
 
 	privCmd.PersistentFlags().BoolP("grantoption", "", false, "grantOption")
 
+	// Component-specific authorizable flags only apply to commands which
+	// take a single privilege or object
+	for _, cmd := range []*cobra.Command{privAddCmd, privRevokeCmd,
+		privDropCmd} {
+		addComponentFlags(cmd, privCmd.PersistentFlags())
+	}
+
 	RootCmd.AddCommand(privCmd)
 }
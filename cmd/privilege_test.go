@@ -0,0 +1,121 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestParsePrivilege_Scope(t *testing.T) {
+	template := &sentryapi.Privilege{Server: "server1", Scope: sentryapi.ScopeTable}
+	priv, err := parsePrivilege("db=sales->scope=DATABASE", template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if priv.Scope != sentryapi.ScopeDatabase {
+		t.Errorf("expected %s scope, got %s", sentryapi.ScopeDatabase, priv.Scope)
+	}
+}
+
+func TestParsePrivilege_Generic(t *testing.T) {
+	defer viper.Set(componentOpt, "")
+	template := &sentryapi.Privilege{Service: "kafka1",
+		Authorizables: []sentryapi.Authorizable{{Type: "host", Name: "h1"}}}
+
+	if _, err := parsePrivilege("topic=clicks->action=read", template); err == nil {
+		t.Error("legacy model: expected error for generic authorizable")
+	}
+	viper.Set(componentOpt, "kafka")
+	tests := []struct {
+		priv     string
+		expected string
+	}{
+		{"topic=clicks->action=read",
+			"service=kafka1->host=h1->topic=clicks->action=read"},
+		{"host=h2->topic=clicks->action=read",
+			"service=kafka1->host=h2->topic=clicks->action=read"},
+		{"service=kafka2->consumergroup=g1->action=all",
+			"service=kafka2->host=h1->consumergroup=g1->action=all"},
+	}
+	for _, tt := range tests {
+		priv, err := parsePrivilege(tt.priv, template)
+		if err != nil {
+			t.Errorf("%s: %v", tt.priv, err)
+			continue
+		}
+		if s := priv.String(); s != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.priv, tt.expected, s)
+		}
+	}
+	if len(template.Authorizables) != 1 || template.Authorizables[0].Name != "h1" {
+		t.Errorf("template changed: %v", template.Authorizables)
+	}
+}
+
+func TestComponentFlags(t *testing.T) {
+	for _, cmd := range []*cobra.Command{privAddCmd, privRevokeCmd, privDropCmd} {
+		for name := range componentFlags {
+			if RootCmd.PersistentFlags().Lookup(name) != nil {
+				t.Errorf("%s: --%s hides the global option", cmd.Name(), name)
+			}
+			if cmd.Flags().Lookup(name) == nil {
+				t.Errorf("%s: missing --%s", cmd.Name(), name)
+			}
+		}
+	}
+	if privListCmd.Flags().Lookup("topic") != nil {
+		t.Error("list: unexpected --topic")
+	}
+
+	defer viper.Set(componentOpt, "")
+	setFlag(t, privAddCmd, "kafka-host", "h1")
+	setFlag(t, privAddCmd, "topic", "clicks")
+	priv := &sentryapi.Privilege{}
+	if err := setComponentAuthorizables(privAddCmd, priv); err == nil {
+		t.Error("legacy model: expected error for --topic")
+	}
+	viper.Set(componentOpt, "solr")
+	if err := setComponentAuthorizables(privAddCmd, priv); err == nil {
+		t.Error("solr: expected error for --topic")
+	}
+	viper.Set(componentOpt, "kafka")
+	if err := setComponentAuthorizables(privAddCmd, priv); err != nil {
+		t.Fatal(err)
+	}
+	if s := priv.String(); s != "host=h1->topic=clicks" {
+		t.Errorf("expected host=h1->topic=clicks, got %s", s)
+	}
+}
+
+// setFlag sets the command flag for the duration of the test
+func setFlag(t *testing.T, cmd *cobra.Command, name string, value string) {
+	flag := cmd.Flags().Lookup(name)
+	if flag == nil {
+		flag = cmd.InheritedFlags().Lookup(name)
+	}
+	old := flag.Value.String()
+	if err := flag.Value.Set(value); err != nil {
+		t.Fatal(err)
+	}
+	flag.Changed = true
+	t.Cleanup(func() {
+		flag.Value.Set(old)
+		flag.Changed = false
+	})
+}
@@ -35,20 +35,32 @@ var privListCmd = &cobra.Command{
 Roles are given as command-line arguments.
 
 If any of the filtering options (server, database, table, etc) are specified,
-  only show matching privileges.`,
+  only show matching privileges.
+
+When a component is specified, the service name should be specified with --service flag.
+Generic model privileges are displayed as type=name->...->action=x.`,
+	Example: `
+  $ sentrytool privilege list admin
+  admin = server=server1->db=sales->action=all
+  $ sentrytool -C solr privilege list --service service1 admin
+  admin = collection=logs->action=query`,
 }
 
 func listPriv(cmd *cobra.Command, args []string) error {
+	template, err := listTemplate(cmd)
+	if err != nil {
+		return err
+	}
 	client, err := getClient()
 	if err != nil {
-		fmt.Println(err)
+		printError(err)
 		return nil
 	}
 	defer client.Close()
 
 	roles, _, err := getRoles(cmd, args, true, client)
 	if err != nil {
-		fmt.Println(toAPIError(err))
+		printError(err)
 		return nil
 	}
 
@@ -61,7 +73,21 @@ func listPriv(cmd *cobra.Command, args []string) error {
 	uri, _ := cmd.Flags().GetString("uri")
 	scope, _ := cmd.Flags().GetString("scope")
 	grant, _ := cmd.Flags().GetBool("grantoption")
-	service, _ := cmd.Flags().GetString("service")
+	service, err := serviceName(cmd)
+	if err != nil {
+		return err
+	}
+
+	filter := (&sentryapi.Privilege{
+		Action:   action,
+		Server:   server,
+		Database: database,
+		Table:    table,
+		Column:   column,
+		URI:      uri,
+		Scope:    scope,
+		Service:  service,
+	}).Normalize()
 
 	for _, roleName := range roles {
 		isValid, err := isValidRole(client, roleName)
@@ -71,42 +97,20 @@ func listPriv(cmd *cobra.Command, args []string) error {
 		if !isValid {
 			return fmt.Errorf("role %s doesn't exist", roleName)
 		}
-		privList, err := client.ListPrivilegesByRole(roleName, nil)
+		privList, err := client.ListPrivilegesByRole(roleName, template)
 		if err != nil {
-			fmt.Println(toAPIError(err))
+			printError(err)
 			continue
 		}
 
 		privs := make([]string, 0, len(privList))
+		seen := make(map[string]bool)
 		// Go through privileges and add matching ones
 		for _, priv := range privList {
-			if action != "" && priv.Action != action {
-				continue
-			}
-			if server != "" && priv.Server != server {
-				continue
-			}
-			if database != "" && priv.Database != database {
-				continue
-			}
-			if table != "" && priv.Table != table {
-				continue
-			}
-			if column != "" && priv.Column != column {
-				continue
-			}
-			if uri != "" && priv.URI != uri {
-				continue
-			}
-			if scope != "" && priv.Scope != scope {
-				continue
-			}
-			if service != "" && priv.Service != service {
-				continue
-			}
-			if grant && !priv.GrantOption {
+			if !matchPrivilege(priv, filter, grant) || seen[priv.Key()] {
 				continue
 			}
+			seen[priv.Key()] = true
 			privs = append(privs, displayPrivilege(roleName, priv))
 		}
 		if len(privs) == 0 {
@@ -118,28 +122,39 @@ func listPriv(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func displayPrivilege(role string, privilege *sentryapi.Privilege) string {
-	parts := []string{}
-	if privilege.Server != "" {
-		parts = append(parts, serverKey+valSeparator+privilege.Server)
-	}
-	if privilege.Database != "" {
-		parts = append(parts, dbKey+valSeparator+privilege.Database)
-	}
-	if privilege.Table != "" {
-		parts = append(parts, tableKey+valSeparator+privilege.Table)
-	}
-	if privilege.Column != "" {
-		parts = append(parts, columnKey+valSeparator+privilege.Column)
-	}
-	if privilege.URI != "" {
-		parts = append(parts, uriKey+valSeparator+privilege.URI)
-	}
-	if privilege.Action != "" {
-		parts = append(parts, actionKey+valSeparator+privilege.Action)
+// matchPrivilege returns true if the privilege matches all non-empty fields
+// of the filter. Both are compared in the normalized form, so names differing
+// only in case and "all" vs "*" actions match.
+func matchPrivilege(privilege, filter *sentryapi.Privilege, grant bool) bool {
+	priv := privilege.Normalize()
+	for _, field := range []struct{ value, want string }{
+		{priv.Action, filter.Action},
+		{priv.Server, filter.Server},
+		{priv.Database, filter.Database},
+		{priv.Table, filter.Table},
+		{priv.Column, filter.Column},
+		{priv.URI, filter.URI},
+		{priv.Scope, filter.Scope},
+		{priv.Service, filter.Service},
+	} {
+		if field.want != "" && field.value != field.want {
+			return false
+		}
 	}
+	return !grant || priv.GrantOption
+}
 
-	return strings.Join(parts, sentrySeparator)
+// displayPrivilege returns privilege in Sentry format. The service is
+// omitted since it is always specified with --service flag, the scope is
+// implied by the privilege objects. Grantor and create time are assigned by
+// Sentry and are not shown.
+func displayPrivilege(role string, privilege *sentryapi.Privilege) string {
+	priv := *privilege
+	priv.Service = ""
+	priv.Scope = ""
+	priv.Grantor = ""
+	priv.CreateTime = 0
+	return priv.String()
 }
 
 func init() {
@@ -0,0 +1,41 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+)
+
+func TestDisplayPrivilege(t *testing.T) {
+	tests := []struct {
+		priv     sentryapi.Privilege
+		expected string
+	}{
+		{sentryapi.Privilege{Server: "server1", Database: "sales",
+			Scope: sentryapi.ScopeDatabase, Action: "select"},
+			"server=server1->db=sales->action=select"},
+		{sentryapi.Privilege{Service: "kafka1", Authorizables: []sentryapi.Authorizable{
+			{Type: "host", Name: "*"}, {Type: "topic", Name: "clicks"}},
+			Action: "read", GrantOption: true},
+			"host=*->topic=clicks->action=read->grantoption=true"},
+	}
+	for _, tt := range tests {
+		if s := displayPrivilege("r1", &tt.priv); s != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, s)
+		}
+	}
+}
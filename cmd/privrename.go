@@ -0,0 +1,115 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	fromOpt = "from"
+	toOpt   = "to"
+)
+
+var privRenameCmd = &cobra.Command{
+	Use:     "rename-object",
+	Aliases: []string{"rename"},
+	Short:   "move all privileges to a renamed object",
+	Long: `Move privileges on an object to a new object for all roles.
+This is useful for keeping privileges after Hive object is renamed.
+
+Objects are specified as 'db', 'db.table' or using sentry-style specification
+like 'db=sales->table=orders'. Server is specified with -s flag.`,
+	Example: `
+  $ sentrytool privilege rename-object -s server1 --from sales.orders --to sales.orders_v2
+  $ sentrytool privilege rename-object -s server1 --from staging --to staging_old`,
+	RunE: renamePrivileges,
+}
+
+func renamePrivileges(cmd *cobra.Command, args []string) error {
+	fromSpec, _ := cmd.Flags().GetString(fromOpt)
+	toSpec, _ := cmd.Flags().GetString(toOpt)
+	if fromSpec == "" || toSpec == "" {
+		return errors.New("both --from and --to should be specified")
+	}
+
+	server, _ := cmd.Flags().GetString("server")
+	service, err := serviceName(cmd)
+	if err != nil {
+		return err
+	}
+	template := &sentryapi.Privilege{
+		Server:  server,
+		Service: service,
+	}
+
+	from, err := parseObject(fromSpec, template)
+	if err != nil {
+		return err
+	}
+	to, err := parseObject(toSpec, template)
+	if err != nil {
+		return err
+	}
+
+	client, err := getClient()
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	defer client.Close()
+
+	if err = client.RenamePrivilegesOnObject(from, to); err != nil {
+		printError(err)
+		return nil
+	}
+
+	if viper.GetBool(verboseOpt) {
+		fmt.Println("renamed privileges on", displayPrivilege("", from),
+			"to", displayPrivilege("", to))
+	}
+	return nil
+}
+
+// parseObject parses object specified either as db.table or in Sentry
+// format, filling unset parts from the template.
+func parseObject(spec string,
+	template *sentryapi.Privilege) (*sentryapi.Privilege, error) {
+	if strings.Contains(spec, valSeparator) {
+		return parsePrivilege(spec, template)
+	}
+	object := *template
+	parts := strings.Split(spec, ".")
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("invalid object '%s'", spec)
+	}
+	object.Database = parts[0]
+	if len(parts) == 2 {
+		object.Table = parts[1]
+	}
+	return &object, nil
+}
+
+func init() {
+	privRenameCmd.Flags().StringP(fromOpt, "", "", "old object")
+	privRenameCmd.Flags().StringP(toOpt, "", "", "new object")
+	privCmd.AddCommand(privRenameCmd)
+}
@@ -0,0 +1,54 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+)
+
+func TestParseObject(t *testing.T) {
+	template := &sentryapi.Privilege{Server: "server1"}
+	tests := []struct {
+		spec     string
+		expected *sentryapi.Privilege
+	}{
+		{"sales", &sentryapi.Privilege{Server: "server1", Database: "sales"}},
+		{"sales.orders", &sentryapi.Privilege{Server: "server1",
+			Database: "sales", Table: "orders"}},
+		{"db=sales->table=orders", &sentryapi.Privilege{Server: "server1",
+			Database: "sales", Table: "orders"}},
+		{"server=server2->db=sales", &sentryapi.Privilege{Server: "server2",
+			Database: "sales"}},
+		{"sales.orders.id", nil},
+	}
+	for _, tt := range tests {
+		object, err := parseObject(tt.spec, template)
+		if tt.expected == nil {
+			if err == nil {
+				t.Errorf("%s: expected error, got %v", tt.spec, object)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+			continue
+		}
+		if !object.Equal(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.spec, tt.expected, object)
+		}
+	}
+}
@@ -52,7 +52,7 @@ func revokePrivilege(cmd *cobra.Command, args []string) error {
 
 	client, err := getClient()
 	if err != nil {
-		fmt.Println(err)
+		printError(err)
 		return nil
 	}
 	defer client.Close()
@@ -73,7 +73,10 @@ func revokePrivilege(cmd *cobra.Command, args []string) error {
 	uri, _ := cmd.Flags().GetString("uri")
 	scope, _ := cmd.Flags().GetString("scope")
 	grant, _ := cmd.Flags().GetBool("grantoption")
-	service, _ := cmd.Flags().GetString("service")
+	service, err := serviceName(cmd)
+	if err != nil {
+		return err
+	}
 
 	priv := &sentryapi.Privilege{
 		Action:      action,
@@ -86,6 +89,9 @@ func revokePrivilege(cmd *cobra.Command, args []string) error {
 		GrantOption: grant,
 		Service:     service,
 	}
+	if err := setComponentAuthorizables(cmd, priv); err != nil {
+		return err
+	}
 
 	removePrivileges(client, roleName, priv, privs)
 	return nil
@@ -96,9 +102,13 @@ func removePrivileges(client sentryapi.ClientAPI, role string, template *sentrya
 	args []string) {
 	// Without args, the template is our privilege
 	if len(args) == 0 {
+		if err := validatePrivilege(template); err != nil {
+			printError(err)
+			return
+		}
 		err := client.RevokePrivilege(role, template)
 		if err != nil {
-			fmt.Println(toAPIError(err))
+			printError(err)
 		}
 	}
 	// Privileges specified at the command line, parse them, fill unset parts from
@@ -106,12 +116,16 @@ func removePrivileges(client sentryapi.ClientAPI, role string, template *sentrya
 	for _, privSpec := range args {
 		privilege, err := parsePrivilege(privSpec, template)
 		if err != nil {
-			fmt.Println(err)
+			printError(err)
+			continue
+		}
+		if err = validatePrivilege(privilege); err != nil {
+			printError(err)
 			continue
 		}
 		err = client.RevokePrivilege(role, privilege)
 		if err != nil {
-			fmt.Println(toAPIError(err))
+			printError(err)
 			continue
 		}
 	}
@@ -0,0 +1,122 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var privWhoCmd = &cobra.Command{
+	Use:   "who",
+	Short: "show who has access to an object",
+	Long: `Show all roles that have privileges on the specified object along with
+the groups each role is granted to.
+The object is specified with server, database, table, column or URI flags.
+Without -s flag the server is taken from SENTRY_SERVER or the config file.
+
+If -g flag is specified, only roles granted to these groups are shown.`,
+	Example: `
+  $ sentrytool privilege who -d sales -t orders
+  analyst = server=server1->db=sales->table=orders->action=select
+    groups = analysts, finance
+  $ sentrytool privilege who -d sales -t orders -g finance`,
+	RunE: whoHasAccess,
+}
+
+func whoHasAccess(cmd *cobra.Command, args []string) error {
+	server, _ := cmd.Flags().GetString("server")
+	database, _ := cmd.Flags().GetString("database")
+	table, _ := cmd.Flags().GetString("table")
+	column, _ := cmd.Flags().GetString("column")
+	uri, _ := cmd.Flags().GetString("uri")
+	service, err := serviceName(cmd)
+	if err != nil {
+		return err
+	}
+	groups, _ := cmd.Flags().GetStringSlice(groupOpt)
+
+	object := &sentryapi.Privilege{
+		Server:   server,
+		Database: database,
+		Table:    table,
+		Column:   column,
+		URI:      uri,
+		Service:  service,
+	}
+	if isGeneric() {
+		if object.Service == "" {
+			return fmt.Errorf("--%s is required for the generic model", serviceOpt)
+		}
+	} else if object.Server == "" {
+		if object.Server = viper.GetString(serverOpt); object.Server == "" {
+			return errors.New("missing server")
+		}
+	}
+
+	client, err := getClient()
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	defer client.Close()
+
+	rolePrivileges, err := client.ListPrivilegesByObject(object, groups)
+	if err != nil {
+		printError(err)
+		return nil
+	}
+
+	// Get groups for each role
+	_, roleList, err := client.ListRoleByGroup("")
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	roleGroups := make(roleGroupMap)
+	for _, role := range roleList {
+		roleGroups[role.Name] = role.Groups
+	}
+
+	roles := make([]string, 0, len(rolePrivileges))
+	for role := range rolePrivileges {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		privs := make([]string, 0, len(rolePrivileges[role]))
+		for _, priv := range rolePrivileges[role] {
+			privs = append(privs, displayPrivilege(role, priv))
+		}
+		sort.Strings(privs)
+		fmt.Println(role, "=", strings.Join(privs, ", "))
+		groups := roleGroups[role]
+		sort.Strings(groups)
+		fmt.Println("  groups =", strings.Join(groups, ", "))
+	}
+	return nil
+}
+
+func init() {
+	privWhoCmd.Flags().StringSliceP(groupOpt, "g", nil, "only show roles for these groups")
+	privCmd.AddCommand(privWhoCmd)
+}
@@ -0,0 +1,66 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/spf13/viper"
+)
+
+func TestPrivilegeWho(t *testing.T) {
+	setTestServer(t)
+	defer viper.Set(serverOpt, "")
+	roleName := "sentrytool_test_who_role"
+	group := "sentrytool_test_who_group"
+	client, err := getClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.CreateRole(roleName); err != nil {
+		t.Fatal(err)
+	}
+	defer client.RemoveRole(roleName)
+	if err := client.AddGroupsToRole(roleName, []string{group}); err != nil {
+		t.Fatal(err)
+	}
+	priv := &sentryapi.Privilege{Server: "server1", Database: "sales",
+		Table: "orders", Action: "select"}
+	if err := client.GrantPrivilege(roleName, priv); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, name := range []string{"database", "table"} {
+			flag := privWhoCmd.Flags().Lookup(name)
+			flag.Value.Set("")
+			flag.Changed = false
+		}
+	}()
+
+	RootCmd.SetArgs([]string{"privilege", "who", "-d", "sales", "-t", "orders"})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("without server: expected error")
+	}
+
+	// The server comes from SENTRY_SERVER or the config file
+	viper.Set(serverOpt, "server1")
+	expected := roleName + " = server=server1->db=sales->table=orders->action=select\n" +
+		"  groups = " + group + "\n"
+	if out := runCommand(t, "privilege", "who", "-d", "sales", "-t", "orders"); out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
@@ -32,7 +32,7 @@ var roleCreateCmd = &cobra.Command{
 func roleCreate(cmd *cobra.Command, args []string) {
 	client, err := getClient()
 	if err != nil {
-		fmt.Println(err)
+		printError(err)
 		return
 	}
 	defer client.Close()
@@ -42,7 +42,7 @@ func roleCreate(cmd *cobra.Command, args []string) {
 	// Get existing roles
 	roles, _, err := client.ListRoleByGroup("")
 	if err != nil {
-		fmt.Println(toAPIError(err))
+		printError(err)
 		return
 	}
 
@@ -59,7 +59,7 @@ func roleCreate(cmd *cobra.Command, args []string) {
 		}
 		err = client.CreateRole(roleName)
 		if err != nil {
-			fmt.Println(toAPIError(err))
+			printError(err)
 			continue
 		}
 		existingRoles[roleName] = true
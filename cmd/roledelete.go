@@ -43,7 +43,7 @@ func roleDelete(cmd *cobra.Command, args []string) {
 
 	roles, _, err := getRoles(cmd, args, true, client)
 	if err != nil {
-		fmt.Println(toAPIError(err))
+		printError(err)
 		return
 	}
 
@@ -54,7 +54,7 @@ func roleDelete(cmd *cobra.Command, args []string) {
 		}
 		err = client.RemoveRole(roleName)
 		if err != nil {
-			fmt.Println(toAPIError(err))
+			printError(err)
 			continue
 		}
 		if verbose {
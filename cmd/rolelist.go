@@ -111,14 +111,14 @@ func getRoles(cmd *cobra.Command,
 func listRoles(cmd *cobra.Command, args []string) {
 	client, err := getClient()
 	if err != nil {
-		fmt.Println(err)
+		printError(err)
 		return
 	}
 	defer client.Close()
 
 	roles, roleGroups, err := getRoles(cmd, args, true, client)
 	if err != nil {
-		fmt.Println(toAPIError(err))
+		printError(err)
 		return
 	}
 
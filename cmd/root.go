@@ -18,27 +18,50 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 const (
-	defaultThriftPort = "8038"
-	hostOpt           = "host"
-	portOpt           = "port"
-	userOpt           = "username"
-	matchOpt          = "match"
-	groupOpt          = "group"
-	forceOpt          = "force"
-	componentOpt      = "component"
-	verboseOpt        = "verbose"
-	jstackOpt         = "jstack"
-	noverifyOpt       = "noverify"
+	defaultThriftPort  = "8038"
+	configOpt          = "config"
+	hostOpt            = "host"
+	portOpt            = "port"
+	userOpt            = "username"
+	matchOpt           = "match"
+	groupOpt           = "group"
+	forceOpt           = "force"
+	componentOpt       = "component"
+	verboseOpt         = "verbose"
+	jstackOpt          = "jstack"
+	noverifyOpt        = "noverify"
+	principalOpt       = "principal"
+	keytabOpt          = "keytab"
+	saslMechOpt        = "sasl-mech"
+	passwordOpt        = "password"
+	tlsOpt             = "tls"
+	caCertOpt          = "ca-cert"
+	clientCertOpt      = "client-cert"
+	clientKeyOpt       = "client-key"
+	tlsServerNameOpt   = "tls-server-name"
+	tlsInsecureOpt     = "tls-insecure"
+	timeoutOpt         = "timeout"
+	connectTimeoutOpt  = "connect-timeout"
+	protocolVersionOpt = "protocol-version"
+	recordOpt          = "record"
+	replayOpt          = "replay"
+	debugOpt           = "debug"
+	serviceOpt         = "service"
+	serverOpt          = "server"
 )
 
 var (
 	cfgFile string
+	// verboseFlag is set by explicit -v flag only
+	verboseFlag bool
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -62,6 +85,15 @@ Java properties config files format. B default the file ~/.sentrytool.yaml is us
 * SENTRY_USER:      User name on which behalf the request is made ('user' in the config file)
 * SENTRY_COMPONENT: Component name (e.g. 'kafka'). ('component' in the config file)
 * SENRY_VERBOSE:    Use verbose mode if set ('verbose' in config file)
+* SENTRY_SASL_MECH: SASL mechanism, 'gssapi' or 'plain' ('sasl-mech' in config file)
+* SENTRY_PRINCIPAL: Kerberos principal for GSSAPI ('principal' in config file)
+* SENTRY_KEYTAB:    Keytab for the principal ('keytab' in config file)
+* SENTRY_PASSWORD:  Password for PLAIN mechanism ('password' in config file)
+* SENTRY_TLS:       Use TLS connection if set ('tls' in config file)
+* SENTRY_CA_CERT:   PEM file with CA certificates ('ca-cert' in config file)
+* SENTRY_CLIENT_CERT, SENTRY_CLIENT_KEY: PEM client certificate and key for mutual TLS
+                    ('client-cert' and 'client-key' in config file)
+* SENTRY_SERVER:    Default server name for 'privilege who' ('server' in config file)
 
 Host may be specified in one of the following ways:
 
@@ -70,11 +102,37 @@ Host may be specified in one of the following ways:
 * host:port
 * host1,host2:port2, host4:port3
 
-When multiple hosts are specified, the wirst responding host is used.
+When multiple hosts are specified, the wirst responding host is used. If the host fails
+during the session, the tool switches to another host: read-only calls are retried and
+the failed host is not used for a while. With -v the host serving each call is shown.
 The value of port from the host string overrides all other values for a port.
 
 When a component is specified the tool uses Generic client model, otherwise it uses the
-legacy model.
+legacy model. Listing generic model privileges requires a service name set with
+'--service', SENTRY_SERVICE or the config file. The service is only used by the generic
+model. If the server doesn't provide the required service, the error shows which
+services are available. The newest Sentry protocol version supported by the server is
+used unless '--protocol-version' is specified. Use 'sentrytool server capabilities' to see
+what the server supports.
+
+Exit codes: 0 - success, 1 - failure, 2 - failed to communicate with Sentry,
+3 - object already exists, 4 - no such object, 5 - invalid input, 6 - access denied,
+7 - Thrift version mismatch, 8 - access not granted ('check' command).
+
+Kerberized Sentry requires '--sasl-mech gssapi'. Without a keytab the Kerberos ticket
+cache (KRB5CCNAME) is used. Kerberos configuration is read from KRB5_CONFIG or
+/etc/krb5.conf. The PLAIN mechanism is intended for test setups only.
+
+Connection attempts are limited by '--connect-timeout' and every read or write on the
+connection is limited by '--timeout'. Zero value disables the timeout.
+
+TLS is enabled with '--tls'. Specifying CA or client certificate also enables TLS.
+
+Thrift calls can be recorded to a file with '--record file' and served back from the file
+instead of the server with '--replay file'. Recordings are JSON lines with one request
+and response per line. They are not encrypted even when TLS or SASL is used.
+
+With '--debug' every Sentry call is logged to stderr with its arguments, result and duration.
 `,
 	Example: `
   # Display everything
@@ -104,6 +162,15 @@ legacy model.
   sentrytool group grant -r admin_role admin_group finance_group
   sentrytool group revoke admin_role finance_group
 
+  # Grant, revoke and list users (legacy model only)
+  sentrytool user grant -r admin_role hive impala
+  sentrytool user revoke admin_role impala
+  sentrytool user list -v hive
+
+  # Back up the policy and restore it on another server
+  sentrytool export > policy.json
+  sentrytool -H backup-host import policy.json
+
   # Grant and list privileges
   sentrytool privilege grant -r r1 -s server1 -d db2 -t table1 -c columnt1 \
       -a insert
@@ -114,10 +181,19 @@ legacy model.
 // Execute adds all child commands to the root command sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(-1)
+	err := RootCmd.Execute()
+	if recordErr := finishRecording(); recordErr != nil {
+		if err != nil {
+			fmt.Println(recordErr)
+		} else {
+			err = recordErr
+		}
 	}
+	if err != nil {
+		fmt.Println(toAPIError(err))
+		os.Exit(errorExitCode(err))
+	}
+	os.Exit(exitCode)
 }
 
 // listAllCmd shows all roles, groups and privileges
@@ -128,7 +204,9 @@ func listAll(cmd *cobra.Command, args []string) {
 	fmt.Println("[groups]")
 	listGroups(cmd, args)
 	fmt.Println("[privileges]")
-	listPriv(cmd, args)
+	if err := listPriv(cmd, args); err != nil {
+		printError(err)
+	}
 }
 
 func init() {
@@ -140,13 +218,29 @@ func init() {
 	// Cobra supports Persistent Flags, which, if defined here,
 	// will be global for your application.
 
-	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.sentrytool.yaml)")
+	RootCmd.PersistentFlags().StringVar(&cfgFile, configOpt, "", "config file (default is $HOME/.sentrytool.yaml)")
 	RootCmd.PersistentFlags().StringP(hostOpt, "H", "localhost", "hostname for Sentry server")
 	RootCmd.PersistentFlags().StringP(portOpt, "P", defaultThriftPort, "port for Sentry server")
 	RootCmd.PersistentFlags().StringP(userOpt, "U", currentUser.Username, "user name")
 	RootCmd.PersistentFlags().StringP(componentOpt, "C", "", "sentry client component")
-	RootCmd.PersistentFlags().BoolP(verboseOpt, "v", false, "verbose mode")
+	RootCmd.PersistentFlags().StringP(serviceOpt, "", "", "service name (generic model)")
+	RootCmd.PersistentFlags().BoolVarP(&verboseFlag, verboseOpt, "v", false, "verbose mode")
 	RootCmd.PersistentFlags().BoolP(jstackOpt, "J", false, "show Java stack on for errors")
+	RootCmd.PersistentFlags().BoolP(debugOpt, "", false, "log every Sentry call with timing")
+	RootCmd.PersistentFlags().DurationP(timeoutOpt, "", 5*time.Minute, "read/write timeout")
+	RootCmd.PersistentFlags().DurationP(connectTimeoutOpt, "", 30*time.Second, "connect timeout")
+	RootCmd.PersistentFlags().IntP(protocolVersionOpt, "", 0, "Sentry protocol version (1 or 2, 0 to detect)")
+	RootCmd.PersistentFlags().StringP(saslMechOpt, "", "", "SASL mechanism (gssapi or plain)")
+	RootCmd.PersistentFlags().StringP(principalOpt, "", "", "Kerberos principal")
+	RootCmd.PersistentFlags().StringP(keytabOpt, "", "", "Kerberos keytab file")
+	RootCmd.PersistentFlags().BoolP(tlsOpt, "", false, "use TLS connection")
+	RootCmd.PersistentFlags().StringP(caCertOpt, "", "", "CA certificates file for TLS")
+	RootCmd.PersistentFlags().StringP(clientCertOpt, "", "", "client certificate file for TLS")
+	RootCmd.PersistentFlags().StringP(clientKeyOpt, "", "", "client key file for TLS")
+	RootCmd.PersistentFlags().StringP(tlsServerNameOpt, "", "", "server name for TLS verification")
+	RootCmd.PersistentFlags().BoolP(tlsInsecureOpt, "", false, "skip TLS server verification")
+	RootCmd.PersistentFlags().StringP(recordOpt, "", "", "record Thrift calls to the file")
+	RootCmd.PersistentFlags().StringP(replayOpt, "", "", "replay Thrift calls from the file")
 
 	// Bind flags to viper variables
 	viper.BindPFlags(RootCmd.PersistentFlags())
@@ -166,6 +260,8 @@ func initConfig() {
 	viper.AddConfigPath("$HOME")       // adding home directory as first search path
 	viper.SetEnvPrefix("sentry")       // All environment vars should start with SENTRY_
 	viper.AutomaticEnv()               // read in environment variables that match
+	// Map options like sasl-mech to environment vars like SENTRY_SASL_MECH
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
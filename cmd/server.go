@@ -0,0 +1,34 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// serverCmd groups commands that query the Sentry server itself
+var serverCmd = &cobra.Command{
+	Use:   "server",
+	Short: "Sentry server information",
+	Long: `Query information about the Sentry server.
+Configuration commands are only supported by the legacy (Hive) model.`,
+	Example: `
+  sentrytool server config get sentry.service.admin.group
+  sentrytool server capabilities --json`,
+}
+
+func init() {
+	RootCmd.AddCommand(serverCmd)
+}
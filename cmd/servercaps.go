@@ -0,0 +1,111 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// serverCapsCmd shows services and calls supported by Sentry servers
+var serverCapsCmd = &cobra.Command{
+	Use:   "capabilities",
+	Short: "show Sentry server capabilities",
+	Long: `Show which Sentry services each server provides, the protocol version used for
+each service and which calls are supported. Every host from --host is checked. The
+report is printed as a JSON object if --json flag is specified.`,
+	Example: `
+  $ sentrytool server capabilities
+  localhost:8038
+    SentryPolicyService: protocol version 2
+      AddGroupsToRole
+      ...
+      ListRoleByUser (unsupported)
+    SentryGenericPolicyService: protocol version 2
+      ...`,
+	RunE: showServerCaps,
+}
+
+func showServerCaps(cmd *cobra.Command, args []string) error {
+	asJSON, _ := cmd.Flags().GetBool(jsonOpt)
+	user := viper.GetString(userOpt)
+	endpoints, err := sentryapi.ParseEndpoints(viper.GetString(hostOpt),
+		viper.GetInt(portOpt))
+	if err != nil {
+		return err
+	}
+	opts, err := getClientOptions(user)
+	if err != nil {
+		return err
+	}
+
+	report := make(map[string]*sentryapi.Capabilities, len(endpoints))
+	for _, e := range endpoints {
+		caps, err := sentryapi.GetCapabilities(e.Host, e.Port,
+			viper.GetString(componentOpt), user, opts...)
+		if err != nil {
+			printError(fmt.Errorf("%s: %v", e, err))
+			return nil
+		}
+		report[e.String()] = caps
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	for _, e := range endpoints {
+		caps := report[e.String()]
+		fmt.Println(e)
+		printServiceCaps(&caps.Policy)
+		printServiceCaps(&caps.Generic)
+	}
+	return nil
+}
+
+// printServiceCaps shows capabilities of a single service
+func printServiceCaps(caps *sentryapi.ServiceCapabilities) {
+	if !caps.Available {
+		fmt.Printf("  %s: unavailable: %s\n", caps.Service, caps.Error)
+		return
+	}
+	fmt.Printf("  %s: protocol version %d\n", caps.Service, caps.ProtocolVersion)
+	operations := make([]string, 0, len(caps.Operations))
+	for op := range caps.Operations {
+		operations = append(operations, op)
+	}
+	sort.Strings(operations)
+	for _, op := range operations {
+		if caps.Operations[op] {
+			fmt.Println("    " + op)
+		} else {
+			fmt.Printf("    %s (unsupported)\n", op)
+		}
+	}
+}
+
+func init() {
+	serverCapsCmd.Flags().BoolP(jsonOpt, "", false, "print report as JSON")
+	serverCmd.AddCommand(serverCapsCmd)
+}
@@ -0,0 +1,100 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	jsonOpt    = "json"
+	defaultOpt = "default"
+)
+
+// serverConfigCmd represents server configuration commands
+var serverConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Sentry server configuration",
+	Long:  `Query Sentry server configuration.`,
+}
+
+// serverConfigGetCmd gets server configuration values
+var serverConfigGetCmd = &cobra.Command{
+	Use:   "get",
+	Short: "get Sentry server configuration values",
+	Long: `Get values of Sentry server configuration properties.
+Arguments are property names. Values are printed as key=value pairs or as a JSON
+object if --json flag is specified.
+
+Properties that are not set are reported with the value of --default flag.`,
+	Example: `
+  $ sentrytool server config get sentry.service.admin.group sentry.service.processor.factories
+  sentry.service.admin.group=hive,impala,hue
+  sentry.service.processor.factories=org.apache.sentry.provider.db.service.thrift.SentryPolicyStoreProcessorFactory
+  $ sentrytool server config get --json sentry.service.admin.group
+  {
+    "sentry.service.admin.group": "hive,impala,hue"
+  }`,
+	RunE: getServerConfig,
+}
+
+func getServerConfig(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing property name")
+	}
+	defaultValue, _ := cmd.Flags().GetString(defaultOpt)
+	asJSON, _ := cmd.Flags().GetBool(jsonOpt)
+
+	client, err := getClient()
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	defer client.Close()
+
+	values := make(map[string]string, len(args))
+	for _, name := range args {
+		value, err := client.GetConfigValue(name, defaultValue)
+		if err != nil {
+			printError(err)
+			return nil
+		}
+		values[name] = value
+	}
+
+	if asJSON {
+		data, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	for _, name := range args {
+		fmt.Printf("%s=%s\n", name, values[name])
+	}
+	return nil
+}
+
+func init() {
+	serverConfigGetCmd.Flags().BoolP(jsonOpt, "", false, "print values as JSON")
+	serverConfigGetCmd.Flags().StringP(defaultOpt, "", "", "value for unset properties")
+	serverConfigCmd.AddCommand(serverConfigGetCmd)
+	serverCmd.AddCommand(serverConfigCmd)
+}
@@ -0,0 +1,53 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// userCmd represents the user command
+var userCmd = &cobra.Command{
+	Use:     "user",
+	Aliases: []string{"u"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		viper.Set(verboseOpt, true)
+		if len(args) == 0 {
+			return fmt.Errorf("missing user name")
+		}
+		return listUsers(cmd, args)
+	},
+	Short: "list, add or remove users",
+	Long: `user command manages Sentry users. A user can be added to a role or removed from a role.
+A single user can belong to multiple roles. Users are only supported by the legacy (Hive) model.
+
+The role is specified with either '-r' flag ro as the first parameter.
+The remaining parameters are user names.
+
+Without subcommands lists roles for the given users.`,
+	Example: `
+  sentrytool user list hive impala
+  sentrytool user grant -r admin_role hive impala
+  sentrytool user grant admin_role etl_user`,
+}
+
+func init() {
+	// ALl user commands operate on a role which can be supplied with -r flag
+	userCmd.PersistentFlags().StringP("role", "r", "", "role name")
+	RootCmd.AddCommand(userCmd)
+}
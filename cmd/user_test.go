@@ -0,0 +1,96 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/akolb1/sentrytool/sentryapi/sentrytest"
+	"github.com/spf13/viper"
+)
+
+// setTestServer points commands to the Sentry server specified by
+// SENTRY_HOST and SENTRY_PORT. Without SENTRY_HOST the in-memory server is
+// used.
+func setTestServer(t *testing.T) {
+	if host := os.Getenv("SENTRY_HOST"); host != "" {
+		viper.Set(hostOpt, host)
+		if port := os.Getenv("SENTRY_PORT"); port != "" {
+			viper.Set(portOpt, port)
+		}
+		return
+	}
+	server, err := sentrytest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	viper.Set(hostOpt, server.Host)
+	viper.Set(portOpt, server.Port)
+}
+
+// runCommand runs sentrytool with the given arguments and returns its output
+func runCommand(t *testing.T, args ...string) string {
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	RootCmd.SetArgs(args)
+	err = RootCmd.Execute()
+	w.Close()
+	os.Stdout = stdout
+	out, _ := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", strings.Join(args, " "), err)
+	}
+	return string(out)
+}
+
+func TestUserCommands(t *testing.T) {
+	setTestServer(t)
+	defer viper.Set(verboseOpt, false)
+	roleName := "sentrytool_test_user_role"
+	userName := "sentrytool_test_user"
+	client, err := getClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.CreateRole(roleName); err != nil {
+		t.Fatal(err)
+	}
+	defer client.RemoveRole(roleName)
+
+	runCommand(t, "user", "grant", roleName, userName)
+	viper.Set(verboseOpt, true)
+	expected := userName + " = " + roleName + "\n"
+	if out := runCommand(t, "user", "list", userName); out != expected {
+		t.Errorf("after grant: expected %q, got %q", expected, out)
+	}
+	viper.Set(verboseOpt, false)
+	runCommand(t, "user", "revoke", roleName, userName)
+	if out := runCommand(t, "user", "list", userName); strings.Contains(out, roleName) {
+		t.Errorf("after revoke: unexpected output %q", out)
+	}
+	RootCmd.SetArgs([]string{"user", "grant", roleName})
+	if err := RootCmd.Execute(); err == nil {
+		t.Error("user grant without users: expected error")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// userAddCmd represents the user grant command
+var userAddCmd = &cobra.Command{
+	Use:     "grant",
+	Aliases: []string{"add", "create"},
+	RunE:    addUsersToRole,
+	Short:   "grant user to a role",
+	Long: `Grant command associates user with a specific role.
+A role should be either specified with -role flag or be the first argument
+followed by list of users.
+
+If -role flag is specified, arguments are user names to add.`,
+
+	Example: `
+  # Grant user to a role
+  sentrytool user grant -r admin_role hive impala
+  sentrytool user grant admin_role etl_user
+
+  # Revoke user from role
+  sentrytool user revoke -r admin_role hive`,
+}
+
+// addUsersToRole adds a set of users to the specific role
+func addUsersToRole(cmd *cobra.Command, args []string) error {
+	// Get role name
+	roleName, _ := cmd.Flags().GetString("role")
+	if len(args) == 0 || (roleName == "" && len(args) == 1) {
+		return errors.New("missing user name")
+	}
+
+	users := args
+	if roleName == "" {
+		roleName = args[0]
+		users = args[1:]
+	}
+
+	// Get Thrift client
+	client, err := getClient()
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	defer client.Close()
+
+	// Verify that roleName is valid
+	isValid, err := isValidRole(client, roleName)
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	if !isValid {
+		return fmt.Errorf("role %s doesn't exist", roleName)
+	}
+
+	// Add users to the role
+	if err = client.AddUsersToRole(roleName, users); err != nil {
+		printError(err)
+		return nil
+	}
+
+	verbose := viper.GetBool(verboseOpt)
+	if verbose {
+		listUsers(cmd, users)
+	}
+
+	return nil
+}
+
+func init() {
+	userCmd.AddCommand(userAddCmd)
+}
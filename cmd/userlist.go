@@ -0,0 +1,85 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// userListCmd manages listing of users
+var userListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "list roles for users",
+	RunE:    listUsers,
+	Long: `List roles for users specified in the command line.
+Sentry can't enumerate users, so at least one user should be specified.
+Without -v flag, just prints users that have any roles. If '-v' flag is provided,
+prints user to roles mapping.
+`,
+	Example: `
+  sentrytool user list -v hive impala
+  hive = admin
+  impala = admin, customer`,
+}
+
+// listUsers displays users and their associated roles
+func listUsers(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing user name")
+	}
+
+	client, err := getClient()
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	defer client.Close()
+
+	users := make([]string, len(args))
+	copy(users, args)
+	sort.Strings(users)
+
+	// Display all users
+	verbose := viper.GetBool(verboseOpt)
+	for _, user := range users {
+		roles, _, err := client.ListRoleByUser(user)
+		if err != nil {
+			printError(err)
+			return nil
+		}
+		if len(roles) == 0 {
+			continue
+		}
+		sort.Strings(roles)
+		if verbose {
+			fmt.Println(user, "=", strings.Join(roles, ", "))
+		} else {
+			fmt.Println(user)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	userCmd.AddCommand(userListCmd)
+}
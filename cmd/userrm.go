@@ -0,0 +1,86 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// userRemoveCmd represents the user revoke command
+var userRemoveCmd = &cobra.Command{
+	Use:     "revoke",
+	Aliases: []string{"remove", "delete", "rm"},
+	Short:   "Remove user from a role",
+	Long: `Remove user from a role.
+A role should be either specified with -role flag or be the first argument
+followed by list of users.
+
+If role is specified with -role flag, arguments are user names to remove.`,
+	Example: `
+  user revoke admin_role hive impala
+  user revoke -r admin_role hive impala`,
+	RunE: removeUserFromRole,
+}
+
+func removeUserFromRole(cmd *cobra.Command, args []string) error {
+	roleName, _ := cmd.Flags().GetString("role")
+	if len(args) == 0 || (roleName == "" && len(args) == 1) {
+		return errors.New("missing user name(s)")
+	}
+
+	users := args
+	if roleName == "" {
+		roleName = args[0]
+		users = args[1:]
+	}
+
+	client, err := getClient()
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	defer client.Close()
+
+	// Verify that roleName is valid
+	isValid, err := isValidRole(client, roleName)
+	if err != nil {
+		printError(err)
+		return nil
+	}
+	if !isValid {
+		return fmt.Errorf("role %s doesn't exist", roleName)
+	}
+
+	// Remove users from the role
+	if err = client.RemoveUsersFromRole(roleName, users); err != nil {
+		printError(err)
+		return nil
+	}
+
+	verbose := viper.GetBool(verboseOpt)
+	if verbose {
+		fmt.Println("removed users from role", roleName)
+	}
+
+	return nil
+}
+
+func init() {
+	userCmd.AddCommand(userRemoveCmd)
+}
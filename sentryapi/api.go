@@ -14,7 +14,10 @@
 
 package sentryapi
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // ProtocolType is enum describing available Apache Sentry protocols. Currently Sentry supports
 // two protocols: old protocol and generic protocol.
@@ -22,9 +25,14 @@ type ProtocolType int
 
 // PolicyProtocol is the legacy Sentry protocol
 // GenericPolicyProtocol is the generic Sentry protocol
+// AutoProtocol probes which services the server provides on the first
+// connection to the server. It uses the generic protocol when component is
+// specified and the legacy protocol otherwise, reporting which services the
+// server provides if the required one is missing
 const (
 	PolicyProtocol ProtocolType = iota
 	GenericPolicyProtocol
+	AutoProtocol
 )
 
 const (
@@ -39,32 +47,48 @@ func (pt ProtocolType) String() string {
 	if pt == GenericPolicyProtocol {
 		return sentryGenericProtocol
 	}
+	if pt == AutoProtocol {
+		return "auto"
+	}
 	return "unknownProtocol"
 }
 
 // Role is a representation of Sentry role. Each role has a name and a
-// list of groups associated with the role.
+// list of groups and users associated with the role.
 // Attributes:
 //   Name - Role name
 //   Groups - list of groups for the role
+//   Users - list of users for the role
 type Role struct {
 	Name   string
 	Groups []string
+	Users  []string
+}
+
+// Authorizable is a single element of the generic model privilege hierarchy,
+// e.g. topic=clicks for Kafka or collection=logs for Solr.
+type Authorizable struct {
+	Type string `json:"type" yaml:"type"`
+	Name string `json:"name" yaml:"name"`
 }
 
 // Privilege is the Sentry privilege representation. It comboines
-// Generic model and legacy Hive model
+// Generic model and legacy Hive model. Generic model privileges use
+// Authorizables for the privilege hierarchy.
 type Privilege struct {
-	Scope            string
-	Server           string
-	Database         string
-	Table            string
-	Column           string
-	URI              string
-	Action           string
-	Service          string
-	GrantOption      bool
-	UnsetGrantOption bool // True is grant option is unset
+	Scope            string         `json:"scope,omitempty" yaml:"scope,omitempty"`
+	Server           string         `json:"server,omitempty" yaml:"server,omitempty"`
+	Database         string         `json:"database,omitempty" yaml:"database,omitempty"`
+	Table            string         `json:"table,omitempty" yaml:"table,omitempty"`
+	Column           string         `json:"column,omitempty" yaml:"column,omitempty"`
+	URI              string         `json:"uri,omitempty" yaml:"uri,omitempty"`
+	Action           string         `json:"action,omitempty" yaml:"action,omitempty"`
+	Service          string         `json:"service,omitempty" yaml:"service,omitempty"`
+	Authorizables    []Authorizable `json:"authorizables,omitempty" yaml:"authorizables,omitempty"`
+	Grantor          string         `json:"grantor,omitempty" yaml:"grantor,omitempty"`
+	CreateTime       int64          `json:"createTime,omitempty" yaml:"createTime,omitempty"`
+	GrantOption      bool           `json:"grantOption,omitempty" yaml:"grantOption,omitempty"`
+	UnsetGrantOption bool           `json:"-" yaml:"-"` // True is grant option is unset
 }
 
 // ClientAPI is a generic Apache Sentry client interface.
@@ -90,6 +114,18 @@ type ClientAPI interface {
 	//   roleName - role name
 	//   groups - list of group names to remove
 	RemoveGroupsFromRole(roleName string, groups []string) error
+	// ListRoleByUser returns list of role names for a given user. Sentry
+	// doesn't report role users, so Users of the returned roles are nil.
+	//   userName - user name
+	ListRoleByUser(userName string) ([]string, []*Role, error)
+	// AddUsersToRole adds specified users to the role
+	//   roleName - role name
+	//   users - list of user names to add
+	AddUsersToRole(roleName string, users []string) error
+	// RemoveUsersFromRole removes specified users from the role
+	//   roleName - role name
+	//   users - list of user names to remove
+	RemoveUsersFromRole(roleName string, users []string) error
 	// GrantPrivilege grants privilege to the role
 	//   roleName - role name
 	//   priv - privilege to grant
@@ -102,6 +138,52 @@ type ClientAPI interface {
 	// role.
 	// If template is not NULL, only return privileges matching template
 	ListPrivilegesByRole(roleName string, template *Privilege) ([]*Privilege, error)
+	// DropPrivilegesOnObject removes all privileges on the object from all
+	// roles. Only the object part of the privilege (server, database, table,
+	// column, URI or service) is used.
+	//   object - object which privileges should be dropped
+	DropPrivilegesOnObject(object *Privilege) error
+	// RenamePrivilegesOnObject moves all privileges on the object to a new
+	// object for all roles.
+	//   from - old object
+	//   to - new object
+	RenamePrivilegesOnObject(from *Privilege, to *Privilege) error
+	// ListPrivilegesByObject returns privileges on the object for all roles.
+	// Result is a map from role name to the list of role privileges.
+	//   object - object to check (only the object part of privilege is used)
+	//   groups - if not empty, only return roles granted to these groups
+	ListPrivilegesByObject(object *Privilege,
+		groups []string) (map[string][]*Privilege, error)
+	// EffectivePrivileges returns privileges available to the given groups
+	// and users in Sentry provider format (e.g.
+	// "server=server1->db=db1->table=t1->action=select").
+	//   groups - list of groups
+	//   users - list of users
+	//   activeRoles - list of active roles, all roles are active if empty
+	//   object - if not nil, only return privileges for the object hierarchy.
+	//            The generic model requires the object with the service.
+	EffectivePrivileges(groups []string, users []string, activeRoles []string,
+		object *Privilege) ([]string, error)
+	// GetConfigValue returns the value of Sentry server configuration
+	// property.
+	//   name - property name
+	//   defaultValue - value returned if property is not set
+	GetConfigValue(name string, defaultValue string) (string, error)
+}
+
+// PolicyIO is implemented by legacy model clients returned by GetClient().
+// Clients wrapped with failover, pooling or interceptors don't implement it.
+type PolicyIO interface {
+	// ExportPolicy returns the full policy: group, user and privilege
+	// mappings for all roles. Role and privilege lists are sorted.
+	//   objectPath - if not empty, only export privileges for the object
+	//                specified as "db=name->table=name"
+	ExportPolicy(objectPath string) (*Policy, error)
+	// ImportPolicy imports the policy into Sentry
+	//   policy - policy to import
+	//   overwrite - if true, replace privileges of existing roles with
+	//               imported ones, otherwise merge them
+	ImportPolicy(policy *Policy, overwrite bool) error
 }
 
 // GetClient returns a Sentry client implementation
@@ -110,21 +192,38 @@ type ClientAPI interface {
 //   port - server port
 //   component - Sentry component for generic protocol
 //   user - Sentry authorization user
+//   opts - optional client settings, e.g. WithSASL()
 func GetClient(protocol ProtocolType, host string, port int,
-	component string, user string) (ClientAPI, error) {
+	component string, user string, opts ...ClientOption) (ClientAPI, error) {
+	options := newClientOptions(opts)
 	switch protocol {
 	case PolicyProtocol:
-		return getHiveClient(host, port, user)
+		client, err := getHiveClient(host, port, user, options)
+		if err != nil {
+			return nil, err
+		}
+		return newInterceptedClient(client, Endpoint{Host: host, Port: port},
+			options.interceptors), nil
 	case GenericPolicyProtocol:
-		return getGenericClient(host, port, component, user)
+		client, err := getGenericClient(host, port, component, user, options)
+		if err != nil {
+			return nil, err
+		}
+		return newInterceptedClient(client, Endpoint{Host: host, Port: port},
+			options.interceptors), nil
+	case AutoProtocol:
+		return getAutoClient(host, port, component, user, opts)
 	default:
 		return nil, fmt.Errorf("invalid protocol %s", protocol.String())
 	}
 }
 
-// APIError is an extension to error type that also contains source stack trace.
+// APIError is an error reported by Sentry server. Besides the message it
+// contains the Sentry status code and the source stack trace. Use errors.Is()
+// with ErrAlreadyExists, ErrNoSuchObject, etc. to check the status.
 type APIError struct {
 	Err        error
+	Code       int32
 	StackTrace string
 }
 
@@ -133,13 +232,14 @@ func (err *APIError) Error() string {
 }
 
 // newApiError returns an initialized instance of ApiError.
-func newAPIError(err error, stackP *string) *APIError {
+func newAPIError(code int32, message string, stackP *string) *APIError {
 	var stack string
 	if stackP != nil {
 		stack = *stackP
 	}
 	return &APIError{
-		Err:        err,
+		Err:        errors.New(message),
+		Code:       code,
 		StackTrace: stack,
 	}
 }
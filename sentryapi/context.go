@@ -0,0 +1,298 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"context"
+	"errors"
+)
+
+// ContextClientAPI extends ClientAPI with variants of every operation that
+// accept a context. When the context is cancelled or its deadline expires
+// before the call completes, the pending call is aborted and the context
+// error is returned. The connection is broken after that, so the client
+// should be closed.
+type ContextClientAPI interface {
+	ClientAPI
+	// CreateRoleContext is CreateRole with a context
+	CreateRoleContext(ctx context.Context, roleName string) error
+	// RemoveRoleContext is RemoveRole with a context
+	RemoveRoleContext(ctx context.Context, roleName string) error
+	// ListRoleByGroupContext is ListRoleByGroup with a context
+	ListRoleByGroupContext(ctx context.Context,
+		groupName string) ([]string, []*Role, error)
+	// AddGroupsToRoleContext is AddGroupsToRole with a context
+	AddGroupsToRoleContext(ctx context.Context, roleName string,
+		groups []string) error
+	// RemoveGroupsFromRoleContext is RemoveGroupsFromRole with a context
+	RemoveGroupsFromRoleContext(ctx context.Context, roleName string,
+		groups []string) error
+	// ListRoleByUserContext is ListRoleByUser with a context
+	ListRoleByUserContext(ctx context.Context,
+		userName string) ([]string, []*Role, error)
+	// AddUsersToRoleContext is AddUsersToRole with a context
+	AddUsersToRoleContext(ctx context.Context, roleName string,
+		users []string) error
+	// RemoveUsersFromRoleContext is RemoveUsersFromRole with a context
+	RemoveUsersFromRoleContext(ctx context.Context, roleName string,
+		users []string) error
+	// GrantPrivilegeContext is GrantPrivilege with a context
+	GrantPrivilegeContext(ctx context.Context, roleName string,
+		priv *Privilege) error
+	// RevokePrivilegeContext is RevokePrivilege with a context
+	RevokePrivilegeContext(ctx context.Context, roleName string,
+		priv *Privilege) error
+	// ListPrivilegesByRoleContext is ListPrivilegesByRole with a context
+	ListPrivilegesByRoleContext(ctx context.Context, roleName string,
+		template *Privilege) ([]*Privilege, error)
+	// DropPrivilegesOnObjectContext is DropPrivilegesOnObject with a context
+	DropPrivilegesOnObjectContext(ctx context.Context, object *Privilege) error
+	// RenamePrivilegesOnObjectContext is RenamePrivilegesOnObject with a
+	// context
+	RenamePrivilegesOnObjectContext(ctx context.Context, from *Privilege,
+		to *Privilege) error
+	// ListPrivilegesByObjectContext is ListPrivilegesByObject with a context
+	ListPrivilegesByObjectContext(ctx context.Context, object *Privilege,
+		groups []string) (map[string][]*Privilege, error)
+	// EffectivePrivilegesContext is EffectivePrivileges with a context
+	EffectivePrivilegesContext(ctx context.Context, groups []string,
+		users []string, activeRoles []string, object *Privilege) ([]string, error)
+	// GetConfigValueContext is GetConfigValue with a context
+	GetConfigValueContext(ctx context.Context, name string,
+		defaultValue string) (string, error)
+}
+
+// ErrNotCancelable is returned by NewContextClient() for clients which can't
+// abort a single call, e.g. pooled or HA clients
+var ErrNotCancelable = errors.New("client calls can't be cancelled")
+
+// canceler is implemented by clients which can abort the pending call
+// from another goroutine
+type canceler interface {
+	cancel()
+}
+
+// contextClient implements ContextClientAPI on top of any ClientAPI
+type contextClient struct {
+	ClientAPI
+	canceler canceler
+}
+
+// NewContextClient returns ContextClientAPI wrapping the client returned by
+// GetClient(). Cancellation aborts the pending call on the client connection.
+// Pooled and HA clients share several connections, so they are rejected with
+// ErrNotCancelable.
+func NewContextClient(client ClientAPI) (ContextClientAPI, error) {
+	if c, ok := client.(ContextClientAPI); ok {
+		return c, nil
+	}
+	c, ok := client.(canceler)
+	if !ok {
+		return nil, ErrNotCancelable
+	}
+	return &contextClient{ClientAPI: client, canceler: c}, nil
+}
+
+// GetClientContext is GetClient that gives up connecting when the context
+// is done.
+func GetClientContext(ctx context.Context, protocol ProtocolType, host string,
+	port int, component string, user string,
+	opts ...ClientOption) (ContextClientAPI, error) {
+	type result struct {
+		client ClientAPI
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		client, err := GetClient(protocol, host, port, component, user, opts...)
+		done <- result{client, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, r.err
+		}
+		return NewContextClient(r.client)
+	case <-ctx.Done():
+		// Close the client if connection succeeds after all
+		go func() {
+			if r := <-done; r.err == nil {
+				r.client.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// run executes the call, aborting it if context is done before the call
+// completes.
+func (c *contextClient) run(ctx context.Context, call func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- call() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		c.canceler.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// CreateRoleContext implements ContextClientAPI.CreateRoleContext()
+func (c *contextClient) CreateRoleContext(ctx context.Context,
+	roleName string) error {
+	return c.run(ctx, func() error { return c.CreateRole(roleName) })
+}
+
+// RemoveRoleContext implements ContextClientAPI.RemoveRoleContext()
+func (c *contextClient) RemoveRoleContext(ctx context.Context,
+	roleName string) error {
+	return c.run(ctx, func() error { return c.RemoveRole(roleName) })
+}
+
+// ListRoleByGroupContext implements ContextClientAPI.ListRoleByGroupContext()
+func (c *contextClient) ListRoleByGroupContext(ctx context.Context,
+	groupName string) ([]string, []*Role, error) {
+	var names []string
+	var roles []*Role
+	err := c.run(ctx, func() (err error) {
+		names, roles, err = c.ListRoleByGroup(groupName)
+		return err
+	})
+	return names, roles, err
+}
+
+// AddGroupsToRoleContext implements ContextClientAPI.AddGroupsToRoleContext()
+func (c *contextClient) AddGroupsToRoleContext(ctx context.Context,
+	roleName string, groups []string) error {
+	return c.run(ctx, func() error { return c.AddGroupsToRole(roleName, groups) })
+}
+
+// RemoveGroupsFromRoleContext implements
+// ContextClientAPI.RemoveGroupsFromRoleContext()
+func (c *contextClient) RemoveGroupsFromRoleContext(ctx context.Context,
+	roleName string, groups []string) error {
+	return c.run(ctx, func() error {
+		return c.RemoveGroupsFromRole(roleName, groups)
+	})
+}
+
+// ListRoleByUserContext implements ContextClientAPI.ListRoleByUserContext()
+func (c *contextClient) ListRoleByUserContext(ctx context.Context,
+	userName string) ([]string, []*Role, error) {
+	var names []string
+	var roles []*Role
+	err := c.run(ctx, func() (err error) {
+		names, roles, err = c.ListRoleByUser(userName)
+		return err
+	})
+	return names, roles, err
+}
+
+// AddUsersToRoleContext implements ContextClientAPI.AddUsersToRoleContext()
+func (c *contextClient) AddUsersToRoleContext(ctx context.Context,
+	roleName string, users []string) error {
+	return c.run(ctx, func() error { return c.AddUsersToRole(roleName, users) })
+}
+
+// RemoveUsersFromRoleContext implements
+// ContextClientAPI.RemoveUsersFromRoleContext()
+func (c *contextClient) RemoveUsersFromRoleContext(ctx context.Context,
+	roleName string, users []string) error {
+	return c.run(ctx, func() error {
+		return c.RemoveUsersFromRole(roleName, users)
+	})
+}
+
+// GrantPrivilegeContext implements ContextClientAPI.GrantPrivilegeContext()
+func (c *contextClient) GrantPrivilegeContext(ctx context.Context,
+	roleName string, priv *Privilege) error {
+	return c.run(ctx, func() error { return c.GrantPrivilege(roleName, priv) })
+}
+
+// RevokePrivilegeContext implements ContextClientAPI.RevokePrivilegeContext()
+func (c *contextClient) RevokePrivilegeContext(ctx context.Context,
+	roleName string, priv *Privilege) error {
+	return c.run(ctx, func() error { return c.RevokePrivilege(roleName, priv) })
+}
+
+// ListPrivilegesByRoleContext implements
+// ContextClientAPI.ListPrivilegesByRoleContext()
+func (c *contextClient) ListPrivilegesByRoleContext(ctx context.Context,
+	roleName string, template *Privilege) ([]*Privilege, error) {
+	var privileges []*Privilege
+	err := c.run(ctx, func() (err error) {
+		privileges, err = c.ListPrivilegesByRole(roleName, template)
+		return err
+	})
+	return privileges, err
+}
+
+// DropPrivilegesOnObjectContext implements
+// ContextClientAPI.DropPrivilegesOnObjectContext()
+func (c *contextClient) DropPrivilegesOnObjectContext(ctx context.Context,
+	object *Privilege) error {
+	return c.run(ctx, func() error { return c.DropPrivilegesOnObject(object) })
+}
+
+// RenamePrivilegesOnObjectContext implements
+// ContextClientAPI.RenamePrivilegesOnObjectContext()
+func (c *contextClient) RenamePrivilegesOnObjectContext(ctx context.Context,
+	from *Privilege, to *Privilege) error {
+	return c.run(ctx, func() error {
+		return c.RenamePrivilegesOnObject(from, to)
+	})
+}
+
+// ListPrivilegesByObjectContext implements
+// ContextClientAPI.ListPrivilegesByObjectContext()
+func (c *contextClient) ListPrivilegesByObjectContext(ctx context.Context,
+	object *Privilege, groups []string) (map[string][]*Privilege, error) {
+	var privileges map[string][]*Privilege
+	err := c.run(ctx, func() (err error) {
+		privileges, err = c.ListPrivilegesByObject(object, groups)
+		return err
+	})
+	return privileges, err
+}
+
+// EffectivePrivilegesContext implements
+// ContextClientAPI.EffectivePrivilegesContext()
+func (c *contextClient) EffectivePrivilegesContext(ctx context.Context,
+	groups []string, users []string, activeRoles []string,
+	object *Privilege) ([]string, error) {
+	var privileges []string
+	err := c.run(ctx, func() (err error) {
+		privileges, err = c.EffectivePrivileges(groups, users, activeRoles,
+			object)
+		return err
+	})
+	return privileges, err
+}
+
+// GetConfigValueContext implements ContextClientAPI.GetConfigValueContext()
+func (c *contextClient) GetConfigValueContext(ctx context.Context,
+	name string, defaultValue string) (string, error) {
+	var value string
+	err := c.run(ctx, func() (err error) {
+		value, err = c.GetConfigValue(name, defaultValue)
+		return err
+	})
+	return value, err
+}
@@ -0,0 +1,156 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// blockingClient is ClientAPI that blocks every CreateRole call until the
+// call is cancelled
+type blockingClient struct {
+	ClientAPI
+	cancelled chan struct{}
+}
+
+func (c *blockingClient) cancel() {
+	close(c.cancelled)
+}
+
+func (c *blockingClient) CreateRole(roleName string) error {
+	<-c.cancelled
+	return errors.New("transport closed")
+}
+
+func TestContextClient_Cancel(t *testing.T) {
+	blocking := &blockingClient{cancelled: make(chan struct{})}
+	client, err := NewContextClient(blocking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+	err = client.CreateRoleContext(ctx, "role")
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	select {
+	case <-blocking.cancelled:
+	default:
+		t.Error("call wasn't cancelled")
+	}
+}
+
+func TestContextClient_Cancelled(t *testing.T) {
+	blocking := &blockingClient{cancelled: make(chan struct{})}
+	client, err := NewContextClient(blocking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.CreateRoleContext(ctx, "role"); err != context.Canceled {
+		t.Errorf("expected context cancelled, got %v", err)
+	}
+}
+
+func TestContextClient_CancelConnection(t *testing.T) {
+	// Server accepts connection but never replies
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	leaf, err := GetClient(PolicyProtocol, "127.0.0.1",
+		listener.Addr().(*net.TCPAddr).Port, "", "admin",
+		WithProtocolVersion(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leaf.Close()
+	client, err := NewContextClient(leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := client.CreateRoleContext(ctx, "role"); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("cancellation took %s", elapsed)
+	}
+	if !clientBroken(leaf) {
+		t.Error("connection isn't broken after cancellation")
+	}
+}
+
+func TestContextClient_NotCancelable(t *testing.T) {
+	pool, err := NewPooledClient(PolicyProtocol,
+		[]Endpoint{{Host: "127.0.0.1", Port: 1}}, "", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	if _, err := NewContextClient(pool); err != ErrNotCancelable {
+		t.Errorf("expected ErrNotCancelable, got %v", err)
+	}
+}
+
+func TestTransport_Timeout(t *testing.T) {
+	// Server accepts connection but never replies
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	options := newClientOptions([]ClientOption{
+		WithConnectTimeout(time.Second),
+		WithTimeout(50 * time.Millisecond)})
+	transport, err := openTransport("127.0.0.1",
+		listener.Addr().(*net.TCPAddr).Port, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+	start := time.Now()
+	if _, err := transport.Read(make([]byte, 1)); err == nil {
+		t.Error("expected read timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("read took %s despite timeout", elapsed)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_common_service"
+)
+
+// Sentinel errors matching Sentry status codes. Use errors.Is() to check
+// whether an error returned by ClientAPI has the status.
+var (
+	ErrAlreadyExists         = errors.New("already exists")
+	ErrNoSuchObject          = errors.New("no such object")
+	ErrRuntime               = errors.New("server runtime error")
+	ErrInvalidInput          = errors.New("invalid input")
+	ErrAccessDenied          = errors.New("access denied")
+	ErrThriftVersionMismatch = errors.New("thrift version mismatch")
+)
+
+// ErrNotSupported is returned for operations the service doesn't provide,
+// e.g. user operations of the generic service.
+var ErrNotSupported = errors.New("not supported")
+
+// statusErrors maps Sentry status codes to sentinel errors
+var statusErrors = map[int32]error{
+	sentry_common_service.TSENTRY_STATUS_ALREADY_EXISTS:          ErrAlreadyExists,
+	sentry_common_service.TSENTRY_STATUS_NO_SUCH_OBJECT:          ErrNoSuchObject,
+	sentry_common_service.TSENTRY_STATUS_RUNTIME_ERROR:           ErrRuntime,
+	sentry_common_service.TSENTRY_STATUS_INVALID_INPUT:           ErrInvalidInput,
+	sentry_common_service.TSENTRY_STATUS_ACCESS_DENIED:           ErrAccessDenied,
+	sentry_common_service.TSENTRY_STATUS_THRIFT_VERSION_MISMATCH: ErrThriftVersionMismatch,
+}
+
+// Is reports whether the error status matches the sentinel error
+func (err *APIError) Is(target error) bool {
+	statusErr, ok := statusErrors[err.Code]
+	return ok && statusErr == target
+}
+
+// TransportError is an error communicating with Sentry server, as opposed
+// to APIError which is reported by the server itself.
+type TransportError struct {
+	Message string
+	Err     error
+}
+
+func (err *TransportError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Message, err.Err)
+}
+
+// Unwrap returns the underlying Thrift error
+func (err *TransportError) Unwrap() error {
+	return err.Err
+}
+
+// newTransportError wraps Thrift error with formatted message
+func newTransportError(err error, format string,
+	args ...interface{}) *TransportError {
+	return &TransportError{
+		Message: fmt.Sprintf(format, args...),
+		Err:     err,
+	}
+}
+
+// IsTransportError returns true if the error is a TransportError
+func IsTransportError(err error) bool {
+	var transportErr *TransportError
+	return errors.As(err, &transportErr)
+}
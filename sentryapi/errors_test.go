@@ -0,0 +1,62 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_common_service"
+)
+
+func TestAPIError_Is(t *testing.T) {
+	err := error(newAPIError(sentry_common_service.TSENTRY_STATUS_ALREADY_EXISTS,
+		"role r1 already exists", nil))
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Error("expected ErrAlreadyExists")
+	}
+	if errors.Is(err, ErrNoSuchObject) {
+		t.Error("unexpected ErrNoSuchObject")
+	}
+	if err.Error() != "role r1 already exists" {
+		t.Errorf("unexpected message %s", err)
+	}
+	wrapped := fmt.Errorf("grant failed: %w",
+		newAPIError(sentry_common_service.TSENTRY_STATUS_ACCESS_DENIED, "denied", nil))
+	if !errors.Is(wrapped, ErrAccessDenied) {
+		t.Error("expected ErrAccessDenied for wrapped error")
+	}
+	if IsTransportError(wrapped) {
+		t.Error("server error reported as transport error")
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	err := error(newTransportError(io.EOF, "failed to create Sentry role %s", "r1"))
+	if !IsTransportError(err) {
+		t.Error("expected transport error")
+	}
+	if !errors.Is(err, io.EOF) {
+		t.Error("expected wrapped io.EOF")
+	}
+	if errors.Is(err, ErrNoSuchObject) {
+		t.Error("unexpected ErrNoSuchObject")
+	}
+	if err.Error() != "failed to create Sentry role r1: EOF" {
+		t.Errorf("unexpected message %s", err)
+	}
+}
@@ -16,13 +16,16 @@ package sentryapi
 
 import (
 	"fmt"
-
-	"errors"
+	"sort"
+	"strings"
 
 	"git.apache.org/thrift.git/lib/go/thrift"
 	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_generic_policy_service"
 )
 
+// allAction is the generic model action matching all actions
+const allAction = "*"
+
 // TMPGenericProtocolFactory is a multiplexing protocol factory
 type tMPGenericProtocolFactory struct {
 }
@@ -33,65 +36,91 @@ func (p *tMPGenericProtocolFactory) GetProtocol(t thrift.TTransport) thrift.TPro
 }
 
 type genericSentryClient struct {
-	component string
-	userName  string
-	transport thrift.TTransport
-	client    *sentry_generic_policy_service.SentryGenericPolicyServiceClient
+	component       string
+	userName        string
+	protocolVersion int32
+	transport       thrift.TTransport
+	client          *sentry_generic_policy_service.SentryGenericPolicyServiceClient
 }
 
 func (c *genericSentryClient) Close() {
 	c.transport.Close()
 }
 
-func getGenericClient(host string, port int, component string, user string) (*genericSentryClient, error) {
-	socket, err := thrift.NewTSocket(fmt.Sprintf("%s:%d", host, port))
+// broken returns true if the client connection failed
+func (c *genericSentryClient) broken() bool {
+	return transportBroken(c.transport)
+}
+
+// cancel aborts the pending call, the client can't be used after that
+func (c *genericSentryClient) cancel() {
+	cancelTransport(c.transport)
+}
+
+func getGenericClient(host string, port int, component string, user string,
+	options *clientOptions) (*genericSentryClient, error) {
+	transport, err := openTransport(host, port, options)
 	if err != nil {
 		return nil, err
 	}
-	var transport thrift.TTransport = thrift.NewTBufferedTransport(socket, 1024)
 	protocolFactory := &tMPGenericProtocolFactory{}
 	client := sentry_generic_policy_service.NewSentryGenericPolicyServiceClientFactory(transport,
 		protocolFactory)
-	if err := transport.Open(); err != nil {
-		return nil, err
-	}
-	return &genericSentryClient{
+	c := &genericSentryClient{
 		userName:  user,
 		transport: transport,
 		client:    client,
 		component: component,
-	}, nil
+	}
+	if err := negotiateVersion(c, options,
+		versionKey(GenericPolicyProtocol, host, port)); err != nil {
+		transport.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// setProtocolVersion sets protocol version used for requests
+func (c *genericSentryClient) setProtocolVersion(version int32) {
+	c.protocolVersion = version
+}
+
+// version returns protocol version used for requests
+func (c *genericSentryClient) version() int32 {
+	return c.protocolVersion
 }
 
 func (c *genericSentryClient) CreateRole(name string) error {
 	arg := sentry_generic_policy_service.NewTCreateSentryRoleRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.Component = c.component
 	arg.RoleName = name
 	result, err := c.client.CreateSentryRole(arg)
 	if err != nil {
-		return fmt.Errorf("failed to create Sentry role %s: %s", name, err)
+		return newTransportError(err, "failed to create Sentry role %s", name)
 	}
 
 	if result.GetStatus().Value != 0 {
-		return newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 	return nil
 }
 
 func (c *genericSentryClient) RemoveRole(name string) error {
 	arg := sentry_generic_policy_service.NewTDropSentryRoleRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.Component = c.component
 	arg.RoleName = name
 	result, err := c.client.DropSentryRole(arg)
 	if err != nil {
-		return fmt.Errorf("failed to remove Sentry role %s: %s", name, err)
+		return newTransportError(err, "failed to remove Sentry role %s", name)
 	}
 	if result.GetStatus().Value != 0 {
-		return newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 	return nil
 }
@@ -99,6 +128,7 @@ func (c *genericSentryClient) RemoveRole(name string) error {
 func (c *genericSentryClient) ListRoleByGroup(group string) ([]string,
 	[]*Role, error) {
 	arg := sentry_generic_policy_service.NewTListSentryRolesRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	if group == "" {
 		arg.GroupName = nil
 	} else {
@@ -109,12 +139,12 @@ func (c *genericSentryClient) ListRoleByGroup(group string) ([]string,
 	arg.Component = c.component
 	result, err := c.client.ListSentryRolesByGroup(arg)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to list Sentry roles: %s", err)
+		return nil, nil, newTransportError(err, "failed to list Sentry roles")
 	}
 
 	if result.GetStatus().Value != 0 {
-		return nil, nil, newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return nil, nil, newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 	roleNames := make([]string, 0, 8)
 	roles := make([]*Role, 0, 8)
@@ -137,6 +167,7 @@ func (c *genericSentryClient) ListRoleByGroup(group string) ([]string,
 
 func (c *genericSentryClient) AddGroupsToRole(role string, groups []string) error {
 	arg := sentry_generic_policy_service.NewTAlterSentryRoleAddGroupsRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.RoleName = role
 	arg.Component = c.component
@@ -148,11 +179,11 @@ func (c *genericSentryClient) AddGroupsToRole(role string, groups []string) erro
 	arg.Groups = groupsMap
 	result, err := c.client.AlterSentryRoleAddGroups(arg)
 	if err != nil {
-		return fmt.Errorf("failed to add groups: %s", err)
+		return newTransportError(err, "failed to add groups")
 	}
 	if result.GetStatus().Value != 0 {
-		return newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 
 	return nil
@@ -160,6 +191,7 @@ func (c *genericSentryClient) AddGroupsToRole(role string, groups []string) erro
 
 func (c *genericSentryClient) RemoveGroupsFromRole(role string, groups []string) error {
 	arg := sentry_generic_policy_service.NewTAlterSentryRoleDeleteGroupsRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.RoleName = role
 	arg.Component = c.component
@@ -171,18 +203,41 @@ func (c *genericSentryClient) RemoveGroupsFromRole(role string, groups []string)
 	arg.Groups = groupsMap
 	result, err := c.client.AlterSentryRoleDeleteGroups(arg)
 	if err != nil {
-		return fmt.Errorf("failed to remove groups: %s", err)
+		return newTransportError(err, "failed to remove groups")
 	}
 	if result.GetStatus().Value != 0 {
-		return newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 
 	return nil
 }
 
+// notSupported returns error wrapping ErrNotSupported for the operation
+func notSupported(operation string) error {
+	return fmt.Errorf("%s is %w for generic service", operation, ErrNotSupported)
+}
+
+// ListRoleByUser is not supported by the generic service which only
+// associates roles with groups.
+func (c *genericSentryClient) ListRoleByUser(user string) ([]string,
+	[]*Role, error) {
+	return nil, nil, notSupported("ListRoleByUser")
+}
+
+// AddUsersToRole is not supported by the generic service
+func (c *genericSentryClient) AddUsersToRole(role string, users []string) error {
+	return notSupported("AddUsersToRole")
+}
+
+// RemoveUsersFromRole is not supported by the generic service
+func (c *genericSentryClient) RemoveUsersFromRole(role string, users []string) error {
+	return notSupported("RemoveUsersFromRole")
+}
+
 func (c *genericSentryClient) GrantPrivilege(role string, priv *Privilege) error {
 	arg := sentry_generic_policy_service.NewTAlterSentryRoleGrantPrivilegeRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.RoleName = role
 	arg.Component = c.component
@@ -191,6 +246,7 @@ func (c *genericSentryClient) GrantPrivilege(role string, priv *Privilege) error
 	tPrivilege.Action = priv.Action
 	tPrivilege.Component = c.component
 	tPrivilege.ServiceName = priv.Service
+	tPrivilege.Authorizables = toTAuthorizables(priv)
 
 	if priv.GrantOption {
 		tPrivilege.GrantOption = sentry_generic_policy_service.TSentryGrantOption_TRUE
@@ -200,11 +256,11 @@ func (c *genericSentryClient) GrantPrivilege(role string, priv *Privilege) error
 	result, err := c.client.AlterSentryRoleGrantPrivilege(arg)
 
 	if err != nil {
-		return fmt.Errorf("failed to grant privilege: %s", err)
+		return newTransportError(err, "failed to grant privilege")
 	}
 	if result.GetStatus().Value != 0 {
-		return newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 
 	return nil
@@ -212,6 +268,7 @@ func (c *genericSentryClient) GrantPrivilege(role string, priv *Privilege) error
 
 func (c *genericSentryClient) RevokePrivilege(role string, priv *Privilege) error {
 	arg := sentry_generic_policy_service.NewTAlterSentryRoleRevokePrivilegeRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.RoleName = role
 	arg.Component = c.component
@@ -220,6 +277,7 @@ func (c *genericSentryClient) RevokePrivilege(role string, priv *Privilege) erro
 	tPrivilege.Action = priv.Action
 	tPrivilege.Component = c.component
 	tPrivilege.ServiceName = priv.Service
+	tPrivilege.Authorizables = toTAuthorizables(priv)
 
 	if priv.GrantOption {
 		tPrivilege.GrantOption = sentry_generic_policy_service.TSentryGrantOption_TRUE
@@ -229,17 +287,254 @@ func (c *genericSentryClient) RevokePrivilege(role string, priv *Privilege) erro
 	result, err := c.client.AlterSentryRoleRevokePrivilege(arg)
 
 	if err != nil {
-		return fmt.Errorf("failed to revoke privilege: %s", err)
+		return newTransportError(err, "failed to revoke privilege")
 	}
 	if result.GetStatus().Value != 0 {
-		return newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 
 	return nil
 }
 
+// ListPrivilegesByRole implements ListPrivilegesByRole API.
+// The template should specify the service name. If template has any
+// authorizables, only privileges matching them are returned.
 func (c *genericSentryClient) ListPrivilegesByRole(roleName string,
 	template *Privilege) ([]*Privilege, error) {
-	return nil, errors.New("ListPrivilegesByRole is not implemented for generic service")
+	if template == nil || template.Service == "" {
+		return nil, fmt.Errorf("service name is required for generic service: %w",
+			ErrInvalidInput)
+	}
+	arg := sentry_generic_policy_service.NewTListSentryPrivilegesRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.RequestorUserName = c.userName
+	arg.RoleName = roleName
+	arg.Component = c.component
+	arg.ServiceName = template.Service
+	if authorizables := toTAuthorizables(template); len(authorizables) != 0 {
+		arg.Authorizables = authorizables
+	}
+
+	result, err := c.client.ListSentryPrivilegesByRole(arg)
+	if err != nil {
+		return nil, newTransportError(err, "failed to list privileges")
+	}
+	if result.GetStatus().Value != 0 {
+		return nil, newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+
+	privList := make([]*Privilege, 0, len(result.Privileges))
+	for tPriv := range result.Privileges {
+		privList = append(privList, fromTGenericPrivilege(tPriv))
+	}
+	return privList, nil
+}
+
+// DropPrivilegesOnObject implements DropPrivilegesOnObject API
+func (c *genericSentryClient) DropPrivilegesOnObject(object *Privilege) error {
+	arg := sentry_generic_policy_service.NewTDropPrivilegesRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.RequestorUserName = c.userName
+	arg.Component = c.component
+
+	tPrivilege := sentry_generic_policy_service.NewTSentryPrivilege()
+	tPrivilege.Component = c.component
+	tPrivilege.ServiceName = object.Service
+	tPrivilege.Authorizables = toTAuthorizables(object)
+	// Drop privileges for all actions unless action is specified
+	tPrivilege.Action = object.Action
+	if tPrivilege.Action == "" {
+		tPrivilege.Action = allAction
+	}
+	arg.Privilege = tPrivilege
+
+	result, err := c.client.DropSentryPrivilege(arg)
+	if err != nil {
+		return newTransportError(err, "failed to drop privileges")
+	}
+	if result.GetStatus().Value != 0 {
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+	return nil
+}
+
+// RenamePrivilegesOnObject implements RenamePrivilegesOnObject API
+func (c *genericSentryClient) RenamePrivilegesOnObject(from *Privilege,
+	to *Privilege) error {
+	arg := sentry_generic_policy_service.NewTRenamePrivilegesRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.RequestorUserName = c.userName
+	arg.Component = c.component
+	arg.ServiceName = from.Service
+	arg.OldAuthorizables = toTAuthorizables(from)
+	arg.NewAuthorizables_ = toTAuthorizables(to)
+
+	result, err := c.client.RenameSentryPrivilege(arg)
+	if err != nil {
+		return newTransportError(err, "failed to rename privileges")
+	}
+	if result.GetStatus().Value != 0 {
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+	return nil
+}
+
+// ListPrivilegesByObject implements ListPrivilegesByObject API
+func (c *genericSentryClient) ListPrivilegesByObject(object *Privilege,
+	groups []string) (map[string][]*Privilege, error) {
+	arg := sentry_generic_policy_service.NewTListSentryPrivilegesByAuthRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.RequestorUserName = c.userName
+	arg.Component = c.component
+	arg.ServiceName = object.Service
+	arg.AuthorizablesSet = map[string]bool{
+		authorizablesToString(toTAuthorizables(object)): true,
+	}
+	if len(groups) != 0 {
+		arg.Groups = toSet(groups)
+	}
+	arg.RoleSet = &sentry_generic_policy_service.TSentryActiveRoleSet{
+		All:   true,
+		Roles: map[string]bool{},
+	}
+
+	result, err := c.client.ListSentryPrivilegesByAuthorizable(arg)
+	if err != nil {
+		return nil, newTransportError(err, "failed to list privileges")
+	}
+	if result.GetStatus().Value != 0 {
+		return nil, newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+
+	rolePrivileges := make(map[string][]*Privilege)
+	for _, privMap := range result.PrivilegesMapByAuth {
+		if privMap == nil {
+			continue
+		}
+		for role, tPrivs := range privMap.PrivilegeMap {
+			for tPriv := range tPrivs {
+				rolePrivileges[role] = append(rolePrivileges[role],
+					fromTGenericPrivilege(tPriv))
+			}
+		}
+	}
+	return rolePrivileges, nil
+}
+
+// EffectivePrivileges implements EffectivePrivileges API.
+// The generic service doesn't support users, so users should be empty.
+// The object is required since it specifies the service.
+func (c *genericSentryClient) EffectivePrivileges(groups []string,
+	users []string, activeRoles []string, object *Privilege) ([]string, error) {
+	if len(users) != 0 {
+		return nil, fmt.Errorf("users are %w for generic service", ErrNotSupported)
+	}
+	if object == nil || object.Service == "" {
+		return nil, fmt.Errorf("service name is required for generic service: %w",
+			ErrInvalidInput)
+	}
+	arg := sentry_generic_policy_service.NewTListSentryPrivilegesForProviderRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.Component = c.component
+	arg.Groups = toSet(groups)
+	arg.RoleSet = &sentry_generic_policy_service.TSentryActiveRoleSet{
+		All:   len(activeRoles) == 0,
+		Roles: toSet(activeRoles),
+	}
+	arg.ServiceName = object.Service
+	arg.Authorizables = toTAuthorizables(object)
+
+	result, err := c.client.ListSentryPrivilegesForProvider(arg)
+	if err != nil {
+		return nil, newTransportError(err, "failed to list privileges")
+	}
+	if result.GetStatus().Value != 0 {
+		return nil, newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+
+	privileges := make([]string, 0, len(result.Privileges))
+	for priv := range result.Privileges {
+		privileges = append(privileges, priv)
+	}
+	sort.Strings(privileges)
+	return privileges, nil
+}
+
+// toTAuthorizables converts object part of the privilege to the list of
+// generic authorizables ordered from the top of the hierarchy.
+// If the privilege has no Authorizables, Hive model fields are used as
+// authorizables instead.
+func toTAuthorizables(priv *Privilege) []*sentry_generic_policy_service.TAuthorizable {
+	authorizables := []*sentry_generic_policy_service.TAuthorizable{}
+	if len(priv.Authorizables) != 0 {
+		for _, auth := range priv.Authorizables {
+			authorizables = append(authorizables,
+				&sentry_generic_policy_service.TAuthorizable{
+					Type: auth.Type,
+					Name: auth.Name,
+				})
+		}
+		return authorizables
+	}
+	for _, auth := range []struct{ kind, name string }{
+		{"server", priv.Server},
+		{"db", priv.Database},
+		{"table", priv.Table},
+		{"column", priv.Column},
+		{"uri", priv.URI},
+	} {
+		if auth.name == "" {
+			continue
+		}
+		authorizables = append(authorizables,
+			&sentry_generic_policy_service.TAuthorizable{
+				Type: auth.kind,
+				Name: auth.name,
+			})
+	}
+	return authorizables
+}
+
+// GetConfigValue is not supported by the generic service
+func (c *genericSentryClient) GetConfigValue(name string,
+	defaultValue string) (string, error) {
+	return "", notSupported("GetConfigValue")
+}
+
+// fromTGenericPrivilege converts generic Thrift privilege to Privilege
+func fromTGenericPrivilege(tPriv *sentry_generic_policy_service.TSentryPrivilege) *Privilege {
+	privilege := &Privilege{
+		Service:       tPriv.ServiceName,
+		Action:        tPriv.Action,
+		Authorizables: make([]Authorizable, 0, len(tPriv.Authorizables)),
+		GrantOption: tPriv.GrantOption ==
+			sentry_generic_policy_service.TSentryGrantOption_TRUE,
+	}
+	for _, auth := range tPriv.Authorizables {
+		privilege.Authorizables = append(privilege.Authorizables,
+			Authorizable{Type: auth.Type, Name: auth.Name})
+	}
+	if tPriv.GrantorPrincipal != nil {
+		privilege.Grantor = *tPriv.GrantorPrincipal
+	}
+	if tPriv.CreateTime != nil {
+		privilege.CreateTime = *tPriv.CreateTime
+	}
+	return privilege
+}
+
+// authorizablesToString converts authorizable hierarchy to the
+// type=name->type=name form used by the generic service.
+func authorizablesToString(authorizables []*sentry_generic_policy_service.TAuthorizable) string {
+	parts := make([]string, 0, len(authorizables))
+	for _, auth := range authorizables {
+		parts = append(parts, auth.Type+"="+auth.Name)
+	}
+	return strings.Join(parts, "->")
 }
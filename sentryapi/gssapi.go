@@ -0,0 +1,209 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	krbclient "gopkg.in/jcmturner/gokrb5.v7/client"
+	"gopkg.in/jcmturner/gokrb5.v7/config"
+	"gopkg.in/jcmturner/gokrb5.v7/credentials"
+	"gopkg.in/jcmturner/gokrb5.v7/crypto"
+	"gopkg.in/jcmturner/gokrb5.v7/gssapi"
+	"gopkg.in/jcmturner/gokrb5.v7/iana/flags"
+	"gopkg.in/jcmturner/gokrb5.v7/iana/keyusage"
+	"gopkg.in/jcmturner/gokrb5.v7/keytab"
+	"gopkg.in/jcmturner/gokrb5.v7/messages"
+	"gopkg.in/jcmturner/gokrb5.v7/spnego"
+	"gopkg.in/jcmturner/gokrb5.v7/types"
+)
+
+const (
+	// DefaultSentryService is the service part of Sentry server principal
+	DefaultSentryService = "sentry"
+
+	defaultKrb5Config = "/etc/krb5.conf"
+	krb5ConfigEnv     = "KRB5_CONFIG"
+	krb5CCacheEnv     = "KRB5CCNAME"
+
+	// SASL GSSAPI security layer that only provides authentication
+	saslSecurityNone = 1
+)
+
+// gssapiMechanism implements SASL GSSAPI mechanism (RFC 4752) using Kerberos
+type gssapiMechanism struct {
+	client  *krbclient.Client
+	service string
+	key     types.EncryptionKey
+}
+
+// NewGSSAPIMechanism returns SASL GSSAPI mechanism authenticating as the
+// principal.
+//   principal - client principal, e.g. user@EXAMPLE.COM
+//   keytabPath - keytab for the principal. If empty, the ticket cache
+//                specified by KRB5CCNAME or the default one is used.
+//   service - service part of the Sentry principal, DefaultSentryService
+//             is used if empty
+// Kerberos configuration is read from KRB5_CONFIG or /etc/krb5.conf
+func NewGSSAPIMechanism(principal string, keytabPath string,
+	service string) (SASLMechanism, error) {
+	configPath := os.Getenv(krb5ConfigEnv)
+	if configPath == "" {
+		configPath = defaultKrb5Config
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kerberos config %s: %s",
+			configPath, err)
+	}
+	if service == "" {
+		service = DefaultSentryService
+	}
+
+	var cl *krbclient.Client
+	if keytabPath != "" {
+		if principal == "" {
+			return nil, fmt.Errorf("principal is required with keytab")
+		}
+		kt, err := keytab.Load(keytabPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load keytab %s: %s",
+				keytabPath, err)
+		}
+		user, realm := splitPrincipal(principal)
+		if realm == "" {
+			realm = cfg.LibDefaults.DefaultRealm
+		}
+		cl = krbclient.NewClientWithKeytab(user, realm, kt, cfg)
+		if err := cl.Login(); err != nil {
+			return nil, fmt.Errorf("kerberos login for %s failed: %s",
+				principal, err)
+		}
+	} else {
+		ccache, err := credentials.LoadCCache(ccachePath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ticket cache: %s", err)
+		}
+		if cl, err = krbclient.NewClientFromCCache(ccache, cfg); err != nil {
+			return nil, fmt.Errorf("failed to use ticket cache: %s", err)
+		}
+	}
+	return &gssapiMechanism{client: cl, service: service}, nil
+}
+
+// Name implements SASLMechanism.Name()
+func (m *gssapiMechanism) Name() string {
+	return "GSSAPI"
+}
+
+// Start implements SASLMechanism.Start(). It returns the initial GSSAPI
+// context token with AP-REQ for the service principal service/host.
+func (m *gssapiMechanism) Start(host string) ([]byte, error) {
+	spn := m.service + "/" + strings.ToLower(host)
+	tkt, key, err := m.client.GetServiceTicket(spn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service ticket for %s: %s",
+			spn, err)
+	}
+	m.key = key
+	token, err := spnego.NewKRB5TokenAPREQ(m.client, tkt, key,
+		[]int{gssapi.ContextFlagMutual, gssapi.ContextFlagInteg,
+			gssapi.ContextFlagConf},
+		[]int{flags.APOptionMutualRequired})
+	if err != nil {
+		return nil, err
+	}
+	return token.Marshal()
+}
+
+// Step implements SASLMechanism.Step(). The server first sends AP-REP which
+// completes the GSSAPI context and then the wrapped security layer offer.
+// The client always chooses no security layer.
+func (m *gssapiMechanism) Step(challenge []byte) ([]byte, error) {
+	if len(challenge) == 0 {
+		return []byte{}, nil
+	}
+	if !isWrapToken(challenge) {
+		return []byte{}, m.acceptAPRep(challenge)
+	}
+	var offer gssapi.WrapToken
+	if err := offer.Unmarshal(challenge, true); err != nil {
+		return nil, err
+	}
+	if ok, err := offer.Verify(m.key, keyusage.GSSAPI_ACCEPTOR_SEAL); !ok {
+		return nil, fmt.Errorf("invalid security layer offer: %v", err)
+	}
+	if len(offer.Payload) != 4 {
+		return nil, fmt.Errorf("invalid security layer offer size %d",
+			len(offer.Payload))
+	}
+	if offer.Payload[0]&saslSecurityNone == 0 {
+		return nil, fmt.Errorf("server requires SASL security layer")
+	}
+	reply, err := gssapi.NewInitiatorWrapToken(
+		[]byte{saslSecurityNone, 0, 0, 0}, m.key)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Marshal()
+}
+
+// acceptAPRep processes AP-REP token, switching to the acceptor subkey
+// if the server provided one
+func (m *gssapiMechanism) acceptAPRep(challenge []byte) error {
+	var token spnego.KRB5Token
+	if err := token.Unmarshal(challenge); err != nil {
+		return fmt.Errorf("invalid GSSAPI token: %s", err)
+	}
+	if !token.IsAPRep() {
+		return fmt.Errorf("unexpected GSSAPI token from server")
+	}
+	b, err := crypto.DecryptEncPart(token.APRep.EncPart, m.key,
+		keyusage.AP_REP_ENCPART)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt AP-REP: %s", err)
+	}
+	var repPart messages.EncAPRepPart
+	if err := repPart.Unmarshal(b); err != nil {
+		return fmt.Errorf("invalid AP-REP: %s", err)
+	}
+	if repPart.Subkey.KeyType != 0 {
+		m.key = repPart.Subkey
+	}
+	return nil
+}
+
+// isWrapToken returns true if token is GSSAPI Wrap token (RFC 4121)
+func isWrapToken(token []byte) bool {
+	return len(token) > 2 && token[0] == 0x05 && token[1] == 0x04
+}
+
+// splitPrincipal splits principal into the name and realm parts
+func splitPrincipal(principal string) (string, string) {
+	if i := strings.LastIndex(principal, "@"); i >= 0 {
+		return principal[:i], principal[i+1:]
+	}
+	return principal, ""
+}
+
+// ccachePath returns path to the Kerberos ticket cache
+func ccachePath() string {
+	if path := os.Getenv(krb5CCacheEnv); path != "" {
+		return strings.TrimPrefix(path, "FILE:")
+	}
+	return fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid())
+}
@@ -0,0 +1,388 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoint is a Sentry server address
+type Endpoint struct {
+	Host string
+	Port int
+}
+
+func (e Endpoint) String() string {
+	return fmt.Sprintf("%s:%d", e.Host, e.Port)
+}
+
+// ParseEndpoints parses comma-separated list of hosts. Each host may be
+// specified as host or host:port. The defaultPort is used for hosts without
+// a port.
+func ParseEndpoints(hosts string, defaultPort int) ([]Endpoint, error) {
+	endpoints := []Endpoint{}
+	for _, host := range strings.Split(hosts, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		endpoint := Endpoint{Host: host, Port: defaultPort}
+		if parts := strings.Split(host, ":"); len(parts) == 2 {
+			port, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid port %s", parts[1])
+			}
+			endpoint = Endpoint{Host: parts[0], Port: port}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no Sentry hosts specified")
+	}
+	return endpoints, nil
+}
+
+// haEndpoint keeps circuit breaker state of the endpoint
+type haEndpoint struct {
+	Endpoint
+	failures  int
+	downUntil time.Time
+}
+
+// fail marks endpoint down for the backoff time which doubles with every
+// consecutive failure
+func (e *haEndpoint) fail(now time.Time, options *clientOptions) {
+	backoff := options.minBackoff
+	for i := 0; i < e.failures && backoff < options.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > options.maxBackoff {
+		backoff = options.maxBackoff
+	}
+	e.failures++
+	e.downUntil = now.Add(backoff)
+}
+
+// haState keeps circuit breaker state of the endpoints. The state is shared
+// by all connections of the pooled client, so an endpoint which is down is
+// skipped by every connection.
+type haState struct {
+	lock      sync.Mutex
+	endpoints []*haEndpoint
+	options   *clientOptions
+}
+
+// newHAState returns state with all endpoints up
+func newHAState(endpoints []Endpoint, options *clientOptions) *haState {
+	s := &haState{options: options}
+	for _, endpoint := range endpoints {
+		s.endpoints = append(s.endpoints, &haEndpoint{Endpoint: endpoint})
+	}
+	return s
+}
+
+// candidates returns endpoints in the order they should be tried:
+// endpoints which are up in the configured order. If all endpoints are down,
+// the one which should recover first is returned.
+func (s *haState) candidates(now time.Time) []*haEndpoint {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := []*haEndpoint{}
+	var next *haEndpoint
+	for _, e := range s.endpoints {
+		if !now.Before(e.downUntil) {
+			result = append(result, e)
+		} else if next == nil || e.downUntil.Before(next.downUntil) {
+			next = e
+		}
+	}
+	if len(result) == 0 {
+		result = append(result, next)
+	}
+	return result
+}
+
+// fail marks endpoint down
+func (s *haState) fail(e *haEndpoint, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e.fail(now, s.options)
+}
+
+// succeed resets endpoint backoff after a successful call
+func (s *haState) succeed(e *haEndpoint) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e.failures = 0
+}
+
+// haClient is ClientAPI which fails over between several Sentry servers.
+// Only one server is used at a time. When the connection to the server
+// breaks, the server is marked down and the client reconnects to another
+// one. Idempotent calls are retried on the new server, other calls return
+// the error.
+type haClient struct {
+	state   *haState
+	dial    func(endpoint Endpoint) (ClientAPI, error)
+	current *haEndpoint
+	client  ClientAPI
+	failed  bool
+}
+
+// NewHAClient returns ClientAPI which uses any of the endpoints, failing
+// over to another one when the connection breaks. Arguments are the same
+// as for GetClient(). The connection is established lazily, on first call.
+// HA specific options are WithFailoverBackoff() and WithCallReporter().
+func NewHAClient(protocol ProtocolType, endpoints []Endpoint, component string,
+	user string, opts ...ClientOption) (ClientAPI, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no Sentry hosts specified")
+	}
+	state := newHAState(endpoints, newClientOptions(opts))
+	return newHAClient(state, protocol, component, user, opts), nil
+}
+
+// newHAClient returns HA client using the given endpoint state
+func newHAClient(state *haState, protocol ProtocolType, component string,
+	user string, opts []ClientOption) *haClient {
+	return &haClient{
+		state: state,
+		dial: func(endpoint Endpoint) (ClientAPI, error) {
+			return GetClient(protocol, endpoint.Host, endpoint.Port,
+				component, user, opts...)
+		},
+	}
+}
+
+// Close closes the current connection
+func (c *haClient) Close() {
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+		c.current = nil
+	}
+}
+
+// broken returns true if the last call failed because no endpoint could
+// serve it
+func (c *haClient) broken() bool {
+	return c.failed || (c.client != nil && clientBroken(c.client))
+}
+
+// connection returns connected client, connecting to a new endpoint if needed
+func (c *haClient) connection() (ClientAPI, error) {
+	if c.client != nil {
+		return c.client, nil
+	}
+	now := time.Now()
+	var err error
+	for _, e := range c.state.candidates(now) {
+		var client ClientAPI
+		if client, err = c.dial(e.Endpoint); err == nil {
+			c.current = e
+			c.client = client
+			return client, nil
+		}
+		c.state.fail(e, now)
+	}
+	return nil, fmt.Errorf("no Sentry host available: %w", err)
+}
+
+// call executes the operation, reconnecting if the connection breaks.
+// Operations with retry set are repeated on another endpoint.
+func (c *haClient) call(operation string, retry bool,
+	fn func(client ClientAPI) error) error {
+	var err error
+	c.failed = true
+	for attempt := 0; attempt < len(c.state.endpoints); attempt++ {
+		var client ClientAPI
+		if client, err = c.connection(); err != nil {
+			c.report(operation, "", err)
+			return err
+		}
+		endpoint := c.current
+		err = fn(client)
+		c.report(operation, endpoint.String(), err)
+		if !clientBroken(client) {
+			c.state.succeed(endpoint)
+			c.failed = false
+			return err
+		}
+		c.Close()
+		c.state.fail(endpoint, time.Now())
+		if !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// report calls the reporter if one is set
+func (c *haClient) report(operation string, host string, err error) {
+	if c.state.options.reporter != nil {
+		c.state.options.reporter(operation, host, err)
+	}
+}
+
+// clientBroken returns true if client connection can't be used anymore
+func clientBroken(client ClientAPI) bool {
+	if c, ok := client.(interface {
+		broken() bool
+	}); ok {
+		return c.broken()
+	}
+	return false
+}
+
+// CreateRole implements ClientAPI.CreateRole()
+func (c *haClient) CreateRole(roleName string) error {
+	return c.call("CreateRole", false, func(client ClientAPI) error {
+		return client.CreateRole(roleName)
+	})
+}
+
+// RemoveRole implements ClientAPI.RemoveRole()
+func (c *haClient) RemoveRole(roleName string) error {
+	return c.call("RemoveRole", false, func(client ClientAPI) error {
+		return client.RemoveRole(roleName)
+	})
+}
+
+// ListRoleByGroup implements ClientAPI.ListRoleByGroup()
+func (c *haClient) ListRoleByGroup(groupName string) ([]string, []*Role, error) {
+	var names []string
+	var roles []*Role
+	err := c.call("ListRoleByGroup", true, func(client ClientAPI) (err error) {
+		names, roles, err = client.ListRoleByGroup(groupName)
+		return err
+	})
+	return names, roles, err
+}
+
+// AddGroupsToRole implements ClientAPI.AddGroupsToRole()
+func (c *haClient) AddGroupsToRole(roleName string, groups []string) error {
+	return c.call("AddGroupsToRole", false, func(client ClientAPI) error {
+		return client.AddGroupsToRole(roleName, groups)
+	})
+}
+
+// RemoveGroupsFromRole implements ClientAPI.RemoveGroupsFromRole()
+func (c *haClient) RemoveGroupsFromRole(roleName string, groups []string) error {
+	return c.call("RemoveGroupsFromRole", false, func(client ClientAPI) error {
+		return client.RemoveGroupsFromRole(roleName, groups)
+	})
+}
+
+// ListRoleByUser implements ClientAPI.ListRoleByUser()
+func (c *haClient) ListRoleByUser(userName string) ([]string, []*Role, error) {
+	var names []string
+	var roles []*Role
+	err := c.call("ListRoleByUser", true, func(client ClientAPI) (err error) {
+		names, roles, err = client.ListRoleByUser(userName)
+		return err
+	})
+	return names, roles, err
+}
+
+// AddUsersToRole implements ClientAPI.AddUsersToRole()
+func (c *haClient) AddUsersToRole(roleName string, users []string) error {
+	return c.call("AddUsersToRole", false, func(client ClientAPI) error {
+		return client.AddUsersToRole(roleName, users)
+	})
+}
+
+// RemoveUsersFromRole implements ClientAPI.RemoveUsersFromRole()
+func (c *haClient) RemoveUsersFromRole(roleName string, users []string) error {
+	return c.call("RemoveUsersFromRole", false, func(client ClientAPI) error {
+		return client.RemoveUsersFromRole(roleName, users)
+	})
+}
+
+// GrantPrivilege implements ClientAPI.GrantPrivilege()
+func (c *haClient) GrantPrivilege(roleName string, priv *Privilege) error {
+	return c.call("GrantPrivilege", false, func(client ClientAPI) error {
+		return client.GrantPrivilege(roleName, priv)
+	})
+}
+
+// RevokePrivilege implements ClientAPI.RevokePrivilege()
+func (c *haClient) RevokePrivilege(roleName string, priv *Privilege) error {
+	return c.call("RevokePrivilege", false, func(client ClientAPI) error {
+		return client.RevokePrivilege(roleName, priv)
+	})
+}
+
+// ListPrivilegesByRole implements ClientAPI.ListPrivilegesByRole()
+func (c *haClient) ListPrivilegesByRole(roleName string,
+	template *Privilege) ([]*Privilege, error) {
+	var privileges []*Privilege
+	err := c.call("ListPrivilegesByRole", true, func(client ClientAPI) (err error) {
+		privileges, err = client.ListPrivilegesByRole(roleName, template)
+		return err
+	})
+	return privileges, err
+}
+
+// DropPrivilegesOnObject implements ClientAPI.DropPrivilegesOnObject()
+func (c *haClient) DropPrivilegesOnObject(object *Privilege) error {
+	return c.call("DropPrivilegesOnObject", false, func(client ClientAPI) error {
+		return client.DropPrivilegesOnObject(object)
+	})
+}
+
+// RenamePrivilegesOnObject implements ClientAPI.RenamePrivilegesOnObject()
+func (c *haClient) RenamePrivilegesOnObject(from *Privilege, to *Privilege) error {
+	return c.call("RenamePrivilegesOnObject", false, func(client ClientAPI) error {
+		return client.RenamePrivilegesOnObject(from, to)
+	})
+}
+
+// ListPrivilegesByObject implements ClientAPI.ListPrivilegesByObject()
+func (c *haClient) ListPrivilegesByObject(object *Privilege,
+	groups []string) (map[string][]*Privilege, error) {
+	var privileges map[string][]*Privilege
+	err := c.call("ListPrivilegesByObject", true, func(client ClientAPI) (err error) {
+		privileges, err = client.ListPrivilegesByObject(object, groups)
+		return err
+	})
+	return privileges, err
+}
+
+// EffectivePrivileges implements ClientAPI.EffectivePrivileges()
+func (c *haClient) EffectivePrivileges(groups []string, users []string,
+	activeRoles []string, object *Privilege) ([]string, error) {
+	var privileges []string
+	err := c.call("EffectivePrivileges", true, func(client ClientAPI) (err error) {
+		privileges, err = client.EffectivePrivileges(groups, users,
+			activeRoles, object)
+		return err
+	})
+	return privileges, err
+}
+
+// GetConfigValue implements ClientAPI.GetConfigValue()
+func (c *haClient) GetConfigValue(name string,
+	defaultValue string) (string, error) {
+	var value string
+	err := c.call("GetConfigValue", true, func(client ClientAPI) (err error) {
+		value, err = client.GetConfigValue(name, defaultValue)
+		return err
+	})
+	return value, err
+}
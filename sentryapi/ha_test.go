@@ -0,0 +1,205 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// haFakeServer simulates Sentry server state for HA client tests
+type haFakeServer struct {
+	dead bool
+}
+
+// haFakeClient is a client connected to haFakeServer. Calls fail and break
+// the connection when the server is dead.
+type haFakeClient struct {
+	ClientAPI
+	server   *haFakeServer
+	name     string
+	isBroken bool
+}
+
+func (c *haFakeClient) Close() {}
+
+func (c *haFakeClient) broken() bool {
+	return c.isBroken
+}
+
+func (c *haFakeClient) check() error {
+	if c.server.dead {
+		c.isBroken = true
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func (c *haFakeClient) CreateRole(roleName string) error {
+	return c.check()
+}
+
+func (c *haFakeClient) ListRoleByGroup(groupName string) ([]string, []*Role, error) {
+	if err := c.check(); err != nil {
+		return nil, nil, err
+	}
+	return []string{c.name}, nil, nil
+}
+
+func newTestHAClient(t *testing.T, servers map[string]*haFakeServer,
+	reporter CallReporter) *haClient {
+	client, err := NewHAClient(PolicyProtocol,
+		[]Endpoint{{"a", 8038}, {"b", 8038}}, "", "user",
+		WithFailoverBackoff(time.Hour, time.Hour), WithCallReporter(reporter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := client.(*haClient)
+	c.dial = func(endpoint Endpoint) (ClientAPI, error) {
+		server := servers[endpoint.Host]
+		if server.dead {
+			return nil, errors.New("connection refused")
+		}
+		return &haFakeClient{server: server, name: endpoint.Host}, nil
+	}
+	return c
+}
+
+func TestHAClient_Failover(t *testing.T) {
+	servers := map[string]*haFakeServer{"a": {}, "b": {}}
+	hosts := []string{}
+	client := newTestHAClient(t, servers,
+		func(operation string, host string, err error) {
+			hosts = append(hosts, host)
+		})
+	names, _, err := client.ListRoleByGroup("")
+	if err != nil || names[0] != "a" {
+		t.Fatalf("expected call served by a, got %v, %v", names, err)
+	}
+
+	// Reads are retried on another host
+	servers["a"].dead = true
+	names, _, err = client.ListRoleByGroup("")
+	if err != nil || names[0] != "b" {
+		t.Fatalf("expected call served by b, got %v, %v", names, err)
+	}
+	expected := []string{"a:8038", "a:8038", "b:8038"}
+	if len(hosts) != len(expected) {
+		t.Fatalf("expected hosts %v, got %v", expected, hosts)
+	}
+	for i := range expected {
+		if hosts[i] != expected[i] {
+			t.Errorf("expected hosts %v, got %v", expected, hosts)
+		}
+	}
+
+	// Host a is down, so it isn't used even after it recovers
+	servers["a"].dead = false
+	client.Close()
+	if names, _, _ = client.ListRoleByGroup(""); names[0] != "b" {
+		t.Errorf("expected call served by b, got %v", names)
+	}
+}
+
+func TestHAClient_NoRetryForUpdates(t *testing.T) {
+	servers := map[string]*haFakeServer{"a": {}, "b": {}}
+	client := newTestHAClient(t, servers, nil)
+	if err := client.CreateRole("r1"); err != nil {
+		t.Fatal(err)
+	}
+	servers["a"].dead = true
+	if err := client.CreateRole("r1"); err == nil {
+		t.Error("expected error for broken connection")
+	}
+	// The next call reconnects to another host
+	if err := client.CreateRole("r1"); err != nil {
+		t.Error(err)
+	}
+	if client.current.Host != "b" {
+		t.Errorf("expected host b, got %s", client.current.Host)
+	}
+}
+
+func TestHAClient_AllDown(t *testing.T) {
+	servers := map[string]*haFakeServer{"a": {dead: true}, "b": {dead: true}}
+	client := newTestHAClient(t, servers, nil)
+	if _, _, err := client.ListRoleByGroup(""); err == nil {
+		t.Fatal("expected error when all hosts are down")
+	}
+	// When all hosts are down, the one recovering first is tried
+	servers["b"].dead = false
+	client.state.endpoints[0].downUntil =
+		client.state.endpoints[1].downUntil.Add(time.Minute)
+	if names, _, err := client.ListRoleByGroup(""); err != nil || names[0] != "b" {
+		t.Errorf("expected call served by b, got %v, %v", names, err)
+	}
+}
+
+func TestHAClient_SharedState(t *testing.T) {
+	servers := map[string]*haFakeServer{"a": {}, "b": {}}
+	first := newTestHAClient(t, servers, nil)
+	second := newHAClient(first.state, PolicyProtocol, "", "user", nil)
+	second.dial = first.dial
+	if _, _, err := second.ListRoleByGroup(""); err != nil {
+		t.Fatal(err)
+	}
+	servers["a"].dead = true
+	if _, _, err := first.ListRoleByGroup(""); err != nil {
+		t.Fatal(err)
+	}
+	// Host a is marked down by the first client, so the second one fails
+	// over without trying it again
+	servers["a"].dead = false
+	second.Close()
+	if names, _, _ := second.ListRoleByGroup(""); names[0] != "b" {
+		t.Errorf("expected call served by b, got %v", names)
+	}
+}
+
+func TestHAClient_Broken(t *testing.T) {
+	servers := map[string]*haFakeServer{"a": {}, "b": {}}
+	client := newTestHAClient(t, servers, nil)
+	if err := client.CreateRole("r1"); err != nil {
+		t.Fatal(err)
+	}
+	servers["a"].dead = true
+	if err := client.CreateRole("r1"); err == nil {
+		t.Fatal("expected error for broken connection")
+	}
+	if !clientBroken(client) {
+		t.Error("expected broken client after transport failure")
+	}
+	if err := client.CreateRole("r1"); err != nil {
+		t.Fatal(err)
+	}
+	if clientBroken(client) {
+		t.Error("client is broken after successful call")
+	}
+}
+
+func TestParseEndpoints(t *testing.T) {
+	endpoints, err := ParseEndpoints("h1, h2:9000", 8038)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 2 || endpoints[0].String() != "h1:8038" ||
+		endpoints[1].String() != "h2:9000" {
+		t.Errorf("unexpected endpoints %v", endpoints)
+	}
+	if _, err := ParseEndpoints("h1:port", 8038); err == nil {
+		t.Error("expected error for invalid port")
+	}
+}
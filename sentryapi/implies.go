@@ -0,0 +1,208 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"sort"
+	"strings"
+)
+
+// wildcard is the object name matching all objects at its level
+const wildcard = "*"
+
+// Implies returns true if the granted privilege gives at least the access
+// described by the requested privilege, following Sentry rules:
+//
+//   - privileges of the legacy and generic model never imply each other
+//   - a privilege on an object implies privileges on all its children, so
+//     server implies database, table, column and URIs on the server, database
+//     implies its tables and so on. A privilege on a child never implies its
+//     parent.
+//   - "*" as an object name matches any object at its level
+//   - granted URI implies the same URI and any path below it
+//   - "all" (or "*") action implies all actions. An empty action means all
+//     actions in both granted and requested privileges.
+//   - a privilege without grant option doesn't imply one with grant option
+//
+// Names are compared in the normalized form, see Privilege.Normalize().
+func Implies(granted, requested *Privilege) bool {
+	if granted == nil || requested == nil {
+		return false
+	}
+	g, r := granted.Normalize(), requested.Normalize()
+	if g.IsGeneric() != r.IsGeneric() {
+		return false
+	}
+	if r.GrantOption && !g.GrantOption {
+		return false
+	}
+	if !impliesAction(g.Action, r.Action) {
+		return false
+	}
+	if g.IsGeneric() {
+		return impliesGeneric(g, r)
+	}
+	return impliesLegacy(g, r)
+}
+
+// impliesAction returns true if granted action implies the requested one.
+// Empty action is the same as ALL.
+func impliesAction(granted, requested string) bool {
+	if granted == "" || granted == allAction {
+		return true
+	}
+	return requested != "" && granted == requested
+}
+
+// impliesName returns true if the granted object name at some level of the
+// hierarchy implies the requested name at the same level. Empty granted name
+// means that the privilege is on the parent object; empty requested name means
+// that the parent object is requested which is only implied by a wildcard.
+func impliesName(granted, requested string) bool {
+	return granted == "" || granted == wildcard || granted == requested
+}
+
+// impliesLegacy checks object hierarchy of legacy model privileges
+func impliesLegacy(g, r *Privilege) bool {
+	if g.URI != "" {
+		return r.URI != "" && impliesURI(g.URI, r.URI) &&
+			impliesName(g.Server, r.Server)
+	}
+	// Only server privileges imply URIs
+	if r.URI != "" && g.Database != "" {
+		return false
+	}
+	return impliesName(g.Server, r.Server) &&
+		impliesName(g.Database, r.Database) &&
+		impliesName(g.Table, r.Table) &&
+		impliesName(g.Column, r.Column)
+}
+
+// impliesURI returns true if the requested URI is the granted one or is
+// below it. The prefix should end at path separator, so hdfs://nn/a doesn't
+// imply hdfs://nn/ab.
+func impliesURI(granted, requested string) bool {
+	if granted == requested {
+		return true
+	}
+	return strings.HasPrefix(requested, strings.TrimSuffix(granted, "/")+"/")
+}
+
+// impliesGeneric checks authorizables of generic model privileges. The
+// authorizables form a path, e.g. db=sales->table=orders, so granted path
+// should be the prefix of the requested one, or the rest of it should be
+// wildcards.
+func impliesGeneric(g, r *Privilege) bool {
+	if g.Service != "" && r.Service != "" && g.Service != r.Service {
+		return false
+	}
+	for i, auth := range g.Authorizables {
+		if i >= len(r.Authorizables) {
+			if auth.Name != wildcard {
+				return false
+			}
+			continue
+		}
+		if auth.Type != r.Authorizables[i].Type ||
+			(auth.Name != wildcard && auth.Name != r.Authorizables[i].Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// PrivilegeSet is a set of privileges, e.g. granted to a role. Privileges
+// which Sentry considers equal are stored once.
+type PrivilegeSet struct {
+	privileges map[string]*Privilege
+}
+
+// NewPrivilegeSet returns set with given privileges
+func NewPrivilegeSet(privileges ...*Privilege) *PrivilegeSet {
+	set := &PrivilegeSet{privileges: make(map[string]*Privilege)}
+	for _, priv := range privileges {
+		set.Add(priv)
+	}
+	return set
+}
+
+// Add adds privilege to the set. It returns false if an equal privilege is
+// already in the set.
+func (s *PrivilegeSet) Add(privilege *Privilege) bool {
+	key := privilege.Key()
+	if _, ok := s.privileges[key]; ok {
+		return false
+	}
+	s.privileges[key] = privilege
+	return true
+}
+
+// Remove removes privilege equal to the given one from the set. It returns
+// false if there is no such privilege.
+func (s *PrivilegeSet) Remove(privilege *Privilege) bool {
+	key := privilege.Key()
+	if _, ok := s.privileges[key]; !ok {
+		return false
+	}
+	delete(s.privileges, key)
+	return true
+}
+
+// Contains returns true if the set has privilege equal to the given one
+func (s *PrivilegeSet) Contains(privilege *Privilege) bool {
+	_, ok := s.privileges[privilege.Key()]
+	return ok
+}
+
+// Covers returns true if any privilege in the set implies the given one
+func (s *PrivilegeSet) Covers(privilege *Privilege) bool {
+	for _, priv := range s.privileges {
+		if Implies(priv, privilege) {
+			return true
+		}
+	}
+	return false
+}
+
+// CoveredBy returns privileges from the set which imply the given one,
+// sorted by their keys
+func (s *PrivilegeSet) CoveredBy(privilege *Privilege) []*Privilege {
+	var result []*Privilege
+	for _, priv := range s.Privileges() {
+		if Implies(priv, privilege) {
+			result = append(result, priv)
+		}
+	}
+	return result
+}
+
+// Len returns the number of privileges in the set
+func (s *PrivilegeSet) Len() int {
+	return len(s.privileges)
+}
+
+// Privileges returns all privileges in the set sorted by their keys
+func (s *PrivilegeSet) Privileges() []*Privilege {
+	keys := make([]string, 0, len(s.privileges))
+	for key := range s.privileges {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]*Privilege, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, s.privileges[key])
+	}
+	return result
+}
@@ -0,0 +1,133 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImplies(t *testing.T) {
+	tests := []struct {
+		granted, requested string
+		generic            bool
+		implies            bool
+	}{
+		{"server=server1", "server=server1->db=sales->table=t->action=select", false, true},
+		{"server=server1->action=all", "server=SERVER1->uri=hdfs://nn/a->action=select", false, true},
+		{"server=server1->db=sales->action=*", "server=server1->db=sales->table=t->action=insert", false, true},
+		{"server=server1->db=sales->action=all", "server=server1->db=Sales->column=c", false, true},
+		{"server=server1->db=sales->table=t->action=all", "server=server1->db=sales->action=select", false, false},
+		{"server=server1->db=sales->action=select", "server=server1->db=sales->action=insert", false, false},
+		{"server=server1->db=sales->action=select", "server=server1->db=sales->action=all", false, false},
+		// No action means ALL
+		{"server=server1->db=sales->action=select", "server=server1->db=sales", false, false},
+		{"server=server1->db=sales", "server=server1->db=sales->table=t->action=insert", false, true},
+		{"server=server1->db=sales", "server=server2->db=sales", false, false},
+		{"server=server1->db=*->action=select", "server=server1->db=other->table=t->action=select", false, true},
+		{"server=server1->db=*->table=*", "server=server1->action=select", false, true},
+		{"server=server1->db=sales->action=select", "server=server1->uri=hdfs://nn/a->action=select", false, false},
+		{"server=server1->uri=hdfs://nn/a->action=all", "server=server1->uri=hdfs://nn/a/b->action=all", false, true},
+		{"server=server1->uri=hdfs://nn/a/->action=all", "server=server1->uri=hdfs://nn/a/b->action=all", false, true},
+		{"server=server1->uri=hdfs://nn/a->action=all", "server=server1->uri=hdfs://nn/ab->action=all", false, false},
+		{"server=server1->uri=hdfs://nn/a->action=all", "server=server1->db=sales->action=all", false, false},
+		{"server=server1->db=sales->action=select", "server=server1->db=sales->action=select->grantoption=true", false, false},
+		{"server=server1->db=sales->action=select->grantoption=true", "server=server1->db=sales->action=select", false, true},
+		{"collection=logs->action=*", "collection=LOGS->action=query", true, true},
+		{"collection=*->action=query", "collection=logs->action=query", true, true},
+		{"collection=logs->action=query", "collection=logs->action=update", true, false},
+		{"collection=logs->action=query", "collection=other->action=query", true, false},
+		{"db=sales->action=all", "db=sales->table=orders->action=select", true, true},
+		{"db=sales->table=orders->action=all", "db=sales->action=select", true, false},
+		{"db=sales->table=*->action=all", "db=sales->action=select", true, true},
+		{"db=sales->action=all", "table=sales->action=select", true, false},
+	}
+	for _, tt := range tests {
+		parse := ParsePrivilege
+		if tt.generic {
+			parse = ParseGenericPrivilege
+		}
+		granted, err := parse(tt.granted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requested, err := parse(tt.requested)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.generic {
+			granted.Service, requested.Service = "service1", "service1"
+		}
+		if implies := Implies(granted, requested); implies != tt.implies {
+			t.Errorf("Implies(%s, %s): expected %v, got %v",
+				tt.granted, tt.requested, tt.implies, implies)
+		}
+	}
+}
+
+func TestImplies_Models(t *testing.T) {
+	legacy := &Privilege{Server: "server1", Action: "all"}
+	generic := &Privilege{Service: "server1", Action: "all"}
+	if Implies(legacy, generic) || Implies(generic, legacy) {
+		t.Error("privileges of different models imply each other")
+	}
+	other := &Privilege{Service: "server2", Action: "all"}
+	if Implies(generic, other) {
+		t.Error("privilege implies privilege in another service")
+	}
+	if Implies(nil, legacy) || Implies(legacy, nil) {
+		t.Error("nil privilege implies or is implied")
+	}
+}
+
+func TestPrivilegeSet(t *testing.T) {
+	dbPriv := &Privilege{Server: "server1", Database: "sales", Action: "all"}
+	tablePriv := &Privilege{Server: "server1", Database: "sales", Table: "orders",
+		Action: "select"}
+	set := NewPrivilegeSet(tablePriv, dbPriv)
+	if set.Len() != 2 {
+		t.Fatalf("expected 2 privileges, got %d", set.Len())
+	}
+	if set.Add(&Privilege{Server: "Server1", Database: "SALES", Action: "*"}) {
+		t.Error("equal privilege added twice")
+	}
+	if !set.Contains(&Privilege{Server: "server1", Database: "sales", Action: "all"}) {
+		t.Error("set doesn't contain added privilege")
+	}
+
+	requested := &Privilege{Server: "server1", Database: "sales", Table: "orders",
+		Column: "id", Action: "select"}
+	if !set.Covers(requested) {
+		t.Error("set doesn't cover column privilege")
+	}
+	if covering := set.CoveredBy(requested); !reflect.DeepEqual(covering,
+		[]*Privilege{dbPriv, tablePriv}) {
+		t.Errorf("unexpected covering privileges %v", covering)
+	}
+	if set.Covers(&Privilege{Server: "server1", Database: "hr", Action: "select"}) {
+		t.Error("set covers privilege on another database")
+	}
+
+	if !set.Remove(dbPriv) || set.Remove(dbPriv) {
+		t.Error("unexpected Remove result")
+	}
+	if set.Covers(&Privilege{Server: "server1", Database: "sales", Table: "orders",
+		Action: "insert"}) {
+		t.Error("set covers insert after removing database privilege")
+	}
+	if privs := set.Privileges(); len(privs) != 1 || privs[0] != tablePriv {
+		t.Errorf("unexpected privileges %v", privs)
+	}
+}
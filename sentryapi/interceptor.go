@@ -0,0 +1,334 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Call describes ClientAPI call passed to interceptors. Only attributes
+// relevant for the operation are set.
+// Attributes:
+//   Operation - ClientAPI method name, e.g. "GrantPrivilege"
+//   Host - host:port of the Sentry server
+//   Role - role name
+//   Groups - group names
+//   Users - user names
+//   Privilege - privilege, object or list template
+//   Target - new object for RenamePrivilegesOnObject
+//   Result - values returned by the call, set when the call completes
+type Call struct {
+	Operation string
+	Host      string
+	Role      string
+	Groups    []string
+	Users     []string
+	Privilege *Privilege
+	Target    *Privilege
+	Result    interface{}
+}
+
+// Invoker executes the call
+type Invoker func(call *Call) error
+
+// Interceptor wraps ClientAPI calls. It should call next to proceed with the
+// call and return its error; returning without calling next rejects the
+// call. Call arguments are informational, changing them doesn't affect the
+// call.
+type Interceptor func(call *Call, next Invoker) error
+
+// WithInterceptors installs interceptors around every call of clients
+// returned by GetClient(). The first interceptor is the outermost one.
+// HA and pooled clients intercept calls of each connection, so retried
+// calls are seen once per attempt.
+func WithInterceptors(interceptors ...Interceptor) ClientOption {
+	return func(o *clientOptions) {
+		o.interceptors = append(o.interceptors, interceptors...)
+	}
+}
+
+// NewLoggingInterceptor returns interceptor which logs every completed call
+// with its arguments and error as key=value pairs
+func NewLoggingInterceptor(logger *log.Logger) Interceptor {
+	return func(call *Call, next Invoker) error {
+		err := next(call)
+		fields := []string{"operation=" + call.Operation}
+		if call.Role != "" {
+			fields = append(fields, "role="+quote(call.Role))
+		}
+		if len(call.Groups) != 0 {
+			fields = append(fields, "groups="+quote(strings.Join(call.Groups, ",")))
+		}
+		if len(call.Users) != 0 {
+			fields = append(fields, "users="+quote(strings.Join(call.Users, ",")))
+		}
+		if call.Privilege != nil {
+			fields = append(fields, "privilege="+quote(call.Privilege.String()))
+		}
+		if call.Target != nil {
+			fields = append(fields, "target="+quote(call.Target.String()))
+		}
+		if err != nil {
+			fields = append(fields, "error="+strconv.Quote(err.Error()))
+		} else {
+			fields = append(fields, "status=ok")
+		}
+		logger.Println(strings.Join(fields, " "))
+		return err
+	}
+}
+
+// NewTimingInterceptor returns interceptor which calls observe with the
+// duration of every call
+func NewTimingInterceptor(observe func(operation string, elapsed time.Duration,
+	err error)) Interceptor {
+	return func(call *Call, next Invoker) error {
+		start := time.Now()
+		err := next(call)
+		observe(call.Operation, time.Since(start), err)
+		return err
+	}
+}
+
+// quote quotes values which contain spaces or quotes
+func quote(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\"=") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// interceptedClient runs every ClientAPI call through the interceptors
+type interceptedClient struct {
+	client       ClientAPI
+	host         string
+	interceptors []Interceptor
+}
+
+// newInterceptedClient wraps client with interceptors, the client is
+// returned as is when there are no interceptors
+func newInterceptedClient(client ClientAPI, endpoint Endpoint,
+	interceptors []Interceptor) ClientAPI {
+	if len(interceptors) == 0 {
+		return client
+	}
+	return &interceptedClient{client: client, host: endpoint.String(),
+		interceptors: interceptors}
+}
+
+// invoke runs fn through the interceptor chain
+func (c *interceptedClient) invoke(call *Call, fn func() (interface{}, error)) error {
+	call.Host = c.host
+	next := func(call *Call) error {
+		result, err := fn()
+		call.Result = result
+		return err
+	}
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := c.interceptors[i], next
+		next = func(call *Call) error {
+			return interceptor(call, inner)
+		}
+	}
+	return next(call)
+}
+
+// broken returns true if the connection of the wrapped client is broken
+func (c *interceptedClient) broken() bool {
+	return clientBroken(c.client)
+}
+
+// cancel aborts the pending call of the wrapped client
+func (c *interceptedClient) cancel() {
+	if cc, ok := c.client.(canceler); ok {
+		cc.cancel()
+	}
+}
+
+// version returns protocol version of the wrapped client
+func (c *interceptedClient) version() int32 {
+	if v, ok := c.client.(interface {
+		version() int32
+	}); ok {
+		return v.version()
+	}
+	return 0
+}
+
+// Close implements ClientAPI.Close()
+func (c *interceptedClient) Close() {
+	c.client.Close()
+}
+
+// CreateRole implements ClientAPI.CreateRole()
+func (c *interceptedClient) CreateRole(roleName string) error {
+	return c.invoke(&Call{Operation: "CreateRole", Role: roleName},
+		func() (interface{}, error) {
+			return nil, c.client.CreateRole(roleName)
+		})
+}
+
+// RemoveRole implements ClientAPI.RemoveRole()
+func (c *interceptedClient) RemoveRole(roleName string) error {
+	return c.invoke(&Call{Operation: "RemoveRole", Role: roleName},
+		func() (interface{}, error) {
+			return nil, c.client.RemoveRole(roleName)
+		})
+}
+
+// ListRoleByGroup implements ClientAPI.ListRoleByGroup(). The call result
+// is the list of role names.
+func (c *interceptedClient) ListRoleByGroup(groupName string) ([]string, []*Role, error) {
+	var names []string
+	var roles []*Role
+	call := &Call{Operation: "ListRoleByGroup"}
+	if groupName != "" {
+		call.Groups = []string{groupName}
+	}
+	err := c.invoke(call, func() (result interface{}, err error) {
+		names, roles, err = c.client.ListRoleByGroup(groupName)
+		return names, err
+	})
+	return names, roles, err
+}
+
+// AddGroupsToRole implements ClientAPI.AddGroupsToRole()
+func (c *interceptedClient) AddGroupsToRole(roleName string, groups []string) error {
+	return c.invoke(&Call{Operation: "AddGroupsToRole", Role: roleName,
+		Groups: groups}, func() (interface{}, error) {
+		return nil, c.client.AddGroupsToRole(roleName, groups)
+	})
+}
+
+// RemoveGroupsFromRole implements ClientAPI.RemoveGroupsFromRole()
+func (c *interceptedClient) RemoveGroupsFromRole(roleName string, groups []string) error {
+	return c.invoke(&Call{Operation: "RemoveGroupsFromRole", Role: roleName,
+		Groups: groups}, func() (interface{}, error) {
+		return nil, c.client.RemoveGroupsFromRole(roleName, groups)
+	})
+}
+
+// ListRoleByUser implements ClientAPI.ListRoleByUser(). The call result
+// is the list of role names.
+func (c *interceptedClient) ListRoleByUser(userName string) ([]string, []*Role, error) {
+	var names []string
+	var roles []*Role
+	err := c.invoke(&Call{Operation: "ListRoleByUser", Users: []string{userName}},
+		func() (result interface{}, err error) {
+			names, roles, err = c.client.ListRoleByUser(userName)
+			return names, err
+		})
+	return names, roles, err
+}
+
+// AddUsersToRole implements ClientAPI.AddUsersToRole()
+func (c *interceptedClient) AddUsersToRole(roleName string, users []string) error {
+	return c.invoke(&Call{Operation: "AddUsersToRole", Role: roleName,
+		Users: users}, func() (interface{}, error) {
+		return nil, c.client.AddUsersToRole(roleName, users)
+	})
+}
+
+// RemoveUsersFromRole implements ClientAPI.RemoveUsersFromRole()
+func (c *interceptedClient) RemoveUsersFromRole(roleName string, users []string) error {
+	return c.invoke(&Call{Operation: "RemoveUsersFromRole", Role: roleName,
+		Users: users}, func() (interface{}, error) {
+		return nil, c.client.RemoveUsersFromRole(roleName, users)
+	})
+}
+
+// GrantPrivilege implements ClientAPI.GrantPrivilege()
+func (c *interceptedClient) GrantPrivilege(roleName string, priv *Privilege) error {
+	return c.invoke(&Call{Operation: "GrantPrivilege", Role: roleName,
+		Privilege: priv}, func() (interface{}, error) {
+		return nil, c.client.GrantPrivilege(roleName, priv)
+	})
+}
+
+// RevokePrivilege implements ClientAPI.RevokePrivilege()
+func (c *interceptedClient) RevokePrivilege(roleName string, priv *Privilege) error {
+	return c.invoke(&Call{Operation: "RevokePrivilege", Role: roleName,
+		Privilege: priv}, func() (interface{}, error) {
+		return nil, c.client.RevokePrivilege(roleName, priv)
+	})
+}
+
+// ListPrivilegesByRole implements ClientAPI.ListPrivilegesByRole()
+func (c *interceptedClient) ListPrivilegesByRole(roleName string,
+	template *Privilege) ([]*Privilege, error) {
+	var privileges []*Privilege
+	err := c.invoke(&Call{Operation: "ListPrivilegesByRole", Role: roleName,
+		Privilege: template}, func() (result interface{}, err error) {
+		privileges, err = c.client.ListPrivilegesByRole(roleName, template)
+		return privileges, err
+	})
+	return privileges, err
+}
+
+// DropPrivilegesOnObject implements ClientAPI.DropPrivilegesOnObject()
+func (c *interceptedClient) DropPrivilegesOnObject(object *Privilege) error {
+	return c.invoke(&Call{Operation: "DropPrivilegesOnObject", Privilege: object},
+		func() (interface{}, error) {
+			return nil, c.client.DropPrivilegesOnObject(object)
+		})
+}
+
+// RenamePrivilegesOnObject implements ClientAPI.RenamePrivilegesOnObject()
+func (c *interceptedClient) RenamePrivilegesOnObject(from *Privilege, to *Privilege) error {
+	return c.invoke(&Call{Operation: "RenamePrivilegesOnObject", Privilege: from,
+		Target: to}, func() (interface{}, error) {
+		return nil, c.client.RenamePrivilegesOnObject(from, to)
+	})
+}
+
+// ListPrivilegesByObject implements ClientAPI.ListPrivilegesByObject()
+func (c *interceptedClient) ListPrivilegesByObject(object *Privilege,
+	groups []string) (map[string][]*Privilege, error) {
+	var privileges map[string][]*Privilege
+	err := c.invoke(&Call{Operation: "ListPrivilegesByObject", Privilege: object,
+		Groups: groups}, func() (result interface{}, err error) {
+		privileges, err = c.client.ListPrivilegesByObject(object, groups)
+		return privileges, err
+	})
+	return privileges, err
+}
+
+// EffectivePrivileges implements ClientAPI.EffectivePrivileges()
+func (c *interceptedClient) EffectivePrivileges(groups []string, users []string,
+	activeRoles []string, object *Privilege) ([]string, error) {
+	var privileges []string
+	err := c.invoke(&Call{Operation: "EffectivePrivileges", Groups: groups,
+		Users: users, Privilege: object}, func() (result interface{}, err error) {
+		privileges, err = c.client.EffectivePrivileges(groups, users,
+			activeRoles, object)
+		return privileges, err
+	})
+	return privileges, err
+}
+
+// GetConfigValue implements ClientAPI.GetConfigValue()
+func (c *interceptedClient) GetConfigValue(name string,
+	defaultValue string) (string, error) {
+	var value string
+	err := c.invoke(&Call{Operation: "GetConfigValue"},
+		func() (result interface{}, err error) {
+			value, err = c.client.GetConfigValue(name, defaultValue)
+			return value, err
+		})
+	return value, err
+}
@@ -0,0 +1,124 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/akolb1/sentrytool/sentryapi/sentrytest"
+)
+
+func TestInterceptors(t *testing.T) {
+	server, err := sentrytest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	var order []string
+	var calls []*sentryapi.Call
+	errDenied := errors.New("denied by policy")
+	trace := func(name string) sentryapi.Interceptor {
+		return func(call *sentryapi.Call, next sentryapi.Invoker) error {
+			order = append(order, name+":"+call.Operation)
+			return next(call)
+		}
+	}
+	record := func(call *sentryapi.Call, next sentryapi.Invoker) error {
+		err := next(call)
+		calls = append(calls, call)
+		return err
+	}
+	deny := func(call *sentryapi.Call, next sentryapi.Invoker) error {
+		if call.Operation == "RemoveRole" {
+			return errDenied
+		}
+		return next(call)
+	}
+	var timed []string
+	timing := sentryapi.NewTimingInterceptor(func(operation string,
+		elapsed time.Duration, err error) {
+		timed = append(timed, operation)
+	})
+	logBuf := &bytes.Buffer{}
+	client, err := sentryapi.GetClient(sentryapi.PolicyProtocol, server.Host,
+		server.Port, "", "admin", sentryapi.WithInterceptors(trace("outer"),
+			trace("inner"), record, timing,
+			sentryapi.NewLoggingInterceptor(log.New(logBuf, "", 0)), deny))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.CreateRole("r1"); err != nil {
+		t.Fatal(err)
+	}
+	priv := &sentryapi.Privilege{Server: "server1", Database: "db1", Action: "select"}
+	if err := client.GrantPrivilege("r1", priv); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddGroupsToRole("r1", []string{"g1", "g2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := client.ListRoleByGroup("g1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveRole("r1"); err != errDenied {
+		t.Errorf("expected policy error, got %v", err)
+	}
+	if err := client.CreateRole("r1"); !errors.Is(err, sentryapi.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	if !reflect.DeepEqual(order[:2], []string{"outer:CreateRole", "inner:CreateRole"}) {
+		t.Errorf("unexpected interceptor order %v", order)
+	}
+	if len(calls) != 6 || len(timed) != 6 {
+		t.Fatalf("expected 6 intercepted calls, got %d and %d", len(calls), len(timed))
+	}
+	if calls[1].Role != "r1" || calls[1].Privilege != priv ||
+		calls[1].Host != fmt.Sprintf("%s:%d", server.Host, server.Port) {
+		t.Errorf("unexpected grant call %+v", calls[1])
+	}
+	if !reflect.DeepEqual(calls[2].Groups, []string{"g1", "g2"}) {
+		t.Errorf("unexpected groups %v", calls[2].Groups)
+	}
+	if !reflect.DeepEqual(calls[3].Result, []string{"r1"}) {
+		t.Errorf("unexpected result %v", calls[3].Result)
+	}
+
+	lines := strings.Split(strings.TrimSpace(logBuf.String()), "\n")
+	expected := []string{
+		"operation=CreateRole role=r1 status=ok",
+		`operation=GrantPrivilege role=r1 privilege="server=server1->db=db1->action=select" status=ok`,
+		"operation=AddGroupsToRole role=r1 groups=g1,g2 status=ok",
+		"operation=ListRoleByGroup groups=g1 status=ok",
+		`operation=RemoveRole role=r1 error="denied by policy"`,
+	}
+	if len(lines) != 6 || !reflect.DeepEqual(lines[:5], expected) {
+		t.Errorf("unexpected log:\n%s", logBuf.String())
+	}
+	if !strings.HasPrefix(lines[5], "operation=CreateRole role=r1 error=") {
+		t.Errorf("unexpected log line %s", lines[5])
+	}
+}
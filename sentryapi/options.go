@@ -0,0 +1,169 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"crypto/tls"
+	"sync"
+	"time"
+)
+
+// ClientOption sets optional parameters of the Sentry client
+type ClientOption func(*clientOptions)
+
+// clientOptions is a collection of optional client parameters
+type clientOptions struct {
+	sasl           SASLMechanism
+	saslLock       *sync.Mutex
+	tlsConfig      *tls.Config
+	connectTimeout time.Duration
+	timeout        time.Duration
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	reporter       CallReporter
+	maxOpen        int
+	maxIdle        int
+	healthCheck    func(client ClientAPI) error
+	checkIdleTime  time.Duration
+	version        int32
+	recorder       *Recorder
+	recording      *Recording
+	interceptors   []Interceptor
+}
+
+// newClientOptions applies all options to the default client options
+func newClientOptions(opts []ClientOption) *clientOptions {
+	options := &clientOptions{
+		minBackoff: time.Second,
+		maxBackoff: time.Minute,
+		maxIdle:    2,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithSASL enables SASL authentication of the Thrift transport using the
+// given mechanism. The mechanism is restarted for every new connection and
+// connections sharing the mechanism are never negotiated concurrently.
+func WithSASL(mech SASLMechanism) ClientOption {
+	lock := &sync.Mutex{}
+	return func(o *clientOptions) {
+		o.sasl = mech
+		o.saslLock = lock
+	}
+}
+
+// WithTLS enables TLS for the Thrift connection. NewTLSConfig() can be used
+// to build the configuration from certificate files.
+func WithTLS(config *tls.Config) ClientOption {
+	return func(o *clientOptions) {
+		o.tlsConfig = config
+	}
+}
+
+// WithConnectTimeout limits the time to establish the connection, including
+// TLS handshake and SASL negotiation. Zero means no timeout.
+func WithConnectTimeout(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.connectTimeout = timeout
+	}
+}
+
+// WithTimeout limits the time of each read or write on the connection.
+// Zero means no timeout.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// CallReporter is called by HA client after each call.
+//   operation - ClientAPI method name, e.g. "CreateRole"
+//   host - host:port which served the call, empty if no host was available
+//   err - call result
+type CallReporter func(operation string, host string, err error)
+
+// WithCallReporter sets function which is called after each HA client call
+func WithCallReporter(reporter CallReporter) ClientOption {
+	return func(o *clientOptions) {
+		o.reporter = reporter
+	}
+}
+
+// WithFailoverBackoff sets for how long HA client considers a failed host
+// down. The time doubles with each consecutive failure from min up to max.
+// Defaults are one second and one minute.
+func WithFailoverBackoff(min time.Duration, max time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// WithMaxOpen limits the number of connections opened by the pooled client.
+// Callers wait for a free connection when the limit is reached. Zero means
+// no limit, which is the default.
+func WithMaxOpen(maxOpen int) ClientOption {
+	return func(o *clientOptions) {
+		o.maxOpen = maxOpen
+	}
+}
+
+// WithMaxIdle sets the number of idle connections kept by the pooled client.
+// The default is 2.
+func WithMaxIdle(maxIdle int) ClientOption {
+	return func(o *clientOptions) {
+		o.maxIdle = maxIdle
+	}
+}
+
+// WithHealthCheck sets function used by the pooled client to verify
+// connections which were idle for longer than idleTime. Connections failing
+// the check are closed.
+func WithHealthCheck(check func(client ClientAPI) error,
+	idleTime time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.healthCheck = check
+		o.checkIdleTime = idleTime
+	}
+}
+
+// WithProtocolVersion sets Sentry Thrift protocol version, e.g.
+// ProtocolVersion1. By default the client uses ProtocolVersion2 and falls
+// back to ProtocolVersion1 if the server doesn't support it.
+func WithProtocolVersion(version int32) ClientOption {
+	return func(o *clientOptions) {
+		o.version = version
+	}
+}
+
+// WithRecorder records every Thrift request and response of the client.
+// Recording happens above TLS and SASL, so the data is not encrypted.
+func WithRecorder(recorder *Recorder) ClientOption {
+	return func(o *clientOptions) {
+		o.recorder = recorder
+	}
+}
+
+// WithReplay serves Thrift calls from the recording instead of connecting
+// to the Sentry server. Calls are matched to the recording by service and
+// method in the recorded order.
+func WithReplay(recording *Recording) ClientOption {
+	return func(o *clientOptions) {
+		o.recording = recording
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"encoding/json"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Policy is a complete Sentry policy document which can be exported from
+// one Sentry server and imported into another one.
+// Attributes:
+//   Groups - map from group name to the list of its roles
+//   Users - map from user name to the list of its roles
+//   Privileges - map from role name to the list of its privileges
+type Policy struct {
+	Groups     map[string][]string     `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Users      map[string][]string     `json:"users,omitempty" yaml:"users,omitempty"`
+	Privileges map[string][]*Privilege `json:"privileges,omitempty" yaml:"privileges,omitempty"`
+}
+
+// NewPolicy returns an empty policy document
+func NewPolicy() *Policy {
+	return &Policy{
+		Groups:     make(map[string][]string),
+		Users:      make(map[string][]string),
+		Privileges: make(map[string][]*Privilege),
+	}
+}
+
+// Sort sorts role lists by name and privilege lists by Privilege.Key(), so
+// that exported policies of the same server are identical
+func (p *Policy) Sort() {
+	for _, roles := range p.Groups {
+		sort.Strings(roles)
+	}
+	for _, roles := range p.Users {
+		sort.Strings(roles)
+	}
+	for _, privs := range p.Privileges {
+		sort.Slice(privs, func(i, j int) bool {
+			return privs[i].Key() < privs[j].Key()
+		})
+	}
+}
+
+// ToJSON returns indented JSON representation of the policy
+func (p *Policy) ToJSON() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// ToYAML returns YAML representation of the policy
+func (p *Policy) ToYAML() ([]byte, error) {
+	return yaml.Marshal(p)
+}
+
+// PolicyFromJSON parses policy document in JSON format
+func PolicyFromJSON(data []byte) (*Policy, error) {
+	policy := NewPolicy()
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// PolicyFromYAML parses policy document in YAML format
+func PolicyFromYAML(data []byte) (*Policy, error) {
+	policy := NewPolicy()
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
@@ -0,0 +1,51 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPolicy_Sort(t *testing.T) {
+	policy := NewPolicy()
+	policy.Groups["g1"] = []string{"r2", "r1"}
+	policy.Users["u1"] = []string{"r3", "r1", "r2"}
+	policy.Privileges["r1"] = []*Privilege{
+		{Server: "server1", Database: "sales", Table: "t", Action: "select"},
+		{Server: "server1", URI: "hdfs://nn/a", Action: "all"},
+		{Server: "server1", Database: "hr", Action: "insert"},
+	}
+	policy.Sort()
+
+	if !reflect.DeepEqual(policy.Groups["g1"], []string{"r1", "r2"}) {
+		t.Errorf("unexpected group roles %v", policy.Groups["g1"])
+	}
+	if !reflect.DeepEqual(policy.Users["u1"], []string{"r1", "r2", "r3"}) {
+		t.Errorf("unexpected user roles %v", policy.Users["u1"])
+	}
+	var privs []string
+	for _, priv := range policy.Privileges["r1"] {
+		privs = append(privs, priv.String())
+	}
+	expected := []string{
+		"server=server1->db=hr->action=insert",
+		"server=server1->db=sales->table=t->action=select",
+		"server=server1->uri=hdfs://nn/a->action=all",
+	}
+	if !reflect.DeepEqual(privs, expected) {
+		t.Errorf("expected %v, got %v", expected, privs)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrClientClosed is returned by the pooled client after Close()
+var ErrClientClosed = errors.New("sentry client is closed")
+
+// pooledConn is a connection kept by the pool
+type pooledConn struct {
+	client   ClientAPI
+	returned time.Time
+}
+
+// pooledClient is ClientAPI which is safe for concurrent use. Each call
+// takes a connection from the pool, so concurrent calls use different
+// connections.
+type pooledClient struct {
+	options *clientOptions
+	connect func() (ClientAPI, error)
+	lock    sync.Mutex
+	freed   *sync.Cond
+	idle    []*pooledConn
+	open    int
+	closed  bool
+}
+
+// NewPooledClient returns goroutine-safe ClientAPI which keeps a pool of
+// connections to the endpoints. Each connection is an HA client, so it
+// fails over between endpoints as described for NewHAClient(). All
+// connections share the endpoint state, so an endpoint which is down is
+// skipped by every connection.
+// Pool specific options are WithMaxOpen(), WithMaxIdle() and
+// WithHealthCheck().
+func NewPooledClient(protocol ProtocolType, endpoints []Endpoint,
+	component string, user string, opts ...ClientOption) (ClientAPI, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("no Sentry hosts specified")
+	}
+	options := newClientOptions(opts)
+	state := newHAState(endpoints, options)
+	return newPool(func() (ClientAPI, error) {
+		return newHAClient(state, protocol, component, user, opts), nil
+	}, options), nil
+}
+
+// newPool returns pooled client using connect to open new connections
+func newPool(connect func() (ClientAPI, error),
+	options *clientOptions) *pooledClient {
+	p := &pooledClient{options: options, connect: connect}
+	p.freed = sync.NewCond(&p.lock)
+	return p
+}
+
+// get returns a connection from the pool, opening a new one if there are
+// no idle connections. It waits for a connection to be returned when
+// the maximum number of connections is open.
+func (p *pooledClient) get() (ClientAPI, error) {
+	p.lock.Lock()
+	for {
+		if p.closed {
+			p.lock.Unlock()
+			return nil, ErrClientClosed
+		}
+		if n := len(p.idle); n != 0 {
+			conn := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.lock.Unlock()
+			if p.healthy(conn) {
+				return conn.client, nil
+			}
+			conn.client.Close()
+			p.lock.Lock()
+			p.open--
+			continue
+		}
+		if p.options.maxOpen <= 0 || p.open < p.options.maxOpen {
+			p.open++
+			p.lock.Unlock()
+			client, err := p.connect()
+			if err != nil {
+				p.lock.Lock()
+				p.open--
+				p.freed.Signal()
+				p.lock.Unlock()
+				return nil, err
+			}
+			return client, nil
+		}
+		p.freed.Wait()
+	}
+}
+
+// healthy returns true if the idle connection can be used
+func (p *pooledClient) healthy(conn *pooledConn) bool {
+	if p.options.healthCheck == nil ||
+		time.Since(conn.returned) < p.options.checkIdleTime {
+		return true
+	}
+	return p.options.healthCheck(conn.client) == nil
+}
+
+// put returns connection to the pool. Broken connections and connections
+// exceeding the idle limit are closed.
+func (p *pooledClient) put(client ClientAPI) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	defer p.freed.Signal()
+	if p.closed || clientBroken(client) || len(p.idle) >= p.options.maxIdle {
+		client.Close()
+		p.open--
+		return
+	}
+	p.idle = append(p.idle, &pooledConn{client: client, returned: time.Now()})
+}
+
+// do runs the call on a pooled connection
+func (p *pooledClient) do(fn func(client ClientAPI) error) error {
+	client, err := p.get()
+	if err != nil {
+		return err
+	}
+	defer p.put(client)
+	return fn(client)
+}
+
+// Close closes idle connections. Connections in use are closed when calls
+// using them complete.
+func (p *pooledClient) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.closed = true
+	for _, conn := range p.idle {
+		conn.client.Close()
+		p.open--
+	}
+	p.idle = nil
+	p.freed.Broadcast()
+}
+
+// CreateRole implements ClientAPI.CreateRole()
+func (p *pooledClient) CreateRole(roleName string) error {
+	return p.do(func(client ClientAPI) error {
+		return client.CreateRole(roleName)
+	})
+}
+
+// RemoveRole implements ClientAPI.RemoveRole()
+func (p *pooledClient) RemoveRole(roleName string) error {
+	return p.do(func(client ClientAPI) error {
+		return client.RemoveRole(roleName)
+	})
+}
+
+// ListRoleByGroup implements ClientAPI.ListRoleByGroup()
+func (p *pooledClient) ListRoleByGroup(groupName string) ([]string, []*Role, error) {
+	var names []string
+	var roles []*Role
+	err := p.do(func(client ClientAPI) (err error) {
+		names, roles, err = client.ListRoleByGroup(groupName)
+		return err
+	})
+	return names, roles, err
+}
+
+// AddGroupsToRole implements ClientAPI.AddGroupsToRole()
+func (p *pooledClient) AddGroupsToRole(roleName string, groups []string) error {
+	return p.do(func(client ClientAPI) error {
+		return client.AddGroupsToRole(roleName, groups)
+	})
+}
+
+// RemoveGroupsFromRole implements ClientAPI.RemoveGroupsFromRole()
+func (p *pooledClient) RemoveGroupsFromRole(roleName string, groups []string) error {
+	return p.do(func(client ClientAPI) error {
+		return client.RemoveGroupsFromRole(roleName, groups)
+	})
+}
+
+// ListRoleByUser implements ClientAPI.ListRoleByUser()
+func (p *pooledClient) ListRoleByUser(userName string) ([]string, []*Role, error) {
+	var names []string
+	var roles []*Role
+	err := p.do(func(client ClientAPI) (err error) {
+		names, roles, err = client.ListRoleByUser(userName)
+		return err
+	})
+	return names, roles, err
+}
+
+// AddUsersToRole implements ClientAPI.AddUsersToRole()
+func (p *pooledClient) AddUsersToRole(roleName string, users []string) error {
+	return p.do(func(client ClientAPI) error {
+		return client.AddUsersToRole(roleName, users)
+	})
+}
+
+// RemoveUsersFromRole implements ClientAPI.RemoveUsersFromRole()
+func (p *pooledClient) RemoveUsersFromRole(roleName string, users []string) error {
+	return p.do(func(client ClientAPI) error {
+		return client.RemoveUsersFromRole(roleName, users)
+	})
+}
+
+// GrantPrivilege implements ClientAPI.GrantPrivilege()
+func (p *pooledClient) GrantPrivilege(roleName string, priv *Privilege) error {
+	return p.do(func(client ClientAPI) error {
+		return client.GrantPrivilege(roleName, priv)
+	})
+}
+
+// RevokePrivilege implements ClientAPI.RevokePrivilege()
+func (p *pooledClient) RevokePrivilege(roleName string, priv *Privilege) error {
+	return p.do(func(client ClientAPI) error {
+		return client.RevokePrivilege(roleName, priv)
+	})
+}
+
+// ListPrivilegesByRole implements ClientAPI.ListPrivilegesByRole()
+func (p *pooledClient) ListPrivilegesByRole(roleName string,
+	template *Privilege) ([]*Privilege, error) {
+	var privileges []*Privilege
+	err := p.do(func(client ClientAPI) (err error) {
+		privileges, err = client.ListPrivilegesByRole(roleName, template)
+		return err
+	})
+	return privileges, err
+}
+
+// DropPrivilegesOnObject implements ClientAPI.DropPrivilegesOnObject()
+func (p *pooledClient) DropPrivilegesOnObject(object *Privilege) error {
+	return p.do(func(client ClientAPI) error {
+		return client.DropPrivilegesOnObject(object)
+	})
+}
+
+// RenamePrivilegesOnObject implements ClientAPI.RenamePrivilegesOnObject()
+func (p *pooledClient) RenamePrivilegesOnObject(from *Privilege, to *Privilege) error {
+	return p.do(func(client ClientAPI) error {
+		return client.RenamePrivilegesOnObject(from, to)
+	})
+}
+
+// ListPrivilegesByObject implements ClientAPI.ListPrivilegesByObject()
+func (p *pooledClient) ListPrivilegesByObject(object *Privilege,
+	groups []string) (map[string][]*Privilege, error) {
+	var privileges map[string][]*Privilege
+	err := p.do(func(client ClientAPI) (err error) {
+		privileges, err = client.ListPrivilegesByObject(object, groups)
+		return err
+	})
+	return privileges, err
+}
+
+// EffectivePrivileges implements ClientAPI.EffectivePrivileges()
+func (p *pooledClient) EffectivePrivileges(groups []string, users []string,
+	activeRoles []string, object *Privilege) ([]string, error) {
+	var privileges []string
+	err := p.do(func(client ClientAPI) (err error) {
+		privileges, err = client.EffectivePrivileges(groups, users,
+			activeRoles, object)
+		return err
+	})
+	return privileges, err
+}
+
+// GetConfigValue implements ClientAPI.GetConfigValue()
+func (p *pooledClient) GetConfigValue(name string,
+	defaultValue string) (string, error) {
+	var value string
+	err := p.do(func(client ClientAPI) (err error) {
+		value, err = client.GetConfigValue(name, defaultValue)
+		return err
+	})
+	return value, err
+}
@@ -0,0 +1,150 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// poolFakeClient tracks how many calls are running concurrently
+type poolFakeClient struct {
+	ClientAPI
+	counter  *poolCounter
+	isBroken bool
+	closed   bool
+}
+
+type poolCounter struct {
+	sync.Mutex
+	opened    int
+	active    int
+	maxActive int
+}
+
+func (c *poolFakeClient) Close() {
+	c.closed = true
+}
+
+func (c *poolFakeClient) broken() bool {
+	return c.isBroken
+}
+
+func (c *poolFakeClient) CreateRole(roleName string) error {
+	c.counter.Lock()
+	c.counter.active++
+	if c.counter.active > c.counter.maxActive {
+		c.counter.maxActive = c.counter.active
+	}
+	c.counter.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	c.counter.Lock()
+	c.counter.active--
+	c.counter.Unlock()
+	if roleName == "break" {
+		c.isBroken = true
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func newTestPool(counter *poolCounter, opts ...ClientOption) *pooledClient {
+	return newPool(func() (ClientAPI, error) {
+		counter.Lock()
+		defer counter.Unlock()
+		counter.opened++
+		return &poolFakeClient{counter: counter}, nil
+	}, newClientOptions(opts))
+}
+
+func TestPooledClient_Concurrent(t *testing.T) {
+	counter := &poolCounter{}
+	pool := newTestPool(counter, WithMaxOpen(3), WithMaxIdle(3))
+	defer pool.Close()
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := pool.CreateRole("role"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if counter.opened > 3 {
+		t.Errorf("expected at most 3 connections, got %d", counter.opened)
+	}
+	if counter.maxActive < 2 || counter.maxActive > 3 {
+		t.Errorf("expected 2-3 concurrent calls, got %d", counter.maxActive)
+	}
+	if len(pool.idle) != pool.open {
+		t.Errorf("expected all %d connections idle, got %d", pool.open,
+			len(pool.idle))
+	}
+}
+
+func TestPooledClient_Broken(t *testing.T) {
+	counter := &poolCounter{}
+	pool := newTestPool(counter)
+	if err := pool.CreateRole("break"); err == nil {
+		t.Error("expected error")
+	}
+	if pool.open != 0 || len(pool.idle) != 0 {
+		t.Errorf("broken connection should be closed, %d open", pool.open)
+	}
+	if err := pool.CreateRole("role"); err != nil {
+		t.Error(err)
+	}
+	if counter.opened != 2 {
+		t.Errorf("expected new connection, opened %d", counter.opened)
+	}
+}
+
+func TestPooledClient_HealthCheck(t *testing.T) {
+	counter := &poolCounter{}
+	checked := 0
+	pool := newTestPool(counter, WithHealthCheck(func(client ClientAPI) error {
+		checked++
+		return errors.New("unhealthy")
+	}, 0))
+	for i := 0; i < 2; i++ {
+		if err := pool.CreateRole("role"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if checked != 1 || counter.opened != 2 {
+		t.Errorf("expected 1 check and 2 connections, got %d and %d",
+			checked, counter.opened)
+	}
+}
+
+func TestPooledClient_Close(t *testing.T) {
+	counter := &poolCounter{}
+	pool := newTestPool(counter)
+	if err := pool.CreateRole("role"); err != nil {
+		t.Fatal(err)
+	}
+	conn := pool.idle[0].client.(*poolFakeClient)
+	pool.Close()
+	if !conn.closed {
+		t.Error("idle connection wasn't closed")
+	}
+	if err := pool.CreateRole("role"); err != ErrClientClosed {
+		t.Errorf("expected ErrClientClosed, got %v", err)
+	}
+}
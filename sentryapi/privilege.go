@@ -0,0 +1,408 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Privilege string syntax is the one used by Sentry policy files and
+// providers:
+//
+//   privilege := segment { "->" segment }
+//   segment   := key "=" value
+//   key       := letter { letter | digit | "_" }
+//   value     := bare | quoted
+//   quoted    := '"' { char | "\" char } '"'
+//
+// Bare values end at the next "->" and may contain "=", e.g.
+// uri=hdfs://nn/a=b. Backslash escapes the next character in both bare and
+// quoted values. Spaces around separators are ignored, so values with
+// leading or trailing spaces must be quoted.
+const (
+	segmentSeparator = "->"
+	keySeparator     = "="
+
+	serverKey      = "server"
+	dbKey          = "db"
+	tableKey       = "table"
+	columnKey      = "column"
+	uriKey         = "uri"
+	serviceKey     = "service"
+	actionKey      = "action"
+	grantOptionKey = "grantoption"
+	scopeKey       = "scope"
+	grantorKey     = "grantor"
+	createTimeKey  = "createtime"
+
+	grantOptionUnset = "unset"
+)
+
+// PrivilegeSyntaxError describes invalid privilege string.
+// Attributes:
+//   Input - privilege string
+//   Column - 1-based position of the error in the input, in characters
+//   Msg - error description
+type PrivilegeSyntaxError struct {
+	Input  string
+	Column int
+	Msg    string
+}
+
+func (e *PrivilegeSyntaxError) Error() string {
+	return fmt.Sprintf("invalid privilege '%s' at column %d: %s", e.Input,
+		e.Column, e.Msg)
+}
+
+// ParsePrivilege parses legacy (Hive) model privilege string like
+// server=server1->db=sales->table=orders->action=select->grantoption=true.
+// Valid keys are server, db, table, column, uri, service, scope, action,
+// grantoption, grantor and createtime. The grantoption value is true, false or
+// unset, the createtime value is an integer.
+func ParsePrivilege(s string) (*Privilege, error) {
+	return parsePrivilege(s, false)
+}
+
+// ParseGenericPrivilege parses generic model privilege string like
+// topic=clicks->action=read. Any key other than service, scope, action,
+// grantoption, grantor and createtime is an authorizable type, authorizables
+// are kept in the order they appear.
+func ParseGenericPrivilege(s string) (*Privilege, error) {
+	return parsePrivilege(s, true)
+}
+
+// String returns privilege in the syntax accepted by ParsePrivilege() or
+// ParseGenericPrivilege(), depending on the privilege model. Values are
+// quoted when needed.
+func (p *Privilege) String() string {
+	parts := []string{}
+	add := func(key string, value string) {
+		if value != "" {
+			parts = append(parts, key+keySeparator+quoteValue(value))
+		}
+	}
+	add(serverKey, p.Server)
+	add(dbKey, p.Database)
+	add(tableKey, p.Table)
+	add(columnKey, p.Column)
+	add(uriKey, p.URI)
+	add(serviceKey, p.Service)
+	for _, auth := range p.Authorizables {
+		parts = append(parts, auth.Type+keySeparator+quoteValue(auth.Name))
+	}
+	add(scopeKey, p.Scope)
+	add(actionKey, p.Action)
+	if p.GrantOption {
+		add(grantOptionKey, "true")
+	} else if p.UnsetGrantOption {
+		add(grantOptionKey, grantOptionUnset)
+	}
+	add(grantorKey, p.Grantor)
+	if p.CreateTime != 0 {
+		add(createTimeKey, strconv.FormatInt(p.CreateTime, 10))
+	}
+	return strings.Join(parts, segmentSeparator)
+}
+
+// quoteValue quotes the value if it can't be represented as a bare value
+func quoteValue(value string) string {
+	if value != "" && value == strings.TrimSpace(value) &&
+		!strings.ContainsAny(value, "\"\\") &&
+		!strings.Contains(value, segmentSeparator) {
+		return value
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		if value[i] == '"' || value[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(value[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// privilegeParser keeps the state of parsing a single privilege string
+type privilegeParser struct {
+	input   string
+	pos     int
+	generic bool
+	seen    map[string]bool
+}
+
+func parsePrivilege(s string, generic bool) (*Privilege, error) {
+	p := &privilegeParser{input: s, generic: generic, seen: make(map[string]bool)}
+	priv := &Privilege{}
+	p.skipSpaces()
+	if p.pos == len(s) {
+		return nil, p.errorf(p.pos, "empty privilege")
+	}
+	for {
+		keyPos := p.pos
+		key := p.key()
+		if key == "" {
+			return nil, p.errorf(p.pos, "expected key")
+		}
+		p.skipSpaces()
+		if !strings.HasPrefix(s[p.pos:], keySeparator) {
+			return nil, p.errorf(p.pos, "expected '=' after %s", key)
+		}
+		p.pos += len(keySeparator)
+		p.skipSpaces()
+		valuePos := p.pos
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			return nil, p.errorf(valuePos, "empty value for %s", key)
+		}
+		if err := p.set(priv, key, keyPos, value, valuePos); err != nil {
+			return nil, err
+		}
+		if p.pos == len(s) {
+			return priv, nil
+		}
+		// value() stops at the separator or at the end
+		p.pos += len(segmentSeparator)
+		p.skipSpaces()
+	}
+}
+
+// errorf returns syntax error at the given offset
+func (p *privilegeParser) errorf(pos int, format string,
+	args ...interface{}) error {
+	return &PrivilegeSyntaxError{
+		Input:  p.input,
+		Column: utf8.RuneCountInString(p.input[:pos]) + 1,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *privilegeParser) skipSpaces() {
+	for p.pos < len(p.input) && isSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// key reads the key, empty key means there is no valid key at the position
+func (p *privilegeParser) key() string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		isLetter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+		isDigit := c >= '0' && c <= '9'
+		if !isLetter && !(p.pos > start && (isDigit || c == '_')) {
+			break
+		}
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// value reads bare or quoted value and positions the parser at the
+// following separator or at the end of input
+func (p *privilegeParser) value() (string, error) {
+	var b strings.Builder
+	if p.pos < len(p.input) && p.input[p.pos] == '"' {
+		start := p.pos
+		p.pos++
+		for {
+			if p.pos == len(p.input) {
+				return "", p.errorf(start, "unterminated quoted value")
+			}
+			c := p.input[p.pos]
+			p.pos++
+			if c == '"' {
+				break
+			}
+			if c == '\\' {
+				if p.pos == len(p.input) {
+					return "", p.errorf(p.pos-1, "unterminated escape")
+				}
+				c = p.input[p.pos]
+				p.pos++
+			}
+			b.WriteByte(c)
+		}
+		p.skipSpaces()
+		if p.pos < len(p.input) &&
+			!strings.HasPrefix(p.input[p.pos:], segmentSeparator) {
+			return "", p.errorf(p.pos, "expected '%s' after quoted value",
+				segmentSeparator)
+		}
+		return b.String(), nil
+	}
+	// Bare value, trailing spaces are dropped unless escaped
+	kept := 0
+	for p.pos < len(p.input) &&
+		!strings.HasPrefix(p.input[p.pos:], segmentSeparator) {
+		c := p.input[p.pos]
+		p.pos++
+		if c == '\\' {
+			if p.pos == len(p.input) {
+				return "", p.errorf(p.pos-1, "unterminated escape")
+			}
+			b.WriteByte(p.input[p.pos])
+			p.pos++
+			kept = b.Len()
+			continue
+		}
+		if c == '"' {
+			return "", p.errorf(p.pos-1, "unexpected quote in value")
+		}
+		b.WriteByte(c)
+		if !isSpace(c) {
+			kept = b.Len()
+		}
+	}
+	return b.String()[:kept], nil
+}
+
+// set assigns the value to the privilege field identified by the key
+func (p *privilegeParser) set(priv *Privilege, key string, keyPos int,
+	value string, valuePos int) error {
+	name := strings.ToLower(key)
+	if p.seen[name] {
+		return p.errorf(keyPos, "duplicate %s", key)
+	}
+	p.seen[name] = true
+	switch name {
+	case actionKey:
+		priv.Action = value
+		return nil
+	case serviceKey:
+		priv.Service = value
+		return nil
+	case scopeKey:
+		priv.Scope = value
+		return nil
+	case grantOptionKey:
+		switch strings.ToLower(value) {
+		case "true":
+			priv.GrantOption = true
+		case "false":
+		case grantOptionUnset:
+			priv.UnsetGrantOption = true
+		default:
+			return p.errorf(valuePos, "invalid grantoption '%s', expected true, false or unset",
+				value)
+		}
+		return nil
+	case grantorKey:
+		priv.Grantor = value
+		return nil
+	case createTimeKey:
+		createTime, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return p.errorf(valuePos, "invalid createtime '%s', expected integer",
+				value)
+		}
+		priv.CreateTime = createTime
+		return nil
+	}
+	if p.generic {
+		priv.Authorizables = append(priv.Authorizables,
+			Authorizable{Type: key, Name: value})
+		return nil
+	}
+	switch name {
+	case serverKey:
+		priv.Server = value
+	case dbKey:
+		priv.Database = value
+	case tableKey:
+		priv.Table = value
+	case columnKey:
+		priv.Column = value
+	case uriKey:
+		priv.URI = value
+	default:
+		return p.errorf(keyPos, "unknown key %s", key)
+	}
+	return nil
+}
+
+// canonicalAction returns lower case action with "all" spelled as allAction
+func canonicalAction(action string) string {
+	action = strings.ToLower(action)
+	if action == "all" {
+		return allAction
+	}
+	return action
+}
+
+// IsGeneric returns true for generic model privileges which are identified
+// by service and authorizables rather than by Hive objects
+func (p *Privilege) IsGeneric() bool {
+	return p.Service != "" || len(p.Authorizables) != 0
+}
+
+// Normalize returns a copy of the privilege in the canonical form used by
+// Sentry. Names of servers, databases, tables and columns are lower case in
+// the legacy model, URIs are case sensitive. Service, authorizable types and
+// names are lower case in the generic model. Actions are lower case with
+// "all" and "*" both spelled as "*". Scope is upper case.
+func (p *Privilege) Normalize() *Privilege {
+	priv := *p
+	priv.Server = strings.ToLower(p.Server)
+	priv.Database = strings.ToLower(p.Database)
+	priv.Table = strings.ToLower(p.Table)
+	priv.Column = strings.ToLower(p.Column)
+	priv.Service = strings.ToLower(p.Service)
+	priv.Action = canonicalAction(p.Action)
+	priv.Scope = strings.ToUpper(p.Scope)
+	priv.Authorizables = nil
+	for _, auth := range p.Authorizables {
+		priv.Authorizables = append(priv.Authorizables, Authorizable{
+			Type: strings.ToLower(auth.Type),
+			Name: strings.ToLower(auth.Name),
+		})
+	}
+	return &priv
+}
+
+// Key returns a string which is the same for privileges Sentry considers
+// equal, so it can be used as a map key. Privileges of the legacy and
+// generic model never have the same key. Scope, Grantor and CreateTime are
+// not part of the key.
+func (p *Privilege) Key() string {
+	priv := p.Normalize()
+	priv.Scope = ""
+	priv.UnsetGrantOption = false
+	priv.Grantor = ""
+	priv.CreateTime = 0
+	if priv.IsGeneric() {
+		return "generic:" + priv.String()
+	}
+	return priv.String()
+}
+
+// Equal returns true if both privileges refer to the same object with the
+// same action and grant option according to Sentry rules
+func (p *Privilege) Equal(other *Privilege) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+	return p.Key() == other.Key()
+}
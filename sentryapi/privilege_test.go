@@ -0,0 +1,338 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePrivilege(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Privilege
+	}{
+		{"server=server1->db=sales->table=orders->column=id->action=select",
+			Privilege{Server: "server1", Database: "sales", Table: "orders",
+				Column: "id", Action: "select"}},
+		{" DB = sales -> Action = ALL ", Privilege{Database: "sales", Action: "ALL"}},
+		{"server=server1->uri=hdfs://nn/a=b->action=all",
+			Privilege{Server: "server1", URI: "hdfs://nn/a=b", Action: "all"}},
+		{"db=sales->action=insert->grantoption=true",
+			Privilege{Database: "sales", Action: "insert", GrantOption: true}},
+		{"db=sales->grantoption=UNSET", Privilege{Database: "sales",
+			UnsetGrantOption: true}},
+		{"db=sales->grantoption=false", Privilege{Database: "sales"}},
+		{`uri="hdfs://nn/a->b"`, Privilege{URI: "hdfs://nn/a->b"}},
+		{`table=" spaced \"name\" "`, Privilege{Table: ` spaced "name" `}},
+		{`table=a\->b`, Privilege{Table: "a->b"}},
+		{"table=my table", Privilege{Table: "my table"}},
+		{"service=hive1->server=server1", Privilege{Service: "hive1",
+			Server: "server1"}},
+		{"db=sales->action=select->grantor=admin->createtime=1500000000000",
+			Privilege{Database: "sales", Action: "select", Grantor: "admin",
+				CreateTime: 1500000000000}},
+	}
+	for _, tt := range tests {
+		priv, err := ParsePrivilege(tt.input)
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(*priv, tt.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tt.input, tt.expected, *priv)
+		}
+	}
+}
+
+func TestParseGenericPrivilege(t *testing.T) {
+	priv, err := ParseGenericPrivilege(
+		"service=kafka1->host=*->topic=clicks->action=read->grantoption=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Privilege{
+		Service: "kafka1",
+		Authorizables: []Authorizable{
+			{Type: "host", Name: "*"},
+			{Type: "topic", Name: "clicks"},
+		},
+		Action:      "read",
+		GrantOption: true,
+	}
+	if !reflect.DeepEqual(*priv, expected) {
+		t.Errorf("expected %+v, got %+v", expected, *priv)
+	}
+	// Hive keys are authorizables in the generic model
+	if priv, err = ParseGenericPrivilege("server=s1->connector=c1"); err != nil {
+		t.Fatal(err)
+	}
+	if priv.Server != "" || len(priv.Authorizables) != 2 {
+		t.Errorf("unexpected generic privilege %+v", *priv)
+	}
+}
+
+func TestParsePrivilege_Errors(t *testing.T) {
+	tests := []struct {
+		input  string
+		column int
+	}{
+		{"", 1},
+		{"   ", 4},
+		{"db", 3},
+		{"db=", 4},
+		{"db=sales->", 11},
+		{"db=sales->->action=all", 11},
+		{"=sales", 1},
+		{"db=sales->topic=clicks", 11},
+		{"db=sales->DB=other", 11},
+		{`db="sales`, 4},
+		{`db="sales"x`, 11},
+		{`db=sa"les`, 6},
+		{`db=sales\`, 9},
+		{"db=sales->grantoption=yes", 23},
+		{"db=sales->createtime=now", 22},
+		{"db=продажи->bad=x", 13},
+	}
+	for _, tt := range tests {
+		_, err := ParsePrivilege(tt.input)
+		var syntaxErr *PrivilegeSyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: expected syntax error, got %v", tt.input, err)
+			continue
+		}
+		if syntaxErr.Column != tt.column {
+			t.Errorf("%q: expected error at column %d, got %v", tt.input,
+				tt.column, err)
+		}
+	}
+}
+
+func TestPrivilege_String(t *testing.T) {
+	tests := []struct {
+		priv     Privilege
+		expected string
+	}{
+		{Privilege{Server: "server1", Database: "sales", Action: "select",
+			GrantOption: true, Scope: "DATABASE"},
+			"server=server1->db=sales->scope=DATABASE->action=select->grantoption=true"},
+		{Privilege{Database: "sales", Action: "select", Grantor: "admin",
+			CreateTime: 1500000000000},
+			"db=sales->action=select->grantor=admin->createtime=1500000000000"},
+		{Privilege{URI: "hdfs://nn/a=b", Action: "all"},
+			"uri=hdfs://nn/a=b->action=all"},
+		{Privilege{Table: `a->"b"\`}, `table="a->\"b\"\\"`},
+		{Privilege{Table: " t "}, `table=" t "`},
+		{Privilege{Database: "sales", UnsetGrantOption: true},
+			"db=sales->grantoption=unset"},
+		{Privilege{Service: "solr1", Authorizables: []Authorizable{
+			{Type: "collection", Name: "logs"}}, Action: "query"},
+			"service=solr1->collection=logs->action=query"},
+	}
+	for _, tt := range tests {
+		if s := tt.priv.String(); s != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, s)
+		}
+	}
+}
+
+// checkRoundTrip verifies that parsing the string representation of parsed
+// privilege gives the same privilege
+func checkRoundTrip(t *testing.T, input string,
+	parse func(string) (*Privilege, error)) {
+	priv, err := parse(input)
+	if err != nil {
+		return
+	}
+	s := priv.String()
+	again, err := parse(s)
+	if err != nil {
+		t.Fatalf("%q: can't parse %q: %v", input, s, err)
+	}
+	if !reflect.DeepEqual(priv, again) {
+		t.Fatalf("%q: %q parsed as %+v, expected %+v", input, s, *again, *priv)
+	}
+}
+
+func FuzzParsePrivilege(f *testing.F) {
+	for _, seed := range []string{
+		"server=server1->db=sales->table=orders->column=id->action=select",
+		"server=server1->uri=hdfs://nn/a=b->action=all->grantoption=true",
+		`table=" spaced \"name\" "->grantoption=unset`,
+		`table=a\->b`,
+		"db=sales->->action=all",
+		"db=sales->grantor=\"a b\"->createtime=-1",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		checkRoundTrip(t, input, ParsePrivilege)
+	})
+}
+
+// checkStringRoundTrip verifies that parsing the string representation of
+// the privilege gives the same privilege. Empty privileges have no valid
+// string representation and are skipped.
+func checkStringRoundTrip(t *testing.T, priv *Privilege,
+	parse func(string) (*Privilege, error)) {
+	s := priv.String()
+	if s == "" {
+		return
+	}
+	parsed, err := parse(s)
+	if err != nil {
+		t.Fatalf("%+v: can't parse %q: %v", *priv, s, err)
+	}
+	if !reflect.DeepEqual(priv, parsed) {
+		t.Fatalf("%+v: %q parsed as %+v", *priv, s, *parsed)
+	}
+}
+
+// fuzzGrantOption sets grant option of the privilege from the fuzzer input
+func fuzzGrantOption(priv *Privilege, grant uint8) {
+	switch grant % 3 {
+	case 1:
+		priv.GrantOption = true
+	case 2:
+		priv.UnsetGrantOption = true
+	}
+}
+
+// isAuthorizableType returns true if the name can be used as generic
+// authorizable type in the privilege string
+func isAuthorizableType(name string) bool {
+	switch strings.ToLower(name) {
+	case serviceKey, scopeKey, actionKey, grantOptionKey, grantorKey,
+		createTimeKey:
+		return false
+	}
+	p := &privilegeParser{input: name}
+	return name != "" && p.key() == name
+}
+
+func FuzzPrivilege_String(f *testing.F) {
+	f.Add("server1", "sales", "orders", "id", "", "TABLE", "select", uint8(1),
+		"admin", int64(1500000000000))
+	f.Add("server1", "", "", "", "hdfs://nn/a=b", "URI", "all", uint8(2), "",
+		int64(0))
+	f.Add("s", `a->"b"\`, " t ", "", "", "", "*", uint8(0), ` "g" `, int64(-1))
+	f.Fuzz(func(t *testing.T, server, db, table, column, uri, scope,
+		action string, grant uint8, grantor string, createTime int64) {
+		priv := &Privilege{Server: server, Database: db, Table: table,
+			Column: column, URI: uri, Scope: scope, Action: action,
+			Grantor: grantor, CreateTime: createTime}
+		fuzzGrantOption(priv, grant)
+		checkStringRoundTrip(t, priv, ParsePrivilege)
+	})
+}
+
+func FuzzGenericPrivilege_String(f *testing.F) {
+	f.Add("kafka1", "host", "*", "topic", "clicks", "", "read", uint8(0),
+		"admin", int64(1500000000000))
+	f.Add("solr1", "collection", "a->b", "field", ` "f" `, "COLLECTION",
+		"query", uint8(1), "", int64(0))
+	f.Add("", "server", "s1", "", "", "", "*", uint8(2), "a->b", int64(-1))
+	f.Fuzz(func(t *testing.T, service, type1, name1, type2, name2, scope,
+		action string, grant uint8, grantor string, createTime int64) {
+		priv := &Privilege{Service: service, Scope: scope, Action: action,
+			Grantor: grantor, CreateTime: createTime}
+		for _, auth := range []Authorizable{{type1, name1}, {type2, name2}} {
+			if !isAuthorizableType(auth.Type) || auth.Name == "" ||
+				(len(priv.Authorizables) != 0 &&
+					strings.EqualFold(priv.Authorizables[0].Type, auth.Type)) {
+				continue
+			}
+			priv.Authorizables = append(priv.Authorizables, auth)
+		}
+		if !priv.IsGeneric() {
+			return
+		}
+		fuzzGrantOption(priv, grant)
+		checkStringRoundTrip(t, priv, ParseGenericPrivilege)
+	})
+}
+
+func FuzzParseGenericPrivilege(f *testing.F) {
+	for _, seed := range []string{
+		"service=kafka1->host=*->topic=clicks->action=read",
+		`collection="a->b"->action=query->grantoption=true`,
+		"server=s1->connector=c1->action=*",
+		"topic=t1->grantor=admin->createtime=1500000000000",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		checkRoundTrip(t, input, ParseGenericPrivilege)
+	})
+}
+
+func TestPrivilege_Normalize(t *testing.T) {
+	priv := &Privilege{Server: "Server1", Database: "Sales", Table: "Orders",
+		Column: "ID", URI: "hdfs://NN/Path", Action: "ALL", Scope: "table"}
+	expected := Privilege{Server: "server1", Database: "sales", Table: "orders",
+		Column: "id", URI: "hdfs://NN/Path", Action: "*", Scope: "TABLE"}
+	if normalized := priv.Normalize(); !reflect.DeepEqual(*normalized, expected) {
+		t.Errorf("expected %+v, got %+v", expected, *normalized)
+	}
+	if priv.Server != "Server1" {
+		t.Error("Normalize modified the privilege")
+	}
+
+	generic := &Privilege{Service: "Kafka1", Action: "Read",
+		Authorizables: []Authorizable{{Type: "Topic", Name: "Clicks"}}}
+	normalized := generic.Normalize()
+	if normalized.Service != "kafka1" || normalized.Action != "read" ||
+		normalized.Authorizables[0] != (Authorizable{Type: "topic", Name: "clicks"}) {
+		t.Errorf("unexpected normalized generic privilege %+v", *normalized)
+	}
+	if generic.Authorizables[0].Name != "Clicks" {
+		t.Error("Normalize modified generic privilege authorizables")
+	}
+}
+
+func TestPrivilege_Equal(t *testing.T) {
+	tests := []struct {
+		a, b  *Privilege
+		equal bool
+	}{
+		{&Privilege{Server: "server1", Database: "Sales", Action: "all"},
+			&Privilege{Server: "SERVER1", Database: "sales", Action: "*",
+				Scope: "DATABASE", CreateTime: 10}, true},
+		{&Privilege{Database: "sales", Action: "select"},
+			&Privilege{Database: "sales", Action: "select", GrantOption: true}, false},
+		{&Privilege{URI: "hdfs://nn/A"}, &Privilege{URI: "hdfs://nn/a"}, false},
+		{&Privilege{Database: "sales", Action: "select"},
+			&Privilege{Database: "sales", Action: "insert"}, false},
+		{&Privilege{Service: "solr1", Action: "ALL", Authorizables: []Authorizable{
+			{Type: "Collection", Name: "Logs"}}},
+			&Privilege{Service: "SOLR1", Action: "*", Authorizables: []Authorizable{
+				{Type: "collection", Name: "logs"}}}, true},
+		// Sqoop server authorizable is not the same as Hive server
+		{&Privilege{Server: "s1"}, &Privilege{Authorizables: []Authorizable{
+			{Type: "server", Name: "s1"}}}, false},
+		{nil, nil, true},
+		{&Privilege{}, nil, false},
+	}
+	for i, tt := range tests {
+		if equal := tt.a.Equal(tt.b); equal != tt.equal {
+			t.Errorf("test %d: expected %v, got %v", i, tt.equal, equal)
+		}
+		if tt.a != nil && tt.b != nil && (tt.a.Key() == tt.b.Key()) != tt.equal {
+			t.Errorf("test %d: keys %s and %s", i, tt.a.Key(), tt.b.Key())
+		}
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+// RecordedCall is a single Thrift request and the server response as seen
+// on the wire above the TLS and SASL layers.
+// Attributes:
+//   Time - when the request was sent
+//   Service - multiplexed service name, e.g. SentryPolicyService
+//   Method - Thrift method name, e.g. create_sentry_role
+//   Request - request message in Thrift binary protocol
+//   Response - response message in Thrift binary protocol
+//   Duration - time from sending the request to receiving the response
+//   Error - transport error which ended the call, if any
+type RecordedCall struct {
+	Time     time.Time     `json:"time"`
+	Service  string        `json:"service"`
+	Method   string        `json:"method"`
+	Request  []byte        `json:"request"`
+	Response []byte        `json:"response,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Recorder writes Thrift calls as JSON lines, one RecordedCall per line.
+// A single recorder can be shared by all client connections.
+type Recorder struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+	err     error
+}
+
+// NewRecorder returns a recorder writing calls to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{encoder: json.NewEncoder(w)}
+}
+
+// Err returns the first error writing the recording
+func (r *Recorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+// record writes the call unless writing failed before
+func (r *Recorder) record(call *RecordedCall) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err == nil {
+		r.err = r.encoder.Encode(call)
+	}
+}
+
+// Recording is a sequence of recorded calls which can be served back to
+// clients instead of a Sentry server with WithReplay().
+type Recording struct {
+	lock  sync.Mutex
+	calls []*RecordedCall
+	used  []bool
+}
+
+// LoadRecording reads recording written by Recorder
+func LoadRecording(r io.Reader) (*Recording, error) {
+	recording := &Recording{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		call := &RecordedCall{}
+		if err := json.Unmarshal(scanner.Bytes(), call); err != nil {
+			return nil, fmt.Errorf("invalid recording at line %d: %v", line, err)
+		}
+		recording.calls = append(recording.calls, call)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	recording.used = make([]bool, len(recording.calls))
+	return recording, nil
+}
+
+// Calls returns all recorded calls
+func (r *Recording) Calls() []*RecordedCall {
+	return r.calls
+}
+
+// Remaining returns the number of calls which were not replayed yet
+func (r *Recording) Remaining() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	remaining := 0
+	for _, used := range r.used {
+		if !used {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+// next returns the first call to the service method which was not replayed
+// yet. Calls are matched by name rather than content, since requests carry
+// sequence numbers and other values which differ between runs.
+func (r *Recording) next(service string, method string) *RecordedCall {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, call := range r.calls {
+		if !r.used[i] && call.Service == service && call.Method == method {
+			r.used[i] = true
+			return call
+		}
+	}
+	return nil
+}
+
+// messageHeader returns the name and the offset of the sequence number of
+// Thrift binary protocol message
+func messageHeader(message []byte) (string, int, bool) {
+	if len(message) < 4 {
+		return "", 0, false
+	}
+	// Strict header starts with version and type followed by the name,
+	// old header starts with the name followed by the type byte.
+	strict := int32(binary.BigEndian.Uint32(message)) < 0
+	offset := 0
+	if strict {
+		offset = 4
+	}
+	if len(message) < offset+4 {
+		return "", 0, false
+	}
+	size := int(binary.BigEndian.Uint32(message[offset:]))
+	offset += 4
+	if size < 0 || len(message) < offset+size {
+		return "", 0, false
+	}
+	name := string(message[offset : offset+size])
+	offset += size
+	if !strict {
+		offset++
+	}
+	if len(message) < offset+4 {
+		return "", 0, false
+	}
+	return name, offset, true
+}
+
+// splitName splits multiplexed message name into service and method
+func splitName(name string) (string, string) {
+	if i := strings.Index(name, thrift.MULTIPLEXED_SEPARATOR); i >= 0 {
+		return name[:i], name[i+len(thrift.MULTIPLEXED_SEPARATOR):]
+	}
+	return "", name
+}
+
+// recordingTransport passes all I/O to the underlying transport and records
+// each flushed request together with the response read after it. The call
+// is complete when the next request starts or the transport is closed.
+type recordingTransport struct {
+	thrift.TTransport
+	recorder *Recorder
+	request  bytes.Buffer
+	call     *RecordedCall
+}
+
+func newRecordingTransport(transport thrift.TTransport,
+	recorder *Recorder) *recordingTransport {
+	return &recordingTransport{TTransport: transport, recorder: recorder}
+}
+
+// finish records the pending call
+func (t *recordingTransport) finish() {
+	if t.call != nil {
+		t.recorder.record(t.call)
+		t.call = nil
+	}
+}
+
+// Write implements io.Writer
+func (t *recordingTransport) Write(p []byte) (int, error) {
+	t.finish()
+	t.request.Write(p)
+	return t.TTransport.Write(p)
+}
+
+// Flush implements thrift.TTransport.Flush()
+func (t *recordingTransport) Flush() error {
+	t.finish()
+	request := make([]byte, t.request.Len())
+	copy(request, t.request.Bytes())
+	t.request.Reset()
+	name, _, _ := messageHeader(request)
+	service, method := splitName(name)
+	t.call = &RecordedCall{
+		Time:    time.Now(),
+		Service: service,
+		Method:  method,
+		Request: request,
+	}
+	err := t.TTransport.Flush()
+	if err != nil {
+		t.call.Error = err.Error()
+		t.finish()
+	}
+	return err
+}
+
+// Read implements io.Reader
+func (t *recordingTransport) Read(p []byte) (int, error) {
+	n, err := t.TTransport.Read(p)
+	if t.call != nil {
+		t.call.Response = append(t.call.Response, p[:n]...)
+		t.call.Duration = time.Since(t.call.Time)
+		if err != nil {
+			t.call.Error = err.Error()
+			t.finish()
+		}
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (t *recordingTransport) Close() error {
+	t.finish()
+	return t.TTransport.Close()
+}
+
+// replayTransport serves responses from the recording instead of talking
+// to a server
+type replayTransport struct {
+	recording *Recording
+	request   bytes.Buffer
+	response  *bytes.Reader
+	err       error
+	open      bool
+}
+
+func newReplayTransport(recording *Recording) *replayTransport {
+	return &replayTransport{recording: recording}
+}
+
+// Open implements thrift.TTransport.Open()
+func (t *replayTransport) Open() error {
+	t.open = true
+	return nil
+}
+
+// IsOpen implements thrift.TTransport.IsOpen()
+func (t *replayTransport) IsOpen() bool {
+	return t.open
+}
+
+// Close implements io.Closer
+func (t *replayTransport) Close() error {
+	t.open = false
+	return nil
+}
+
+// Write implements io.Writer
+func (t *replayTransport) Write(p []byte) (int, error) {
+	return t.request.Write(p)
+}
+
+// Flush implements thrift.TTransport.Flush(). It finds the recorded
+// response for the request and patches its sequence number to match the
+// request.
+func (t *replayTransport) Flush() error {
+	request := t.request.Bytes()
+	defer t.request.Reset()
+	t.response, t.err = nil, nil
+	name, seqOffset, ok := messageHeader(request)
+	if !ok {
+		return fmt.Errorf("replay: invalid Thrift request")
+	}
+	service, method := splitName(name)
+	call := t.recording.next(service, method)
+	if call == nil {
+		return fmt.Errorf("replay: no recorded call for %s", name)
+	}
+	response := make([]byte, len(call.Response))
+	copy(response, call.Response)
+	if _, offset, ok := messageHeader(response); ok {
+		copy(response[offset:offset+4], request[seqOffset:seqOffset+4])
+	}
+	t.response = bytes.NewReader(response)
+	if call.Error != "" {
+		t.err = fmt.Errorf("replay: %s", call.Error)
+	}
+	return nil
+}
+
+// Read implements io.Reader. Once the recorded response is exhausted, the
+// recorded error or EOF is returned.
+func (t *replayTransport) Read(p []byte) (int, error) {
+	if t.response == nil {
+		return 0, io.EOF
+	}
+	n, err := t.response.Read(p)
+	if err == io.EOF && t.err != nil {
+		err = t.err
+	}
+	return n, err
+}
+
+// RemainingBytes implements thrift.ReadSizeProvider
+func (t *replayTransport) RemainingBytes() uint64 {
+	if t.response == nil {
+		return 0
+	}
+	return uint64(t.response.Len())
+}
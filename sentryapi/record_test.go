@@ -0,0 +1,111 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/akolb1/sentrytool/sentryapi/sentrytest"
+)
+
+// recordedSession performs a few calls with the client and returns their
+// results
+func recordedSession(t *testing.T, client sentryapi.ClientAPI) ([]string, error) {
+	if err := client.CreateRole("recorded"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddGroupsToRole("recorded", []string{"g1"}); err != nil {
+		t.Fatal(err)
+	}
+	roles, _, err := client.ListRoleByGroup("g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return roles, client.CreateRole("recorded")
+}
+
+func TestRecordReplay(t *testing.T) {
+	server, err := sentrytest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	recorder := sentryapi.NewRecorder(buf)
+	client, err := sentryapi.GetClient(sentryapi.PolicyProtocol, server.Host,
+		server.Port, "", "admin", sentryapi.WithRecorder(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles, dupErr := recordedSession(t, client)
+	client.Close()
+	server.Close()
+	if err := recorder.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	recording, err := sentryapi.LoadRecording(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var methods []string
+	for _, call := range recording.Calls() {
+		if call.Service != "SentryPolicyService" {
+			t.Errorf("unexpected service %s", call.Service)
+		}
+		if len(call.Request) == 0 || len(call.Response) == 0 {
+			t.Errorf("empty request or response for %s", call.Method)
+		}
+		methods = append(methods, call.Method)
+	}
+	expected := []string{"list_sentry_roles_by_group", "create_sentry_role",
+		"alter_sentry_role_add_groups", "list_sentry_roles_by_group",
+		"create_sentry_role"}
+	if !reflect.DeepEqual(methods, expected) {
+		t.Errorf("expected calls %v, got %v", expected, methods)
+	}
+
+	// The server is gone, the replayed session must give the same results
+	client, err = sentryapi.GetClient(sentryapi.PolicyProtocol, server.Host,
+		server.Port, "", "admin", sentryapi.WithReplay(recording))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	replayedRoles, replayedErr := recordedSession(t, client)
+	if !reflect.DeepEqual(roles, replayedRoles) {
+		t.Errorf("expected roles %v, got %v", roles, replayedRoles)
+	}
+	if !errors.Is(dupErr, sentryapi.ErrAlreadyExists) ||
+		!errors.Is(replayedErr, sentryapi.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v and %v", dupErr, replayedErr)
+	}
+	if recording.Remaining() != 0 {
+		t.Errorf("%d calls were not replayed", recording.Remaining())
+	}
+	if err := client.RemoveRole("recorded"); !sentryapi.IsTransportError(err) {
+		t.Errorf("expected transport error for call missing from recording, got %v", err)
+	}
+}
+
+func TestLoadRecording_Invalid(t *testing.T) {
+	_, err := sentryapi.LoadRecording(bytes.NewBufferString("{}\nnot json\n"))
+	if err == nil {
+		t.Error("expected error for invalid recording")
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+// SASL negotiation status codes used by Thrift TSaslTransport
+const (
+	saslStart    byte = 1
+	saslOK       byte = 2
+	saslBad      byte = 3
+	saslError    byte = 4
+	saslComplete byte = 5
+)
+
+// maxSaslFrameSize limits the size of negotiation and data frames
+const maxSaslFrameSize = 16 * 1024 * 1024
+
+// SASLMechanism is a client side of a SASL authentication mechanism.
+// Implementations only need to support authentication; data is transferred
+// without a security layer once negotiation is complete.
+type SASLMechanism interface {
+	// Name returns SASL mechanism name, e.g. "GSSAPI"
+	Name() string
+	// Start returns the initial client response
+	//   host - Sentry server host
+	Start(host string) ([]byte, error)
+	// Step returns client response to the server challenge
+	//   challenge - data sent by the server
+	Step(challenge []byte) ([]byte, error)
+}
+
+// plainMechanism implements SASL PLAIN mechanism (RFC 4616)
+type plainMechanism struct {
+	user     string
+	password string
+}
+
+// NewPlainMechanism returns SASL PLAIN mechanism. PLAIN sends the password
+// in clear text and should only be used for test setups.
+func NewPlainMechanism(user string, password string) SASLMechanism {
+	return &plainMechanism{user: user, password: password}
+}
+
+// Name implements SASLMechanism.Name()
+func (m *plainMechanism) Name() string {
+	return "PLAIN"
+}
+
+// Start implements SASLMechanism.Start()
+func (m *plainMechanism) Start(host string) ([]byte, error) {
+	return []byte("\x00" + m.user + "\x00" + m.password), nil
+}
+
+// Step implements SASLMechanism.Step()
+func (m *plainMechanism) Step(challenge []byte) ([]byte, error) {
+	return nil, fmt.Errorf("unexpected PLAIN challenge")
+}
+
+// tSaslClientTransport is a client side of Thrift TSaslClientTransport.
+// After negotiation each message is sent as a frame prefixed by its length.
+type tSaslClientTransport struct {
+	transport thrift.TTransport
+	host      string
+	mech      SASLMechanism
+	readBuf   bytes.Buffer
+	writeBuf  bytes.Buffer
+}
+
+// newTSaslClientTransport returns SASL transport wrapping the given one
+func newTSaslClientTransport(transport thrift.TTransport, host string,
+	mech SASLMechanism) *tSaslClientTransport {
+	return &tSaslClientTransport{
+		transport: transport,
+		host:      host,
+		mech:      mech,
+	}
+}
+
+// Open opens underlying transport and performs SASL negotiation
+func (t *tSaslClientTransport) Open() error {
+	if !t.transport.IsOpen() {
+		if err := t.transport.Open(); err != nil {
+			return err
+		}
+	}
+	if err := t.negotiate(); err != nil {
+		t.transport.Close()
+		return err
+	}
+	return nil
+}
+
+// negotiate runs SASL authentication exchange with the server
+func (t *tSaslClientTransport) negotiate() error {
+	if err := t.sendMessage(saslStart, []byte(t.mech.Name())); err != nil {
+		return err
+	}
+	response, err := t.mech.Start(t.host)
+	if err != nil {
+		return fmt.Errorf("SASL %s failed: %s", t.mech.Name(), err)
+	}
+	if err := t.sendMessage(saslOK, response); err != nil {
+		return err
+	}
+	for {
+		status, payload, err := t.receiveMessage()
+		if err != nil {
+			return err
+		}
+		switch status {
+		case saslOK:
+			response, err := t.mech.Step(payload)
+			if err != nil {
+				return fmt.Errorf("SASL %s failed: %s", t.mech.Name(), err)
+			}
+			if err := t.sendMessage(saslOK, response); err != nil {
+				return err
+			}
+		case saslComplete:
+			if len(payload) != 0 {
+				if _, err := t.mech.Step(payload); err != nil {
+					return fmt.Errorf("SASL %s failed: %s", t.mech.Name(), err)
+				}
+			}
+			return nil
+		case saslBad, saslError:
+			return fmt.Errorf("SASL %s authentication failed: %s",
+				t.mech.Name(), string(payload))
+		default:
+			return fmt.Errorf("invalid SASL status %d", status)
+		}
+	}
+}
+
+// sendMessage sends negotiation message with the given status
+func (t *tSaslClientTransport) sendMessage(status byte, payload []byte) error {
+	header := make([]byte, 5)
+	header[0] = status
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	if _, err := t.transport.Write(header); err != nil {
+		return err
+	}
+	if _, err := t.transport.Write(payload); err != nil {
+		return err
+	}
+	return t.transport.Flush()
+}
+
+// receiveMessage reads negotiation message from the server
+func (t *tSaslClientTransport) receiveMessage() (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(t.transport, header); err != nil {
+		return 0, nil, err
+	}
+	payload, err := t.readPayload(binary.BigEndian.Uint32(header[1:]))
+	return header[0], payload, err
+}
+
+// readPayload reads size bytes from the underlying transport
+func (t *tSaslClientTransport) readPayload(size uint32) ([]byte, error) {
+	if size > maxSaslFrameSize {
+		return nil, fmt.Errorf("SASL frame size %d is too large", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(t.transport, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// IsOpen returns true if underlying transport is open
+func (t *tSaslClientTransport) IsOpen() bool {
+	return t.transport.IsOpen()
+}
+
+// Close closes underlying transport
+func (t *tSaslClientTransport) Close() error {
+	return t.transport.Close()
+}
+
+// Read reads data from the current frame, reading the next frame when the
+// current one is exhausted
+func (t *tSaslClientTransport) Read(p []byte) (int, error) {
+	if t.readBuf.Len() == 0 {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(t.transport, header); err != nil {
+			return 0, err
+		}
+		payload, err := t.readPayload(binary.BigEndian.Uint32(header))
+		if err != nil {
+			return 0, err
+		}
+		t.readBuf.Write(payload)
+	}
+	return t.readBuf.Read(p)
+}
+
+// Write buffers data until Flush is called
+func (t *tSaslClientTransport) Write(p []byte) (int, error) {
+	return t.writeBuf.Write(p)
+}
+
+// Flush sends buffered data as a single frame
+func (t *tSaslClientTransport) Flush() error {
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(t.writeBuf.Len()))
+	if _, err := t.transport.Write(header); err != nil {
+		return err
+	}
+	if _, err := t.writeBuf.WriteTo(t.transport); err != nil {
+		return err
+	}
+	return t.transport.Flush()
+}
+
+// RemainingBytes returns number of bytes left in the current frame or
+// unknown size if the next frame wasn't read yet
+func (t *tSaslClientTransport) RemainingBytes() uint64 {
+	if t.readBuf.Len() == 0 {
+		return ^uint64(0)
+	}
+	return uint64(t.readBuf.Len())
+}
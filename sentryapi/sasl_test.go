@@ -0,0 +1,156 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+// saslServer is a stand-in for the Sentry server side of the SASL
+// transport. It accepts a single PLAIN connection and echoes data frames.
+type saslServer struct {
+	listener net.Listener
+	user     string
+	password string
+	done     chan error
+}
+
+func newSaslServer(t *testing.T, user string, password string) *saslServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &saslServer{
+		listener: listener,
+		user:     user,
+		password: password,
+		done:     make(chan error, 1),
+	}
+	go func() { s.done <- s.serve() }()
+	return s
+}
+
+func (s *saslServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *saslServer) serve() error {
+	conn, err := s.listener.Accept()
+	s.listener.Close()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, mech, err := readSaslMessage(conn)
+	if err != nil {
+		return err
+	}
+	_, response, err := readSaslMessage(conn)
+	if err != nil {
+		return err
+	}
+	expected := "\x00" + s.user + "\x00" + s.password
+	if string(mech) != "PLAIN" || string(response) != expected {
+		return writeSaslMessage(conn, saslBad, []byte("authentication failed"))
+	}
+	if err := writeSaslMessage(conn, saslComplete, nil); err != nil {
+		return err
+	}
+	// Echo data frames
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		frame := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(conn, frame); err != nil {
+			return err
+		}
+		if _, err := conn.Write(append(header, frame...)); err != nil {
+			return err
+		}
+	}
+}
+
+func readSaslMessage(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	_, err := io.ReadFull(r, payload)
+	return header[0], payload, err
+}
+
+func writeSaslMessage(w io.Writer, status byte, payload []byte) error {
+	header := make([]byte, 5)
+	header[0] = status
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	_, err := w.Write(append(header, payload...))
+	return err
+}
+
+func TestSaslClientTransport_Plain(t *testing.T) {
+	server := newSaslServer(t, "sentry", "secret")
+	options := newClientOptions([]ClientOption{
+		WithSASL(NewPlainMechanism("sentry", "secret"))})
+	transport, err := openTransport("127.0.0.1", server.port(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := []byte("hello sentry")
+	if _, err := transport.Write(message); err != nil {
+		t.Fatal(err)
+	}
+	if err := transport.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, len(message))
+	if _, err := io.ReadFull(transport, reply); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reply, message) {
+		t.Errorf("expected %q, got %q", message, reply)
+	}
+	transport.Close()
+	if err := <-server.done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSaslClientTransport_BadPassword(t *testing.T) {
+	server := newSaslServer(t, "sentry", "secret")
+	socket, err := thrift.NewTSocket(server.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := newTSaslClientTransport(socket, "127.0.0.1",
+		NewPlainMechanism("sentry", "wrong"))
+	if err := transport.Open(); err == nil {
+		t.Error("expected authentication failure")
+	}
+	if err := <-server.done; err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Generic model components with known privilege schemas
+const (
+	KafkaComponent = "kafka"
+	SolrComponent  = "solr"
+	SqoopComponent = "sqoop"
+)
+
+// AuthorizableSchema describes a single authorizable type in the component
+// privilege hierarchy.
+// Attributes:
+//   Type - authorizable type name, e.g. "topic"
+//   Default - name used when the authorizable is omitted from the hierarchy
+//   Terminal - true if privilege hierarchy may end at this type
+//   Actions - actions valid for privileges ending at this type
+//   Children - authorizable types allowed right below this type
+type AuthorizableSchema struct {
+	Type     string
+	Default  string
+	Terminal bool
+	Actions  []string
+	Children []*AuthorizableSchema
+}
+
+// ComponentSchema describes valid generic model privileges for a component.
+// Attributes:
+//   Component - component name, e.g. "kafka"
+//   Roots - authorizable types allowed at the top of the hierarchy
+type ComponentSchema struct {
+	Component string
+	Roots     []*AuthorizableSchema
+}
+
+var (
+	solrActions  = []string{"*", "query", "update"}
+	sqoopActions = []string{"*", "all", "read", "write"}
+)
+
+var (
+	schemaLock sync.RWMutex
+	schemas    = map[string]*ComponentSchema{
+		KafkaComponent: {
+			Component: KafkaComponent,
+			Roots: []*AuthorizableSchema{
+				{
+					Type:     "host",
+					Default:  "*",
+					Terminal: true,
+					Actions:  []string{"all"},
+					Children: []*AuthorizableSchema{
+						{
+							Type:     "cluster",
+							Terminal: true,
+							Actions: []string{"all", "create", "describe",
+								"alter", "clusteraction", "describeconfigs",
+								"alterconfigs", "idempotentwrite"},
+						},
+						{
+							Type:     "topic",
+							Terminal: true,
+							Actions: []string{"all", "read", "write",
+								"describe", "delete", "alter", "describeconfigs",
+								"alterconfigs"},
+						},
+						{
+							Type:     "consumergroup",
+							Terminal: true,
+							Actions:  []string{"all", "read", "describe", "delete"},
+						},
+					},
+				},
+			},
+		},
+		SolrComponent: {
+			Component: SolrComponent,
+			Roots: []*AuthorizableSchema{
+				{
+					Type:     "collection",
+					Terminal: true,
+					Actions:  solrActions,
+					Children: []*AuthorizableSchema{
+						{Type: "field", Terminal: true, Actions: solrActions},
+					},
+				},
+				{Type: "admin", Terminal: true, Actions: solrActions},
+				{Type: "config", Terminal: true, Actions: solrActions},
+				{Type: "schema", Terminal: true, Actions: solrActions},
+			},
+		},
+		SqoopComponent: {
+			Component: SqoopComponent,
+			Roots: []*AuthorizableSchema{
+				{
+					Type:     "server",
+					Terminal: true,
+					Actions:  sqoopActions,
+					Children: []*AuthorizableSchema{
+						{Type: "connector", Terminal: true, Actions: sqoopActions},
+						{Type: "link", Terminal: true, Actions: sqoopActions},
+						{Type: "job", Terminal: true, Actions: sqoopActions},
+					},
+				},
+			},
+		},
+	}
+)
+
+// GetComponentSchema returns privilege schema for the component.
+// Component names are case-insensitive.
+func GetComponentSchema(component string) (*ComponentSchema, bool) {
+	schemaLock.RLock()
+	defer schemaLock.RUnlock()
+	schema, ok := schemas[strings.ToLower(component)]
+	return schema, ok
+}
+
+// RegisterComponentSchema adds or replaces privilege schema for a component
+func RegisterComponentSchema(schema *ComponentSchema) {
+	schemaLock.Lock()
+	defer schemaLock.Unlock()
+	schemas[strings.ToLower(schema.Component)] = schema
+}
+
+// ComponentSchemas returns all known component schemas sorted by component
+// name
+func ComponentSchemas() []*ComponentSchema {
+	schemaLock.RLock()
+	defer schemaLock.RUnlock()
+	result := make([]*ComponentSchema, 0, len(schemas))
+	for _, schema := range schemas {
+		result = append(result, schema)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Component < result[j].Component
+	})
+	return result
+}
+
+// ValidatePrivilege verifies that privilege matches the schema of the
+// component. Privileges for components without a known schema are always
+// valid.
+func ValidatePrivilege(component string, priv *Privilege) error {
+	schema, ok := GetComponentSchema(component)
+	if !ok {
+		return nil
+	}
+	return schema.Validate(priv)
+}
+
+// Types returns all authorizable types of the component in hierarchy order:
+// parents always precede their children.
+func (s *ComponentSchema) Types() []*AuthorizableSchema {
+	result := []*AuthorizableSchema{}
+	seen := make(map[string]bool)
+	level := s.Roots
+	for len(level) != 0 {
+		next := []*AuthorizableSchema{}
+		for _, auth := range level {
+			if !seen[auth.Type] {
+				seen[auth.Type] = true
+				result = append(result, auth)
+			}
+			next = append(next, auth.Children...)
+		}
+		level = next
+	}
+	return result
+}
+
+// FillDefaults prepends default authorizables omitted at the top of the
+// privilege hierarchy, e.g. host=* for Kafka privileges.
+func (s *ComponentSchema) FillDefaults(priv *Privilege) {
+	if len(priv.Authorizables) == 0 ||
+		findAuthorizable(s.Roots, priv.Authorizables[0].Type) != nil {
+		return
+	}
+	for _, root := range s.Roots {
+		if root.Default == "" ||
+			findAuthorizable(root.Children, priv.Authorizables[0].Type) == nil {
+			continue
+		}
+		priv.Authorizables = append([]Authorizable{{Type: root.Type,
+			Name: root.Default}}, priv.Authorizables...)
+		return
+	}
+}
+
+// Validate verifies that privilege authorizables follow the component
+// hierarchy and the action is valid for the privilege. Validation errors
+// wrap ErrInvalidInput.
+func (s *ComponentSchema) Validate(priv *Privilege) error {
+	if len(priv.Authorizables) == 0 {
+		return s.schemaError("%s privilege should have authorizables", s.Component)
+	}
+	candidates := s.Roots
+	var current *AuthorizableSchema
+	for _, auth := range priv.Authorizables {
+		current = findAuthorizable(candidates, auth.Type)
+		if current == nil {
+			return s.schemaError("invalid %s authorizable '%s', expected one of %s",
+				s.Component, auth.Type, authorizableNames(candidates))
+		}
+		if auth.Name == "" {
+			return s.schemaError("missing name for %s authorizable '%s'",
+				s.Component, auth.Type)
+		}
+		candidates = current.Children
+	}
+	if !current.Terminal {
+		return s.schemaError("incomplete %s privilege, '%s' should be followed by one of %s",
+			s.Component, current.Type, authorizableNames(current.Children))
+	}
+	if priv.Action == "" {
+		return s.schemaError("missing action for %s privilege", s.Component)
+	}
+	action := strings.ToLower(priv.Action)
+	for _, a := range current.Actions {
+		if a == action {
+			return nil
+		}
+	}
+	return s.schemaError("invalid action '%s' for %s %s, expected one of %s",
+		priv.Action, s.Component, current.Type,
+		strings.Join(current.Actions, ", "))
+}
+
+// schemaError returns validation error wrapping ErrInvalidInput
+func (s *ComponentSchema) schemaError(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), ErrInvalidInput)
+}
+
+// findAuthorizable finds authorizable by case-insensitive type name
+func findAuthorizable(candidates []*AuthorizableSchema,
+	authType string) *AuthorizableSchema {
+	for _, auth := range candidates {
+		if strings.EqualFold(auth.Type, authType) {
+			return auth
+		}
+	}
+	return nil
+}
+
+// authorizableNames returns comma-separated list of authorizable types
+func authorizableNames(auths []*AuthorizableSchema) string {
+	names := make([]string, 0, len(auths))
+	for _, auth := range auths {
+		names = append(names, auth.Type)
+	}
+	return strings.Join(names, ", ")
+}
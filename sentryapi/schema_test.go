@@ -0,0 +1,88 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestComponentSchema_Validate(t *testing.T) {
+	tests := []struct {
+		component string
+		priv      Privilege
+		valid     bool
+	}{
+		{KafkaComponent, Privilege{Action: "read", Authorizables: []Authorizable{
+			{"host", "*"}, {"topic", "clicks"}}}, true},
+		{KafkaComponent, Privilege{Action: "READ", Authorizables: []Authorizable{
+			{"host", "*"}, {"topic", "clicks"}}}, true},
+		{KafkaComponent, Privilege{Action: "read", Authorizables: []Authorizable{
+			{"host", "*"}, {"cluster", "kafka"}}}, false},
+		{KafkaComponent, Privilege{Action: "read", Authorizables: []Authorizable{
+			{"topic", "clicks"}, {"cluster", "kafka"}}}, false},
+		{KafkaComponent, Privilege{Action: "all", Authorizables: []Authorizable{
+			{"host", "*"}}}, true},
+		{KafkaComponent, Privilege{Action: "read", Authorizables: []Authorizable{
+			{"host", "*"}}}, false},
+		{SolrComponent, Privilege{Action: "query", Authorizables: []Authorizable{
+			{"collection", "logs"}}}, true},
+		{SolrComponent, Privilege{Action: "query", Authorizables: []Authorizable{
+			{"collection", "logs"}, {"field", "f1"}}}, true},
+		{SolrComponent, Privilege{Action: "read", Authorizables: []Authorizable{
+			{"collection", "logs"}}}, false},
+		{SqoopComponent, Privilege{Action: "read", Authorizables: []Authorizable{
+			{"server", "sqoop1"}, {"job", "j1"}}}, true},
+		{SqoopComponent, Privilege{Action: "read", Authorizables: []Authorizable{
+			{"job", "j1"}}}, false},
+		{SqoopComponent, Privilege{Authorizables: []Authorizable{
+			{"server", "sqoop1"}}}, false},
+	}
+	for _, test := range tests {
+		err := ValidatePrivilege(test.component, &test.priv)
+		if test.valid && err != nil {
+			t.Errorf("%s %v: unexpected error: %v", test.component, test.priv, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s %v: expected invalid input error, got %v",
+				test.component, test.priv, err)
+		}
+	}
+}
+
+func TestComponentSchema_FillDefaults(t *testing.T) {
+	schema, ok := GetComponentSchema("Kafka")
+	if !ok {
+		t.Fatal("missing kafka schema")
+	}
+	priv := &Privilege{Action: "read",
+		Authorizables: []Authorizable{{"topic", "clicks"}}}
+	schema.FillDefaults(priv)
+	if len(priv.Authorizables) != 2 || priv.Authorizables[0].Type != "host" ||
+		priv.Authorizables[0].Name != "*" {
+		t.Errorf("expected default host, got %v", priv.Authorizables)
+	}
+	if err := schema.Validate(priv); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidatePrivilege_UnknownComponent(t *testing.T) {
+	priv := &Privilege{Action: "anything",
+		Authorizables: []Authorizable{{"foo", "bar"}}}
+	if err := ValidatePrivilege("unknown", priv); err != nil {
+		t.Error(err)
+	}
+}
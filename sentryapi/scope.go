@@ -0,0 +1,73 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Legacy model privilege scopes. The scope is the type of the object the
+// privilege is granted on.
+const (
+	ScopeServer   = "SERVER"
+	ScopeDatabase = "DATABASE"
+	ScopeTable    = "TABLE"
+	ScopeColumn   = "COLUMN"
+	ScopeURI      = "URI"
+)
+
+// InferScope returns the scope of a legacy model privilege based on which
+// objects are set. It returns an error wrapping ErrInvalidInput for
+// inconsistent privileges, e.g. column without table, URI with database or
+// explicit Scope which doesn't match the objects. Generic model privileges
+// have no scope, so the result is empty for them.
+func (p *Privilege) InferScope() (string, error) {
+	if p.IsGeneric() {
+		return "", nil
+	}
+	var scope string
+	switch {
+	case p.URI != "":
+		if p.Database != "" || p.Table != "" || p.Column != "" {
+			return "", p.scopeError("URI can't be combined with database, table or column")
+		}
+		scope = ScopeURI
+	case p.Column != "":
+		if p.Table == "" {
+			return "", p.scopeError("column requires table")
+		}
+		scope = ScopeColumn
+	case p.Table != "":
+		scope = ScopeTable
+	case p.Database != "":
+		scope = ScopeDatabase
+	default:
+		scope = ScopeServer
+	}
+	if p.Table != "" && p.Database == "" {
+		return "", p.scopeError("table requires database")
+	}
+	if p.Scope != "" && !strings.EqualFold(p.Scope, scope) {
+		return "", p.scopeError(fmt.Sprintf("scope %s doesn't match %s privilege",
+			p.Scope, strings.ToLower(scope)))
+	}
+	return scope, nil
+}
+
+// scopeError returns invalid privilege error with the privilege and reason
+func (p *Privilege) scopeError(msg string) error {
+	return fmt.Errorf("invalid privilege %s: %s: %w", p, msg, ErrInvalidInput)
+}
@@ -0,0 +1,59 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPrivilege_InferScope(t *testing.T) {
+	tests := []struct {
+		priv  Privilege
+		scope string
+		valid bool
+	}{
+		{Privilege{Server: "server1", Action: "all"}, ScopeServer, true},
+		{Privilege{Server: "server1", Database: "sales"}, ScopeDatabase, true},
+		{Privilege{Server: "server1", Database: "sales", Table: "t"}, ScopeTable, true},
+		{Privilege{Server: "server1", Database: "sales", Table: "t", Column: "c"},
+			ScopeColumn, true},
+		{Privilege{Server: "server1", URI: "hdfs://nn/a"}, ScopeURI, true},
+		{Privilege{Server: "server1", Database: "sales", Scope: "database"},
+			ScopeDatabase, true},
+		{Privilege{Service: "kafka1", Authorizables: []Authorizable{
+			{Type: "topic", Name: "clicks"}}}, "", true},
+		{Privilege{Server: "server1", Database: "sales", Column: "c"}, "", false},
+		{Privilege{Server: "server1", Table: "t"}, "", false},
+		{Privilege{Server: "server1", Database: "sales", URI: "hdfs://nn/a"}, "", false},
+		{Privilege{Server: "server1", Database: "sales", Scope: "TABLE"}, "", false},
+	}
+	for _, tt := range tests {
+		scope, err := tt.priv.InferScope()
+		if !tt.valid {
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("%s: expected invalid input error, got %v", &tt.priv, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", &tt.priv, err)
+			continue
+		}
+		if scope != tt.scope {
+			t.Errorf("%s: expected scope %q, got %q", &tt.priv, tt.scope, scope)
+		}
+	}
+}
@@ -15,7 +15,8 @@
 package sentryapi
 
 import (
-	"fmt"
+	"sort"
+	"strings"
 
 	"git.apache.org/thrift.git/lib/go/thrift"
 	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_policy_service"
@@ -33,9 +34,10 @@ func (p *tMPProtocolFactory) GetProtocol(t thrift.TTransport) thrift.TProtocol {
 
 // sentryCLient represents client handle for Hive model
 type sentryClient struct {
-	userName  string
-	transport thrift.TTransport
-	client    *sentry_policy_service.SentryPolicyServiceClient
+	userName        string
+	protocolVersion int32
+	transport       thrift.TTransport
+	client          *sentry_policy_service.SentryPolicyServiceClient
 }
 
 // Close closes transport connection
@@ -43,34 +45,58 @@ func (c *sentryClient) Close() {
 	c.transport.Close()
 }
 
+// broken returns true if the client connection failed
+func (c *sentryClient) broken() bool {
+	return transportBroken(c.transport)
+}
+
+// cancel aborts the pending call, the client can't be used after that
+func (c *sentryClient) cancel() {
+	cancelTransport(c.transport)
+}
+
 // getHiveClient returns client handle for Hive protocol
-func getHiveClient(host string, port int, user string) (*sentryClient, error) {
-	socket, err := thrift.NewTSocket(fmt.Sprintf("%s:%d", host, port))
+func getHiveClient(host string, port int, user string,
+	options *clientOptions) (*sentryClient, error) {
+	transport, err := openTransport(host, port, options)
 	if err != nil {
 		return nil, err
 	}
-	var transport thrift.TTransport = thrift.NewTBufferedTransport(socket, 1024)
 	protocolFactory := &tMPProtocolFactory{}
 	client := sentry_policy_service.NewSentryPolicyServiceClientFactory(transport, protocolFactory)
-	if err := transport.Open(); err != nil {
+	c := &sentryClient{userName: user, transport: transport, client: client}
+	if err := negotiateVersion(c, options,
+		versionKey(PolicyProtocol, host, port)); err != nil {
+		transport.Close()
 		return nil, err
 	}
-	return &sentryClient{userName: user, transport: transport, client: client}, nil
+	return c, nil
+}
+
+// setProtocolVersion sets protocol version used for requests
+func (c *sentryClient) setProtocolVersion(version int32) {
+	c.protocolVersion = version
+}
+
+// version returns protocol version used for requests
+func (c *sentryClient) version() int32 {
+	return c.protocolVersion
 }
 
 // CreateRole implements CreateRole API.
 func (c *sentryClient) CreateRole(name string) error {
 	arg := sentry_policy_service.NewTCreateSentryRoleRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.RoleName = name
 	result, err := c.client.CreateSentryRole(arg)
 	if err != nil {
-		return fmt.Errorf("failed to create Sentry role %s: %s", name, err)
+		return newTransportError(err, "failed to create Sentry role %s", name)
 	}
 
 	if result.GetStatus().GetValue() != 0 {
-		return newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 	return nil
 }
@@ -78,15 +104,16 @@ func (c *sentryClient) CreateRole(name string) error {
 // RemoveRole implements RemoveRole API
 func (c *sentryClient) RemoveRole(name string) error {
 	arg := sentry_policy_service.NewTDropSentryRoleRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.RoleName = name
 	result, err := c.client.DropSentryRole(arg)
 	if err != nil {
-		return fmt.Errorf("failed to remove Sentry role %s: %s", name, err)
+		return newTransportError(err, "failed to remove Sentry role %s", name)
 	}
 	if result.GetStatus().GetValue() != 0 {
-		return newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 	return nil
 }
@@ -95,6 +122,7 @@ func (c *sentryClient) RemoveRole(name string) error {
 func (c *sentryClient) ListRoleByGroup(group string) ([]string,
 	[]*Role, error) {
 	arg := sentry_policy_service.NewTListSentryRolesRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	if group == "" {
 		arg.GroupName = nil
@@ -104,13 +132,13 @@ func (c *sentryClient) ListRoleByGroup(group string) ([]string,
 
 	result, err := c.client.ListSentryRolesByGroup(arg)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to list Sentry roles: %s", err)
+		return nil, nil, newTransportError(err, "failed to list Sentry roles")
 	}
 
 	if result.GetStatus().GetValue() != 0 {
 		if result.GetStatus().GetValue() != 0 {
-			return nil, nil, newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-				result.GetStatus().Stack)
+			return nil, nil, newAPIError(result.GetStatus().Value,
+				result.GetStatus().Message, result.GetStatus().Stack)
 		}
 	}
 	roleNames := make([]string, 0, 8)
@@ -134,6 +162,7 @@ func (c *sentryClient) ListRoleByGroup(group string) ([]string,
 // AddGroupsToRole implements AddGroupsToRole API
 func (c *sentryClient) AddGroupsToRole(role string, groups []string) error {
 	arg := sentry_policy_service.NewTAlterSentryRoleAddGroupsRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.RoleName = role
 	groupsMap := make(map[*sentry_policy_service.TSentryGroup]bool)
@@ -145,11 +174,11 @@ func (c *sentryClient) AddGroupsToRole(role string, groups []string) error {
 	result, err := c.client.AlterSentryRoleAddGroups(arg)
 	// fmt.Println(result)
 	if err != nil {
-		return fmt.Errorf("failed to add groups: %s", err)
+		return newTransportError(err, "failed to add groups")
 	}
 	if result.GetStatus().GetValue() != 0 {
-		return newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 
 	return nil
@@ -158,6 +187,7 @@ func (c *sentryClient) AddGroupsToRole(role string, groups []string) error {
 // RemoveGroupsFromRole implements RemoveGroupsFromRole API
 func (c *sentryClient) RemoveGroupsFromRole(role string, groups []string) error {
 	arg := sentry_policy_service.NewTAlterSentryRoleDeleteGroupsRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.RoleName = role
 	groupsMap := make(map[*sentry_policy_service.TSentryGroup]bool)
@@ -169,46 +199,115 @@ func (c *sentryClient) RemoveGroupsFromRole(role string, groups []string) error
 	result, err := c.client.AlterSentryRoleDeleteGroups(arg)
 	// fmt.Println(result)
 	if err != nil {
-		return fmt.Errorf("failed to remove groups: %s", err)
+		return newTransportError(err, "failed to remove groups")
 	}
 	if result.GetStatus().GetValue() != 0 {
-		return newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 
 	return nil
 }
 
-// GrantPrivilege implements GrantPrivilege API
-func (c *sentryClient) GrantPrivilege(role string, priv *Privilege) error {
-	arg := sentry_policy_service.NewTAlterSentryRoleGrantPrivilegeRequest()
+// ListRoleByUser implements ListRoleByUser API
+func (c *sentryClient) ListRoleByUser(user string) ([]string,
+	[]*Role, error) {
+	arg := sentry_policy_service.NewTListSentryRolesForUserRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.RequestorUserName = c.userName
+	arg.UserName = user
+
+	result, err := c.client.ListSentryRolesByUser(arg)
+	if err != nil {
+		return nil, nil, newTransportError(err,
+			"failed to list Sentry roles for user %s", user)
+	}
+	if result.GetStatus().GetValue() != 0 {
+		return nil, nil, newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+	roleNames := make([]string, 0, len(result.Roles))
+	roles := make([]*Role, 0, len(result.Roles))
+
+	// Collect results
+	for role := range result.Roles {
+		roleNames = append(roleNames, role.RoleName)
+		groups := []string{}
+		for k := range role.Groups {
+			groups = append(groups, k.GetGroupName())
+		}
+		roles = append(roles,
+			&Role{Name: role.RoleName, Groups: groups})
+	}
+	return roleNames, roles, nil
+}
+
+// AddUsersToRole implements AddUsersToRole API
+func (c *sentryClient) AddUsersToRole(role string, users []string) error {
+	arg := sentry_policy_service.NewTAlterSentryRoleAddUsersRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.RoleName = role
+	usersMap := make(map[string]bool)
+	for _, user := range users {
+		usersMap[user] = true
+	}
+	arg.Users = usersMap
+	result, err := c.client.AlterSentryRoleAddUsers(arg)
+	if err != nil {
+		return newTransportError(err, "failed to add users")
+	}
+	if result.GetStatus().GetValue() != 0 {
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
 
-	tPrivilege := sentry_policy_service.NewTSentryPrivilege()
-	tPrivilege.Action = priv.Action
-	tPrivilege.ColumnName = priv.Column
-	tPrivilege.ServerName = priv.Server
-	tPrivilege.DbName = priv.Database
-	tPrivilege.TableName = priv.Table
-	tPrivilege.URI = priv.URI
+	return nil
+}
 
-	if priv.UnsetGrantOption {
-		tPrivilege.GrantOption = sentry_policy_service.TSentryGrantOption_UNSET
+// RemoveUsersFromRole implements RemoveUsersFromRole API
+func (c *sentryClient) RemoveUsersFromRole(role string, users []string) error {
+	arg := sentry_policy_service.NewTAlterSentryRoleDeleteUsersRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.RequestorUserName = c.userName
+	arg.RoleName = role
+	usersMap := make(map[string]bool)
+	for _, user := range users {
+		usersMap[user] = true
 	}
-	if priv.GrantOption {
-		tPrivilege.GrantOption = sentry_policy_service.TSentryGrantOption_TRUE
+	arg.Users = usersMap
+	result, err := c.client.AlterSentryRoleDeleteUsers(arg)
+	if err != nil {
+		return newTransportError(err, "failed to remove users")
+	}
+	if result.GetStatus().GetValue() != 0 {
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 
-	arg.Privilege = tPrivilege
+	return nil
+}
+
+// GrantPrivilege implements GrantPrivilege API
+func (c *sentryClient) GrantPrivilege(role string, priv *Privilege) error {
+	arg := sentry_policy_service.NewTAlterSentryRoleGrantPrivilegeRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.RequestorUserName = c.userName
+	arg.RoleName = role
+
+	// Validate the privilege, the scope is inferred by toTPrivilege()
+	if _, err := priv.InferScope(); err != nil {
+		return err
+	}
+	arg.Privilege = toTPrivilege(priv)
 	result, err := c.client.AlterSentryRoleGrantPrivilege(arg)
 
 	if err != nil {
-		return fmt.Errorf("failed to grant privilege: %s", err)
+		return newTransportError(err, "failed to grant privilege")
 	}
 	if result.GetStatus().GetValue() != 0 {
-		return newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 
 	return nil
@@ -217,30 +316,23 @@ func (c *sentryClient) GrantPrivilege(role string, priv *Privilege) error {
 // RevokePrivilege implements RevokePrivilege API
 func (c *sentryClient) RevokePrivilege(role string, priv *Privilege) error {
 	arg := sentry_policy_service.NewTAlterSentryRoleRevokePrivilegeRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.RoleName = role
 
-	tPrivilege := sentry_policy_service.NewTSentryPrivilege()
-	tPrivilege.Action = priv.Action
-	tPrivilege.ColumnName = priv.Column
-	tPrivilege.ServerName = priv.Server
-	tPrivilege.DbName = priv.Database
-	tPrivilege.TableName = priv.Table
-	tPrivilege.URI = priv.URI
-
-	if priv.GrantOption {
-		tPrivilege.GrantOption = sentry_policy_service.TSentryGrantOption_TRUE
+	// Validate the privilege, the scope is inferred by toTPrivilege()
+	if _, err := priv.InferScope(); err != nil {
+		return err
 	}
-
-	arg.Privilege = tPrivilege
+	arg.Privilege = toTPrivilege(priv)
 	result, err := c.client.AlterSentryRoleRevokePrivilege(arg)
 
 	if err != nil {
-		return fmt.Errorf("failed to revoke privilege: %s", err)
+		return newTransportError(err, "failed to revoke privilege")
 	}
 	if result.GetStatus().GetValue() != 0 {
-		return newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 
 	return nil
@@ -250,6 +342,7 @@ func (c *sentryClient) RevokePrivilege(role string, priv *Privilege) error {
 func (c *sentryClient) ListPrivilegesByRole(roleName string,
 	template *Privilege) ([]*Privilege, error) {
 	arg := sentry_policy_service.NewTListSentryPrivilegesRequest()
+	arg.ProtocolVersion = c.protocolVersion
 	arg.RequestorUserName = c.userName
 	arg.RoleName = roleName
 	if template != nil {
@@ -264,27 +357,309 @@ func (c *sentryClient) ListPrivilegesByRole(roleName string,
 
 	result, err := c.client.ListSentryPrivilegesByRole(arg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list privileges: %s", err)
+		return nil, newTransportError(err, "failed to list privileges")
 	}
 	if result.GetStatus().GetValue() != 0 {
-		return nil, newAPIError(fmt.Errorf("%s", result.GetStatus().Message),
-			result.GetStatus().Stack)
+		return nil, newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
 	}
 
 	privList := make([]*Privilege, 0, len(result.Privileges))
 	for tPriv := range result.Privileges {
-		privilege := &Privilege{
-			Server:   tPriv.ServerName,
-			Database: tPriv.DbName,
-			Table:    tPriv.TableName,
-			Column:   tPriv.ColumnName,
-			URI:      tPriv.URI,
-			Action:   tPriv.Action,
-		}
-		privilege.GrantOption = tPriv.GrantOption ==
-			sentry_policy_service.TSentryGrantOption_TRUE
-		privList = append(privList, privilege)
+		privList = append(privList, fromTPrivilege(tPriv))
 	}
 
 	return privList, nil
 }
+
+// DropPrivilegesOnObject implements DropPrivilegesOnObject API
+func (c *sentryClient) DropPrivilegesOnObject(object *Privilege) error {
+	arg := sentry_policy_service.NewTDropPrivilegesRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.RequestorUserName = c.userName
+	arg.Authorizable = toTAuthorizable(object)
+
+	result, err := c.client.DropSentryPrivilege(arg)
+	if err != nil {
+		return newTransportError(err, "failed to drop privileges")
+	}
+	if result.GetStatus().GetValue() != 0 {
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+	return nil
+}
+
+// RenamePrivilegesOnObject implements RenamePrivilegesOnObject API
+func (c *sentryClient) RenamePrivilegesOnObject(from *Privilege,
+	to *Privilege) error {
+	arg := sentry_policy_service.NewTRenamePrivilegesRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.RequestorUserName = c.userName
+	arg.OldAuthorizable = toTAuthorizable(from)
+	arg.NewAuthorizable_ = toTAuthorizable(to)
+
+	result, err := c.client.RenameSentryPrivilege(arg)
+	if err != nil {
+		return newTransportError(err, "failed to rename privileges")
+	}
+	if result.GetStatus().GetValue() != 0 {
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+	return nil
+}
+
+// ListPrivilegesByObject implements ListPrivilegesByObject API
+func (c *sentryClient) ListPrivilegesByObject(object *Privilege,
+	groups []string) (map[string][]*Privilege, error) {
+	arg := sentry_policy_service.NewTListSentryPrivilegesByAuthRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.RequestorUserName = c.userName
+	arg.AuthorizableSet = map[*sentry_policy_service.TSentryAuthorizable]bool{
+		toTAuthorizable(object): true,
+	}
+	if len(groups) != 0 {
+		arg.Groups = toSet(groups)
+	}
+	arg.RoleSet = &sentry_policy_service.TSentryActiveRoleSet{
+		All:   true,
+		Roles: map[string]bool{},
+	}
+
+	result, err := c.client.ListSentryPrivilegesByAuthorizable(arg)
+	if err != nil {
+		return nil, newTransportError(err, "failed to list privileges")
+	}
+	if result.GetStatus().GetValue() != 0 {
+		return nil, newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+
+	rolePrivileges := make(map[string][]*Privilege)
+	for _, privMap := range result.PrivilegesMapByAuth {
+		if privMap == nil {
+			continue
+		}
+		for role, tPrivs := range privMap.PrivilegeMap {
+			for tPriv := range tPrivs {
+				rolePrivileges[role] = append(rolePrivileges[role],
+					fromTPrivilege(tPriv))
+			}
+		}
+	}
+	return rolePrivileges, nil
+}
+
+// EffectivePrivileges implements EffectivePrivileges API
+func (c *sentryClient) EffectivePrivileges(groups []string, users []string,
+	activeRoles []string, object *Privilege) ([]string, error) {
+	arg := sentry_policy_service.NewTListSentryPrivilegesForProviderRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.Groups = toSet(groups)
+	if len(users) != 0 {
+		arg.Users = toSet(users)
+	}
+	arg.RoleSet = &sentry_policy_service.TSentryActiveRoleSet{
+		All:   len(activeRoles) == 0,
+		Roles: toSet(activeRoles),
+	}
+	if object != nil {
+		arg.AuthorizableHierarchy = toTAuthorizable(object)
+	}
+
+	result, err := c.client.ListSentryPrivilegesForProvider(arg)
+	if err != nil {
+		return nil, newTransportError(err, "failed to list privileges")
+	}
+	if result.GetStatus().GetValue() != 0 {
+		return nil, newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+
+	privileges := make([]string, 0, len(result.Privileges))
+	for priv := range result.Privileges {
+		privileges = append(privileges, priv)
+	}
+	sort.Strings(privileges)
+	return privileges, nil
+}
+
+// GetConfigValue implements GetConfigValue API
+func (c *sentryClient) GetConfigValue(name string,
+	defaultValue string) (string, error) {
+	arg := sentry_policy_service.NewTSentryConfigValueRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.PropertyName = name
+	arg.DefaultValue = &defaultValue
+
+	result, err := c.client.GetSentryConfigValue(arg)
+	if err != nil {
+		return "", newTransportError(err, "failed to get config value for %s", name)
+	}
+	if result.GetStatus().GetValue() != 0 {
+		return "", newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+	if result.Value == nil {
+		return defaultValue, nil
+	}
+	return *result.Value, nil
+}
+
+// ExportPolicy implements ExportPolicy API
+func (c *sentryClient) ExportPolicy(objectPath string) (*Policy, error) {
+	arg := sentry_policy_service.NewTSentryExportMappingDataRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.RequestorUserName = c.userName
+	if objectPath != "" {
+		arg.ObjectPath = &objectPath
+	}
+
+	result, err := c.client.ExportSentryMappingData(arg)
+	if err != nil {
+		return nil, newTransportError(err, "failed to export policy")
+	}
+	if result.GetStatus().GetValue() != 0 {
+		return nil, newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+
+	policy := NewPolicy()
+	data := result.GetMappingData()
+	if data == nil {
+		return policy, nil
+	}
+	for group, roles := range data.GroupRolesMap {
+		for role := range roles {
+			policy.Groups[group] = append(policy.Groups[group], role)
+		}
+	}
+	for user, roles := range data.UserRolesMap {
+		for role := range roles {
+			policy.Users[user] = append(policy.Users[user], role)
+		}
+	}
+	for role, tPrivs := range data.RolePrivilegesMap {
+		privs := make([]*Privilege, 0, len(tPrivs))
+		for tPriv := range tPrivs {
+			privs = append(privs, fromTPrivilege(tPriv))
+		}
+		policy.Privileges[role] = privs
+	}
+	policy.Sort()
+	return policy, nil
+}
+
+// ImportPolicy implements ImportPolicy API
+func (c *sentryClient) ImportPolicy(policy *Policy, overwrite bool) error {
+	arg := sentry_policy_service.NewTSentryImportMappingDataRequest()
+	arg.ProtocolVersion = c.protocolVersion
+	arg.RequestorUserName = c.userName
+	arg.OverwriteRole = overwrite
+
+	data := sentry_policy_service.NewTSentryMappingData()
+	data.GroupRolesMap = make(map[string]map[string]bool)
+	for group, roles := range policy.Groups {
+		data.GroupRolesMap[group] = toSet(roles)
+	}
+	data.UserRolesMap = make(map[string]map[string]bool)
+	for user, roles := range policy.Users {
+		data.UserRolesMap[user] = toSet(roles)
+	}
+	data.RolePrivilegesMap =
+		make(map[string]map[*sentry_policy_service.TSentryPrivilege]bool)
+	for role, privs := range policy.Privileges {
+		tPrivs := make(map[*sentry_policy_service.TSentryPrivilege]bool)
+		for _, priv := range privs {
+			tPrivs[toTPrivilege(priv)] = true
+		}
+		data.RolePrivilegesMap[role] = tPrivs
+	}
+	arg.MappingData = data
+
+	result, err := c.client.ImportSentryMappingData(arg)
+	if err != nil {
+		return newTransportError(err, "failed to import policy")
+	}
+	if result.GetStatus().GetValue() != 0 {
+		return newAPIError(result.GetStatus().Value,
+			result.GetStatus().Message, result.GetStatus().Stack)
+	}
+	return nil
+}
+
+// toTPrivilege converts Privilege to its Thrift representation
+func toTPrivilege(priv *Privilege) *sentry_policy_service.TSentryPrivilege {
+	tPrivilege := sentry_policy_service.NewTSentryPrivilege()
+	tPrivilege.PrivilegeScope = strings.ToUpper(priv.Scope)
+	if tPrivilege.PrivilegeScope == "" {
+		tPrivilege.PrivilegeScope, _ = priv.InferScope()
+	}
+	tPrivilege.Action = priv.Action
+	tPrivilege.ColumnName = priv.Column
+	tPrivilege.ServerName = priv.Server
+	tPrivilege.DbName = priv.Database
+	tPrivilege.TableName = priv.Table
+	tPrivilege.URI = priv.URI
+
+	if priv.UnsetGrantOption {
+		tPrivilege.GrantOption = sentry_policy_service.TSentryGrantOption_UNSET
+	}
+	if priv.GrantOption {
+		tPrivilege.GrantOption = sentry_policy_service.TSentryGrantOption_TRUE
+	}
+	return tPrivilege
+}
+
+// fromTPrivilege converts Thrift privilege representation to Privilege
+func fromTPrivilege(tPriv *sentry_policy_service.TSentryPrivilege) *Privilege {
+	privilege := &Privilege{
+		Scope:    tPriv.PrivilegeScope,
+		Server:   tPriv.ServerName,
+		Database: tPriv.DbName,
+		Table:    tPriv.TableName,
+		Column:   tPriv.ColumnName,
+		URI:      tPriv.URI,
+		Action:   tPriv.Action,
+		GrantOption: tPriv.GrantOption ==
+			sentry_policy_service.TSentryGrantOption_TRUE,
+	}
+	if tPriv.CreateTime != nil {
+		privilege.CreateTime = *tPriv.CreateTime
+	}
+	// Scope is required, but older servers may not return it
+	if privilege.Scope == "" {
+		privilege.Scope, _ = privilege.InferScope()
+	}
+	return privilege
+}
+
+// toTAuthorizable converts object part of the privilege to the Thrift
+// authorizable. Only non-empty parts are set.
+func toTAuthorizable(priv *Privilege) *sentry_policy_service.TSentryAuthorizable {
+	auth := sentry_policy_service.NewTSentryAuthorizable()
+	auth.Server = priv.Server
+	if priv.Database != "" {
+		auth.Db = &priv.Database
+	}
+	if priv.Table != "" {
+		auth.Table = &priv.Table
+	}
+	if priv.Column != "" {
+		auth.Column = &priv.Column
+	}
+	if priv.URI != "" {
+		auth.URI = &priv.URI
+	}
+	return auth
+}
+
+// toSet converts list of strings to a Thrift set
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,901 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sentryapitest provides a conformance suite for sentryapi.ClientAPI
+// implementations.
+//
+// The suite verifies that a client, or a decorator wrapping one, preserves
+// the semantics of the Sentry API for both the legacy and generic services:
+//
+//	func TestClient(t *testing.T) {
+//		sentryapitest.Run(t, func(t testing.TB,
+//			protocol sentryapi.ProtocolType) sentryapi.ClientAPI {
+//			client, err := sentryapi.GetClient(protocol, host, port,
+//				sentryapitest.Component, "admin")
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return newCachingClient(client)
+//		})
+//	}
+//
+// Cases create roles with unique names and remove them when done, so the
+// suite can run against a shared Sentry server. The requestor should be a
+// Sentry admin.
+package sentryapitest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+)
+
+// Component is the component used by generic clients in the suite
+const Component = "solr"
+
+const (
+	serverName  = "server1"
+	serviceName = "service1"
+	testGroup   = "sentryapitest_group"
+	testUser    = "sentryapitest_user"
+)
+
+// Factory returns a client for the given protocol. Generic clients should
+// use Component. The suite closes every client it gets from the factory.
+type Factory func(t testing.TB, protocol sentryapi.ProtocolType) sentryapi.ClientAPI
+
+// suite is the state of a single case
+//   client - client under test
+//   protocol - client protocol
+//   prefix - prefix of role and object names which makes them unique
+type suite struct {
+	client   sentryapi.ClientAPI
+	protocol sentryapi.ProtocolType
+	prefix   string
+}
+
+// testCase is a single conformance check
+//   name - subtest name
+//   legacy - true if the case only applies to the legacy service
+//   run - the check itself
+type testCase struct {
+	name   string
+	legacy bool
+	run    func(t *testing.T, s *suite)
+}
+
+var testCases = []testCase{
+	{name: "CreateRemoveRole", run: testCreateRemoveRole},
+	{name: "DuplicateRole", run: testDuplicateRole},
+	{name: "RemoveMissingRole", run: testRemoveMissingRole},
+	{name: "Groups", run: testGroups},
+	{name: "GroupsMissingRole", run: testGroupsMissingRole},
+	{name: "Users", run: testUsers},
+	{name: "GrantRevoke", run: testGrantRevoke},
+	{name: "GrantTwice", run: testGrantTwice},
+	{name: "RevokeNotGranted", run: testRevokeNotGranted},
+	{name: "GrantOption", run: testGrantOption},
+	{name: "Scope", legacy: true, run: testScope},
+	{name: "RevokeAnyGrantOption", legacy: true, run: testRevokeAnyGrantOption},
+	{name: "GrantMissingRole", run: testGrantMissingRole},
+	{name: "ListMissingRole", run: testListMissingRole},
+	{name: "ExportImport", run: testExportImport},
+	{name: "DropPrivilegesOnObject", run: testDropPrivilegesOnObject},
+	{name: "RenamePrivilegesOnObject", run: testRenamePrivilegesOnObject},
+	{name: "ListPrivilegesByObject", run: testListPrivilegesByObject},
+	{name: "EffectivePrivileges", run: testEffectivePrivileges},
+	{name: "GetConfigValue", run: testGetConfigValue},
+	{name: "ListPrivilegesFilter", run: testListPrivilegesFilter},
+	{name: "Hierarchy", run: testHierarchy},
+}
+
+// Run runs the conformance suite for legacy and generic clients returned by
+// the factory
+func Run(t *testing.T, factory Factory) {
+	for _, protocol := range []sentryapi.ProtocolType{
+		sentryapi.PolicyProtocol,
+		sentryapi.GenericPolicyProtocol,
+	} {
+		protocol := protocol
+		t.Run(protocolName(protocol), func(t *testing.T) {
+			RunProtocol(t, protocol, factory)
+		})
+	}
+}
+
+// RunProtocol runs the conformance suite for clients of a single protocol
+func RunProtocol(t *testing.T, protocol sentryapi.ProtocolType, factory Factory) {
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.legacy && protocol != sentryapi.PolicyProtocol {
+				t.Skip("not supported by the generic service")
+			}
+			s := newSuite(t, protocol, factory)
+			defer s.client.Close()
+			tc.run(t, s)
+		})
+	}
+}
+
+// Benchmark runs role and privilege benchmarks for legacy and generic
+// clients returned by the factory
+func Benchmark(b *testing.B, factory Factory) {
+	for _, protocol := range []sentryapi.ProtocolType{
+		sentryapi.PolicyProtocol,
+		sentryapi.GenericPolicyProtocol,
+	} {
+		protocol := protocol
+		b.Run(protocolName(protocol), func(b *testing.B) {
+			s := newSuite(b, protocol, factory)
+			defer s.client.Close()
+			b.Run("CreateRole", s.benchmarkCreateRole)
+			b.Run("CreateRoles", s.benchmarkCreateRoles)
+			b.Run("ListPrivilegesByRole", s.benchmarkListPrivilegesByRole)
+			b.Run("GrantAndRevokePrivilege", s.benchmarkGrantAndRevokePrivilege)
+		})
+	}
+}
+
+// protocolName returns subtest name for the protocol
+func protocolName(protocol sentryapi.ProtocolType) string {
+	if protocol == sentryapi.GenericPolicyProtocol {
+		return "Generic"
+	}
+	return "Policy"
+}
+
+func newSuite(t testing.TB, protocol sentryapi.ProtocolType, factory Factory) *suite {
+	return &suite{
+		client:   factory(t, protocol),
+		protocol: protocol,
+		prefix:   fmt.Sprintf("sentryapitest_%d", time.Now().UnixNano()),
+	}
+}
+
+// generic returns true for generic service clients
+func (s *suite) generic() bool {
+	return s.protocol == sentryapi.GenericPolicyProtocol
+}
+
+// roleName returns unique role name. Sentry keeps role names in lower case.
+func (s *suite) roleName(name string) string {
+	return strings.ToLower(s.prefix + "_" + name)
+}
+
+// createRole creates a role with unique name and returns the name
+func (s *suite) createRole(t testing.TB, name string) string {
+	roleName := s.roleName(name)
+	if err := s.client.CreateRole(roleName); err != nil {
+		t.Fatalf("failed to create role %s: %v", roleName, err)
+	}
+	return roleName
+}
+
+// removeRole removes a role created by createRole
+func (s *suite) removeRole(t testing.TB, roleName string) {
+	if err := s.client.RemoveRole(roleName); err != nil {
+		t.Errorf("failed to remove role %s: %v", roleName, err)
+	}
+}
+
+// privilege returns a privilege on the named object which is valid for the
+// client protocol
+func (s *suite) privilege(object string) *sentryapi.Privilege {
+	if s.generic() {
+		return &sentryapi.Privilege{
+			Service: serviceName,
+			Authorizables: []sentryapi.Authorizable{
+				{Type: "collection", Name: s.prefix + "_" + object},
+			},
+			Action: "query",
+		}
+	}
+	return &sentryapi.Privilege{
+		Server:   serverName,
+		Database: s.prefix + "_" + object,
+		Table:    "t1",
+		Action:   "select",
+	}
+}
+
+// template returns the template for listing role privileges
+func (s *suite) template() *sentryapi.Privilege {
+	if s.generic() {
+		return &sentryapi.Privilege{Service: serviceName}
+	}
+	return nil
+}
+
+// object returns the object part of the privilege
+func object(priv *sentryapi.Privilege) *sentryapi.Privilege {
+	obj := *priv
+	obj.Action = ""
+	obj.GrantOption = false
+	return &obj
+}
+
+// listPrivileges returns privileges of the role
+func (s *suite) listPrivileges(t testing.TB, roleName string) []*sentryapi.Privilege {
+	privs, err := s.client.ListPrivilegesByRole(roleName, s.template())
+	if err != nil {
+		t.Fatalf("failed to list privileges for %s: %v", roleName, err)
+	}
+	return privs
+}
+
+// expectProviderPrivileges verifies that provider format privileges match
+// the expected ones. Provider privileges don't include the service.
+func (s *suite) expectProviderPrivileges(t testing.TB, op string,
+	privs []string, expected ...*sentryapi.Privilege) {
+	if len(privs) != len(expected) {
+		t.Errorf("%s: expected %d privileges, got %v", op, len(expected), privs)
+		return
+	}
+	parse := sentryapi.ParsePrivilege
+	if s.generic() {
+		parse = sentryapi.ParseGenericPrivilege
+	}
+	for i, str := range privs {
+		priv, err := parse(str)
+		if err != nil {
+			t.Errorf("%s: %v", op, err)
+			continue
+		}
+		want := *expected[i]
+		want.Service = ""
+		if !samePrivilege(priv, &want) {
+			t.Errorf("%s: expected %s, got %s", op,
+				describe([]*sentryapi.Privilege{&want}), str)
+		}
+	}
+}
+
+// samePrivilege returns true if both privileges refer to the same object,
+// action and grant option. Sentry keeps object names in lower case, except
+// for URIs.
+func samePrivilege(a *sentryapi.Privilege, b *sentryapi.Privilege) bool {
+	if len(a.Authorizables) != len(b.Authorizables) {
+		return false
+	}
+	for i := range a.Authorizables {
+		if !strings.EqualFold(a.Authorizables[i].Type, b.Authorizables[i].Type) ||
+			!strings.EqualFold(a.Authorizables[i].Name, b.Authorizables[i].Name) {
+			return false
+		}
+	}
+	return strings.EqualFold(a.Server, b.Server) &&
+		strings.EqualFold(a.Database, b.Database) &&
+		strings.EqualFold(a.Table, b.Table) &&
+		strings.EqualFold(a.Column, b.Column) &&
+		a.URI == b.URI &&
+		strings.EqualFold(a.Service, b.Service) &&
+		strings.EqualFold(a.Action, b.Action) &&
+		a.GrantOption == b.GrantOption
+}
+
+// expectPrivileges verifies that the role has exactly the given privileges
+func (s *suite) expectPrivileges(t testing.TB, roleName string,
+	expected ...*sentryapi.Privilege) {
+	privs := s.listPrivileges(t, roleName)
+	if len(privs) != len(expected) {
+		t.Fatalf("expected %d privileges for %s, got %d: %s", len(expected),
+			roleName, len(privs), describe(privs))
+	}
+	for _, want := range expected {
+		found := false
+		for _, priv := range privs {
+			if samePrivilege(priv, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("privilege %s not found for %s in %s", describe(
+				[]*sentryapi.Privilege{want}), roleName, describe(privs))
+		}
+	}
+}
+
+// describe returns printable representation of privileges
+func describe(privs []*sentryapi.Privilege) string {
+	result := make([]string, 0, len(privs))
+	for _, priv := range privs {
+		result = append(result, fmt.Sprintf("%+v", *priv))
+	}
+	return "[" + strings.Join(result, ", ") + "]"
+}
+
+// expectError verifies that err matches the target error
+func expectError(t testing.TB, err error, target error, op string) {
+	if !errors.Is(err, target) {
+		t.Errorf("%s: expected %v, got %v", op, target, err)
+	}
+}
+
+// contains returns true if the list contains the name
+func contains(list []string, name string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func testCreateRemoveRole(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "role")
+	names, _, err := s.client.ListRoleByGroup("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(names, roleName) {
+		t.Errorf("role %s not listed in %v", roleName, names)
+	}
+	s.removeRole(t, roleName)
+	if names, _, err = s.client.ListRoleByGroup(""); err != nil {
+		t.Fatal(err)
+	}
+	if contains(names, roleName) {
+		t.Errorf("removed role %s is still listed", roleName)
+	}
+}
+
+func testDuplicateRole(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "duplicate")
+	defer s.removeRole(t, roleName)
+	expectError(t, s.client.CreateRole(roleName), sentryapi.ErrAlreadyExists,
+		"create duplicate role")
+	expectError(t, s.client.CreateRole(strings.ToUpper(roleName)),
+		sentryapi.ErrAlreadyExists, "create duplicate role in upper case")
+}
+
+func testRemoveMissingRole(t *testing.T, s *suite) {
+	expectError(t, s.client.RemoveRole(s.roleName("missing")),
+		sentryapi.ErrNoSuchObject, "remove missing role")
+}
+
+func testGroups(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "groups")
+	defer s.removeRole(t, roleName)
+	group := s.prefix + "_" + testGroup
+	if err := s.client.AddGroupsToRole(roleName, []string{group}); err != nil {
+		t.Fatal(err)
+	}
+	names, roles, err := s.client.ListRoleByGroup(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || !strings.EqualFold(names[0], roleName) {
+		t.Fatalf("expected role %s for group %s, got %v", roleName, group, names)
+	}
+	if len(roles) != 1 || !contains(roles[0].Groups, group) {
+		t.Errorf("expected group %s in role %s", group, roleName)
+	}
+	if err := s.client.RemoveGroupsFromRole(roleName,
+		[]string{group}); err != nil {
+		t.Fatal(err)
+	}
+	// Sentry may report a group without roles as missing
+	names, _, err = s.client.ListRoleByGroup(group)
+	if err != nil && !errors.Is(err, sentryapi.ErrNoSuchObject) {
+		t.Fatal(err)
+	}
+	if contains(names, roleName) {
+		t.Errorf("role %s is still granted to group %s", roleName, group)
+	}
+}
+
+func testGroupsMissingRole(t *testing.T, s *suite) {
+	roleName := s.roleName("missing")
+	groups := []string{s.prefix + "_" + testGroup}
+	expectError(t, s.client.AddGroupsToRole(roleName, groups),
+		sentryapi.ErrNoSuchObject, "add groups to missing role")
+	expectError(t, s.client.RemoveGroupsFromRole(roleName, groups),
+		sentryapi.ErrNoSuchObject, "remove groups from missing role")
+}
+
+func testUsers(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "users")
+	defer s.removeRole(t, roleName)
+	user := s.prefix + "_" + testUser
+	if s.generic() {
+		expectError(t, s.client.AddUsersToRole(roleName, []string{user}),
+			sentryapi.ErrNotSupported, "add users")
+		expectError(t, s.client.RemoveUsersFromRole(roleName, []string{user}),
+			sentryapi.ErrNotSupported, "remove users")
+		_, _, err := s.client.ListRoleByUser(user)
+		expectError(t, err, sentryapi.ErrNotSupported, "list roles by user")
+		return
+	}
+	if err := s.client.AddUsersToRole(roleName, []string{user}); err != nil {
+		t.Fatal(err)
+	}
+	names, roles, err := s.client.ListRoleByUser(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || !strings.EqualFold(names[0], roleName) {
+		t.Fatalf("expected role %s for user %s, got %v", roleName, user, names)
+	}
+	if len(roles) != 1 || !strings.EqualFold(roles[0].Name, roleName) ||
+		roles[0].Users != nil {
+		t.Errorf("expected role %s without users, got %+v", roleName, roles)
+	}
+	if err := s.client.RemoveUsersFromRole(roleName,
+		[]string{user}); err != nil {
+		t.Fatal(err)
+	}
+	names, _, err = s.client.ListRoleByUser(user)
+	if err != nil && !errors.Is(err, sentryapi.ErrNoSuchObject) {
+		t.Fatal(err)
+	}
+	if contains(names, roleName) {
+		t.Errorf("role %s is still granted to user %s", roleName, user)
+	}
+	expectError(t, s.client.AddUsersToRole(s.roleName("missing"),
+		[]string{user}), sentryapi.ErrNoSuchObject, "add users to missing role")
+}
+
+func testGrantRevoke(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "grant")
+	defer s.removeRole(t, roleName)
+	first := s.privilege("first")
+	second := s.privilege("second")
+	for _, priv := range []*sentryapi.Privilege{first, second} {
+		if err := s.client.GrantPrivilege(roleName, priv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.expectPrivileges(t, roleName, first, second)
+	if err := s.client.RevokePrivilege(roleName, first); err != nil {
+		t.Fatal(err)
+	}
+	s.expectPrivileges(t, roleName, second)
+	if err := s.client.RevokePrivilege(roleName, second); err != nil {
+		t.Fatal(err)
+	}
+	s.expectPrivileges(t, roleName)
+}
+
+func testGrantTwice(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "granttwice")
+	defer s.removeRole(t, roleName)
+	priv := s.privilege("object")
+	for i := 0; i < 2; i++ {
+		if err := s.client.GrantPrivilege(roleName, priv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.expectPrivileges(t, roleName, priv)
+}
+
+func testRevokeNotGranted(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "revoke")
+	defer s.removeRole(t, roleName)
+	granted := s.privilege("granted")
+	if err := s.client.GrantPrivilege(roleName, granted); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.client.RevokePrivilege(roleName,
+		s.privilege("other")); err != nil {
+		t.Fatalf("revoking privilege which is not granted: %v", err)
+	}
+	s.expectPrivileges(t, roleName, granted)
+}
+
+func testGrantOption(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "grantoption")
+	defer s.removeRole(t, roleName)
+	priv := s.privilege("object")
+	priv.GrantOption = true
+	if err := s.client.GrantPrivilege(roleName, priv); err != nil {
+		t.Fatal(err)
+	}
+	s.expectPrivileges(t, roleName, priv)
+	if err := s.client.RevokePrivilege(roleName, priv); err != nil {
+		t.Fatal(err)
+	}
+	s.expectPrivileges(t, roleName)
+}
+
+func testScope(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "scope")
+	defer s.removeRole(t, roleName)
+	if err := s.client.GrantPrivilege(roleName, s.privilege("object")); err != nil {
+		t.Fatal(err)
+	}
+	privs := s.listPrivileges(t, roleName)
+	if len(privs) != 1 || privs[0].Scope != sentryapi.ScopeTable {
+		t.Errorf("expected %s scope, got %s", sentryapi.ScopeTable, describe(privs))
+	}
+	invalid := &sentryapi.Privilege{Server: serverName, Database: s.prefix,
+		Column: "c1", Action: "select"}
+	expectError(t, s.client.GrantPrivilege(roleName, invalid),
+		sentryapi.ErrInvalidInput, "grant column privilege without table")
+}
+
+// Revoke with unset grant option removes the privilege with any grant option
+func testRevokeAnyGrantOption(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "revokeany")
+	defer s.removeRole(t, roleName)
+	priv := s.privilege("object")
+	priv.GrantOption = true
+	if err := s.client.GrantPrivilege(roleName, priv); err != nil {
+		t.Fatal(err)
+	}
+	revoked := s.privilege("object")
+	revoked.UnsetGrantOption = true
+	revoked.Scope = sentryapi.ScopeTable
+	if err := s.client.RevokePrivilege(roleName, revoked); err != nil {
+		t.Fatal(err)
+	}
+	s.expectPrivileges(t, roleName)
+	revoked.Scope = sentryapi.ScopeDatabase
+	expectError(t, s.client.RevokePrivilege(roleName, revoked),
+		sentryapi.ErrInvalidInput, "revoke with mismatched scope")
+}
+
+func testGrantMissingRole(t *testing.T, s *suite) {
+	roleName := s.roleName("missing")
+	expectError(t, s.client.GrantPrivilege(roleName, s.privilege("object")),
+		sentryapi.ErrNoSuchObject, "grant to missing role")
+	expectError(t, s.client.RevokePrivilege(roleName, s.privilege("object")),
+		sentryapi.ErrNoSuchObject, "revoke from missing role")
+}
+
+func testListMissingRole(t *testing.T, s *suite) {
+	_, err := s.client.ListPrivilegesByRole(s.roleName("missing"), s.template())
+	expectError(t, err, sentryapi.ErrNoSuchObject, "list privileges for missing role")
+}
+
+func testExportImport(t *testing.T, s *suite) {
+	policyIO, ok := s.client.(sentryapi.PolicyIO)
+	if s.generic() {
+		if ok {
+			t.Error("generic client shouldn't support policy export")
+		}
+		return
+	}
+	if !ok {
+		t.Skip("client doesn't support policy export")
+	}
+	roleName := s.createRole(t, "export")
+	defer s.removeRole(t, roleName)
+	group := s.prefix + "_" + testGroup
+	if err := s.client.AddGroupsToRole(roleName, []string{group}); err != nil {
+		t.Fatal(err)
+	}
+	priv := s.privilege("export")
+	if err := s.client.GrantPrivilege(roleName, priv); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := policyIO.ExportPolicy("db=" + priv.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(policy.Groups[group], roleName) {
+		t.Errorf("expected role %s for group %s, got %v", roleName, group,
+			policy.Groups[group])
+	}
+	exported := policy.Privileges[roleName]
+	if len(exported) != 1 || !samePrivilege(exported[0], priv) {
+		t.Fatalf("expected exported privilege %s, got %s",
+			describe([]*sentryapi.Privilege{priv}), describe(exported))
+	}
+
+	// Import exported privileges into a new role
+	imported := s.roleName("import")
+	policy = sentryapi.NewPolicy()
+	policy.Groups[group] = []string{imported}
+	policy.Privileges[imported] = exported
+	if err := policyIO.ImportPolicy(policy, false); err != nil {
+		t.Fatal(err)
+	}
+	defer s.removeRole(t, imported)
+	s.expectPrivileges(t, imported, priv)
+	names, _, err := s.client.ListRoleByGroup(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(names, roleName) || !contains(names, imported) {
+		t.Errorf("expected roles %s and %s for group %s, got %v", roleName,
+			imported, group, names)
+	}
+
+	// Overwrite replaces privileges of the existing role
+	other := s.privilege("other")
+	policy = sentryapi.NewPolicy()
+	policy.Privileges[imported] = []*sentryapi.Privilege{other}
+	if err := policyIO.ImportPolicy(policy, true); err != nil {
+		t.Fatal(err)
+	}
+	s.expectPrivileges(t, imported, other)
+}
+
+func testDropPrivilegesOnObject(t *testing.T, s *suite) {
+	first := s.createRole(t, "drop1")
+	defer s.removeRole(t, first)
+	second := s.createRole(t, "drop2")
+	defer s.removeRole(t, second)
+	dropped := s.privilege("dropped")
+	kept := s.privilege("kept")
+	for _, grant := range []struct {
+		role string
+		priv *sentryapi.Privilege
+	}{{first, dropped}, {first, kept}, {second, dropped}} {
+		if err := s.client.GrantPrivilege(grant.role, grant.priv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.client.DropPrivilegesOnObject(object(dropped)); err != nil {
+		t.Fatal(err)
+	}
+	s.expectPrivileges(t, first, kept)
+	s.expectPrivileges(t, second)
+}
+
+func testRenamePrivilegesOnObject(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "rename")
+	defer s.removeRole(t, roleName)
+	from := s.privilege("from")
+	kept := s.privilege("kept")
+	for _, priv := range []*sentryapi.Privilege{from, kept} {
+		if err := s.client.GrantPrivilege(roleName, priv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	to := s.privilege("to")
+	if err := s.client.RenamePrivilegesOnObject(object(from),
+		object(to)); err != nil {
+		t.Fatal(err)
+	}
+	s.expectPrivileges(t, roleName, to, kept)
+}
+
+func testListPrivilegesByObject(t *testing.T, s *suite) {
+	first := s.createRole(t, "who1")
+	defer s.removeRole(t, first)
+	second := s.createRole(t, "who2")
+	defer s.removeRole(t, second)
+	firstGroup := s.prefix + "_" + testGroup + "1"
+	secondGroup := s.prefix + "_" + testGroup + "2"
+	priv := s.privilege("object")
+	other := s.privilege("other")
+	for _, grant := range []struct {
+		role  string
+		group string
+		priv  *sentryapi.Privilege
+	}{{first, firstGroup, priv}, {second, secondGroup, priv},
+		{second, secondGroup, other}} {
+		if err := s.client.AddGroupsToRole(grant.role,
+			[]string{grant.group}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.client.GrantPrivilege(grant.role, grant.priv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		groups []string
+		roles  []string
+	}{
+		{nil, []string{first, second}},
+		{[]string{firstGroup}, []string{first}},
+	} {
+		result, err := s.client.ListPrivilegesByObject(object(priv), tt.groups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != len(tt.roles) {
+			t.Errorf("groups %v: expected roles %v, got %v", tt.groups,
+				tt.roles, result)
+			continue
+		}
+		for _, role := range tt.roles {
+			privs := result[role]
+			if len(privs) != 1 || !samePrivilege(privs[0], priv) {
+				t.Errorf("groups %v: expected %s for role %s, got %s", tt.groups,
+					describe([]*sentryapi.Privilege{priv}), role, describe(privs))
+			}
+		}
+	}
+}
+
+func testEffectivePrivileges(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "effective")
+	defer s.removeRole(t, roleName)
+	inactive := s.createRole(t, "inactive")
+	defer s.removeRole(t, inactive)
+	group := s.prefix + "_" + testGroup
+	if err := s.client.AddGroupsToRole(roleName, []string{group}); err != nil {
+		t.Fatal(err)
+	}
+	priv := s.privilege("object")
+	for _, granted := range []*sentryapi.Privilege{priv, s.privilege("other")} {
+		if err := s.client.GrantPrivilege(roleName, granted); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name     string
+		groups   []string
+		active   []string
+		expected []*sentryapi.Privilege
+	}{
+		{"all roles", []string{group}, nil, []*sentryapi.Privilege{priv}},
+		{"active role", []string{group}, []string{roleName},
+			[]*sentryapi.Privilege{priv}},
+		{"inactive role", []string{group}, []string{inactive}, nil},
+		{"other group", []string{group + "_other"}, nil, nil},
+	} {
+		privs, err := s.client.EffectivePrivileges(tt.groups, nil, tt.active,
+			object(priv))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		s.expectProviderPrivileges(t, tt.name, privs, tt.expected...)
+	}
+
+	user := s.prefix + "_" + testUser
+	if s.generic() {
+		_, err := s.client.EffectivePrivileges(nil, []string{user}, nil,
+			object(priv))
+		expectError(t, err, sentryapi.ErrNotSupported, "user privileges")
+		_, err = s.client.EffectivePrivileges([]string{group}, nil, nil, nil)
+		expectError(t, err, sentryapi.ErrInvalidInput, "no object")
+		_, err = s.client.EffectivePrivileges([]string{group}, nil, nil,
+			&sentryapi.Privilege{Authorizables: priv.Authorizables})
+		expectError(t, err, sentryapi.ErrInvalidInput, "no service")
+		return
+	}
+	if err := s.client.AddUsersToRole(roleName, []string{user}); err != nil {
+		t.Fatal(err)
+	}
+	privs, err := s.client.EffectivePrivileges(nil, []string{user}, nil,
+		object(priv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.expectProviderPrivileges(t, "user", privs, priv)
+}
+
+func testGetConfigValue(t *testing.T, s *suite) {
+	name := "sentry." + s.prefix
+	value, err := s.client.GetConfigValue(name, "default")
+	if s.generic() {
+		expectError(t, err, sentryapi.ErrNotSupported, "get config value")
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "default" {
+		t.Errorf("expected default value for %s, got %q", name, value)
+	}
+	// Sentry only allows reading its own properties
+	_, err = s.client.GetConfigValue(s.prefix, "")
+	expectError(t, err, sentryapi.ErrAccessDenied, "get non-sentry property")
+}
+
+func testListPrivilegesFilter(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "filter")
+	defer s.removeRole(t, roleName)
+	first := s.privilege("first")
+	second := s.privilege("second")
+	for _, priv := range []*sentryapi.Privilege{first, second} {
+		if err := s.client.GrantPrivilege(roleName, priv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	privs, err := s.client.ListPrivilegesByRole(roleName, object(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(privs) != 1 || !samePrivilege(privs[0], first) {
+		t.Fatalf("expected %s, got %s", describe([]*sentryapi.Privilege{first}),
+			describe(privs))
+	}
+	if privs[0].CreateTime == 0 {
+		t.Errorf("expected privilege create time, got %s", describe(privs))
+	}
+	if s.generic() {
+		_, err := s.client.ListPrivilegesByRole(roleName, nil)
+		expectError(t, err, sentryapi.ErrInvalidInput, "list without service")
+	}
+}
+
+func testHierarchy(t *testing.T, s *suite) {
+	roleName := s.createRole(t, "hierarchy")
+	defer s.removeRole(t, roleName)
+	parent := s.privilege("object")
+	child := s.privilege("object")
+	if s.generic() {
+		child.Authorizables = append(child.Authorizables,
+			sentryapi.Authorizable{Type: "field", Name: "f1"})
+	} else {
+		child.Column = "c1"
+	}
+	for _, priv := range []*sentryapi.Privilege{parent, child} {
+		if err := s.client.GrantPrivilege(roleName, priv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.expectPrivileges(t, roleName, parent, child)
+	if err := s.client.RevokePrivilege(roleName, child); err != nil {
+		t.Fatal(err)
+	}
+	s.expectPrivileges(t, roleName, parent)
+}
+
+// Repeatedly create and remove a role
+func (s *suite) benchmarkCreateRole(b *testing.B) {
+	roleName := s.roleName("role")
+	for i := 0; i < b.N; i++ {
+		if err := s.client.CreateRole(roleName); err != nil {
+			b.Fatalf("failed to create role %s: %v", roleName, err)
+		}
+		if err := s.client.RemoveRole(roleName); err != nil {
+			b.Fatalf("failed to delete role %s: %v", roleName, err)
+		}
+	}
+}
+
+// Repeatedly create roles then repeatedly remove them
+func (s *suite) benchmarkCreateRoles(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		name := s.roleName(fmt.Sprintf("role_%d", i))
+		if err := s.client.CreateRole(name); err != nil {
+			b.Fatalf("can't create %s: %v", name, err)
+		}
+	}
+	for i := 0; i < b.N; i++ {
+		name := s.roleName(fmt.Sprintf("role_%d", i))
+		if err := s.client.RemoveRole(name); err != nil {
+			b.Errorf("can't remove %s: %v", name, err)
+		}
+	}
+}
+
+func (s *suite) benchmarkListPrivilegesByRole(b *testing.B) {
+	roleName := s.createRole(b, "list")
+	defer s.removeRole(b, roleName)
+	template := s.template()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.client.ListPrivilegesByRole(roleName, template); err != nil {
+			b.Fatalf("can't list privileges: %v", err)
+		}
+	}
+}
+
+func (s *suite) benchmarkGrantAndRevokePrivilege(b *testing.B) {
+	roleName := s.createRole(b, "grant")
+	defer s.removeRole(b, roleName)
+	priv := s.privilege("object")
+	priv.GrantOption = true
+	for i := 0; i < b.N; i++ {
+		if err := s.client.GrantPrivilege(roleName, priv); err != nil {
+			b.Fatalf("can't grant privilege: %v", err)
+		}
+		if err := s.client.RevokePrivilege(roleName, priv); err != nil {
+			b.Fatalf("can't revoke privilege: %v", err)
+		}
+	}
+}
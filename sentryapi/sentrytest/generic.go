@@ -0,0 +1,323 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentrytest
+
+import (
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_common_service"
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_generic_policy_service"
+)
+
+// genericHandler implements the generic Sentry policy service
+type genericHandler struct {
+	server *Server
+}
+
+// fromTAuthorizables converts generic authorizables to the object path
+func fromTAuthorizables(authorizables []*sentry_generic_policy_service.TAuthorizable) string {
+	parts := make([]string, 0, 2*len(authorizables))
+	for _, auth := range authorizables {
+		parts = append(parts, normalize(auth.Type), normalize(auth.Name))
+	}
+	return objectPath(parts...)
+}
+
+// fromTGenericPrivilege converts generic Thrift privilege to privilege.
+// Component of the request is used if the privilege doesn't specify one.
+func fromTGenericPrivilege(tPriv *sentry_generic_policy_service.TSentryPrivilege,
+	component string) privilege {
+	if tPriv.Component != "" {
+		component = tPriv.Component
+	}
+	return privilege{
+		component:   normalize(component),
+		service:     normalize(tPriv.ServiceName),
+		object:      fromTAuthorizables(tPriv.Authorizables),
+		action:      normalize(tPriv.Action),
+		grantOption: tPriv.GrantOption == sentry_generic_policy_service.TSentryGrantOption_TRUE,
+	}
+}
+
+// toTGenericPrivilege converts privilege to generic Thrift privilege
+func toTGenericPrivilege(p privilege, created int64) *sentry_generic_policy_service.TSentryPrivilege {
+	tPriv := sentry_generic_policy_service.NewTSentryPrivilege()
+	tPriv.Component = p.component
+	tPriv.ServiceName = p.service
+	tPriv.Authorizables = []*sentry_generic_policy_service.TAuthorizable{}
+	for _, part := range pathParts(p.object) {
+		tPriv.Authorizables = append(tPriv.Authorizables,
+			&sentry_generic_policy_service.TAuthorizable{Type: part[0], Name: part[1]})
+	}
+	tPriv.Action = p.action
+	tPriv.CreateTime = &created
+	if p.grantOption {
+		tPriv.GrantOption = sentry_generic_policy_service.TSentryGrantOption_TRUE
+	}
+	return tPriv
+}
+
+// genericString returns privilege in the form used by authorization
+// providers, e.g. collection=c1->action=query
+func genericString(p privilege) string {
+	if p.object == "" {
+		return "action=" + p.action
+	}
+	return p.object + "->action=" + p.action
+}
+
+// inService returns true if the privilege belongs to the component and the
+// service. Empty service matches all services.
+func inService(p privilege, component string, service string) bool {
+	return p.component == normalize(component) &&
+		(service == "" || p.service == normalize(service))
+}
+
+// CreateSentryRole implements SentryGenericPolicyService
+func (h *genericHandler) CreateSentryRole(request *sentry_generic_policy_service.TCreateSentryRoleRequest) (*sentry_generic_policy_service.TCreateSentryRoleResponse, error) {
+	response := sentry_generic_policy_service.NewTCreateSentryRoleResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			return st.createRole(request.RoleName)
+		})
+	return response, nil
+}
+
+// DropSentryRole implements SentryGenericPolicyService
+func (h *genericHandler) DropSentryRole(request *sentry_generic_policy_service.TDropSentryRoleRequest) (*sentry_generic_policy_service.TDropSentryRoleResponse, error) {
+	response := sentry_generic_policy_service.NewTDropSentryRoleResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			return st.dropRole(request.RoleName)
+		})
+	return response, nil
+}
+
+// AlterSentryRoleGrantPrivilege implements SentryGenericPolicyService
+func (h *genericHandler) AlterSentryRoleGrantPrivilege(request *sentry_generic_policy_service.TAlterSentryRoleGrantPrivilegeRequest) (*sentry_generic_policy_service.TAlterSentryRoleGrantPrivilegeResponse, error) {
+	response := sentry_generic_policy_service.NewTAlterSentryRoleGrantPrivilegeResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			if request.Privilege == nil {
+				return newError(sentry_common_service.TSENTRY_STATUS_INVALID_INPUT,
+					"Privilege is required")
+			}
+			return st.grant(request.RoleName,
+				fromTGenericPrivilege(request.Privilege, request.Component))
+		})
+	return response, nil
+}
+
+// AlterSentryRoleRevokePrivilege implements SentryGenericPolicyService
+func (h *genericHandler) AlterSentryRoleRevokePrivilege(request *sentry_generic_policy_service.TAlterSentryRoleRevokePrivilegeRequest) (*sentry_generic_policy_service.TAlterSentryRoleRevokePrivilegeResponse, error) {
+	response := sentry_generic_policy_service.NewTAlterSentryRoleRevokePrivilegeResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			if request.Privilege == nil {
+				return newError(sentry_common_service.TSENTRY_STATUS_INVALID_INPUT,
+					"Privilege is required")
+			}
+			anyGrantOption := request.Privilege.GrantOption ==
+				sentry_generic_policy_service.TSentryGrantOption_UNSET
+			return st.revoke(request.RoleName,
+				fromTGenericPrivilege(request.Privilege, request.Component),
+				anyGrantOption)
+		})
+	return response, nil
+}
+
+// AlterSentryRoleAddGroups implements SentryGenericPolicyService
+func (h *genericHandler) AlterSentryRoleAddGroups(request *sentry_generic_policy_service.TAlterSentryRoleAddGroupsRequest) (*sentry_generic_policy_service.TAlterSentryRoleAddGroupsResponse, error) {
+	response := sentry_generic_policy_service.NewTAlterSentryRoleAddGroupsResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			return st.alterGroups(request.RoleName, sortedKeys(request.Groups), true)
+		})
+	return response, nil
+}
+
+// AlterSentryRoleDeleteGroups implements SentryGenericPolicyService
+func (h *genericHandler) AlterSentryRoleDeleteGroups(request *sentry_generic_policy_service.TAlterSentryRoleDeleteGroupsRequest) (*sentry_generic_policy_service.TAlterSentryRoleDeleteGroupsResponse, error) {
+	response := sentry_generic_policy_service.NewTAlterSentryRoleDeleteGroupsResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			return st.alterGroups(request.RoleName, sortedKeys(request.Groups), false)
+		})
+	return response, nil
+}
+
+// ListSentryRolesByGroup implements SentryGenericPolicyService. Unlike the
+// legacy service, unknown groups have no roles. Listing all roles requires
+// administrator rights.
+func (h *genericHandler) ListSentryRolesByGroup(request *sentry_generic_policy_service.TListSentryRolesRequest) (*sentry_generic_policy_service.TListSentryRolesResponse, error) {
+	response := sentry_generic_policy_service.NewTListSentryRolesResponse()
+	group := value(request.GroupName)
+	if group == allAction {
+		group = ""
+	}
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, group == "", func(st *store) error {
+			response.Roles = make(map[*sentry_generic_policy_service.TSentryRole]bool)
+			for _, r := range st.sortedRoles() {
+				if group == "" || r.groups[group] {
+					groups := make(map[string]bool, len(r.groups))
+					for g := range r.groups {
+						groups[g] = true
+					}
+					response.Roles[&sentry_generic_policy_service.TSentryRole{
+						RoleName: r.name,
+						Groups:   groups,
+					}] = true
+				}
+			}
+			return nil
+		})
+	return response, nil
+}
+
+// ListSentryPrivilegesByRole implements SentryGenericPolicyService. If
+// authorizables are specified, only privileges on their parents and
+// children are returned.
+func (h *genericHandler) ListSentryPrivilegesByRole(request *sentry_generic_policy_service.TListSentryPrivilegesRequest) (*sentry_generic_policy_service.TListSentryPrivilegesResponse, error) {
+	response := sentry_generic_policy_service.NewTListSentryPrivilegesResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			r, err := st.getRole(request.RoleName)
+			if err != nil {
+				return err
+			}
+			object := privilege{
+				component: normalize(request.Component),
+				service:   normalize(request.ServiceName),
+				object:    fromTAuthorizables(request.Authorizables),
+			}
+			response.Privileges = make(map[*sentry_generic_policy_service.TSentryPrivilege]bool)
+			for p, created := range r.privileges {
+				if p.related(object) {
+					response.Privileges[toTGenericPrivilege(p, created)] = true
+				}
+			}
+			return nil
+		})
+	return response, nil
+}
+
+// ListSentryPrivilegesForProvider implements SentryGenericPolicyService
+func (h *genericHandler) ListSentryPrivilegesForProvider(request *sentry_generic_policy_service.TListSentryPrivilegesForProviderRequest) (*sentry_generic_policy_service.TListSentryPrivilegesForProviderResponse, error) {
+	response := sentry_generic_policy_service.NewTListSentryPrivilegesForProviderResponse()
+	response.Privileges = make(map[string]bool)
+	response.Status = h.server.run(request.ProtocolVersion, "", false,
+		func(st *store) error {
+			all := request.RoleSet == nil || request.RoleSet.All
+			var active map[string]bool
+			if request.RoleSet != nil {
+				active = activeRoles(request.RoleSet.Roles)
+			}
+			object := privilege{
+				service: normalize(request.ServiceName),
+				object:  fromTAuthorizables(request.Authorizables),
+			}
+			for _, r := range st.rolesFor(sortedKeys(request.Groups), nil,
+				all, active) {
+				for p := range r.privileges {
+					if !inService(p, request.Component, request.ServiceName) {
+						continue
+					}
+					object.component = p.component
+					object.service = p.service
+					if p.related(object) {
+						response.Privileges[genericString(p)] = true
+					}
+				}
+			}
+			return nil
+		})
+	return response, nil
+}
+
+// ListSentryPrivilegesByAuthorizable implements SentryGenericPolicyService.
+// Authorizables have the form type=name->type=name. For each of them
+// privileges on the object itself are returned.
+func (h *genericHandler) ListSentryPrivilegesByAuthorizable(request *sentry_generic_policy_service.TListSentryPrivilegesByAuthRequest) (*sentry_generic_policy_service.TListSentryPrivilegesByAuthResponse, error) {
+	response := sentry_generic_policy_service.NewTListSentryPrivilegesByAuthResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			all := request.RoleSet == nil || request.RoleSet.All
+			var active map[string]bool
+			if request.RoleSet != nil {
+				active = activeRoles(request.RoleSet.Roles)
+			}
+			roles := filterRoles(st.sortedRoles(), all, active)
+			if request.Groups != nil {
+				roles = st.rolesFor(sortedKeys(request.Groups), nil, all, active)
+			}
+			response.PrivilegesMapByAuth = make(map[string]*sentry_generic_policy_service.TSentryPrivilegeMap)
+			for auth := range request.AuthorizablesSet {
+				object := privilege{
+					component: normalize(request.Component),
+					service:   normalize(request.ServiceName),
+					object:    normalize(auth),
+				}
+				privMap := sentry_generic_policy_service.NewTSentryPrivilegeMap()
+				privMap.PrivilegeMap = make(map[string]map[*sentry_generic_policy_service.TSentryPrivilege]bool)
+				for _, r := range roles {
+					for p, created := range r.privileges {
+						if !p.sameObject(object) {
+							continue
+						}
+						if privMap.PrivilegeMap[r.name] == nil {
+							privMap.PrivilegeMap[r.name] = make(map[*sentry_generic_policy_service.TSentryPrivilege]bool)
+						}
+						privMap.PrivilegeMap[r.name][toTGenericPrivilege(p, created)] = true
+					}
+				}
+				response.PrivilegesMapByAuth[auth] = privMap
+			}
+			return nil
+		})
+	return response, nil
+}
+
+// DropSentryPrivilege implements SentryGenericPolicyService
+func (h *genericHandler) DropSentryPrivilege(request *sentry_generic_policy_service.TDropPrivilegesRequest) (*sentry_generic_policy_service.TDropPrivilegesResponse, error) {
+	response := sentry_generic_policy_service.NewTDropPrivilegesResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			if request.Privilege == nil {
+				return newError(sentry_common_service.TSENTRY_STATUS_INVALID_INPUT,
+					"Privilege is required")
+			}
+			st.dropPrivileges(fromTGenericPrivilege(request.Privilege,
+				request.Component))
+			return nil
+		})
+	return response, nil
+}
+
+// RenameSentryPrivilege implements SentryGenericPolicyService
+func (h *genericHandler) RenameSentryPrivilege(request *sentry_generic_policy_service.TRenamePrivilegesRequest) (*sentry_generic_policy_service.TRenamePrivilegesResponse, error) {
+	response := sentry_generic_policy_service.NewTRenamePrivilegesResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			from := privilege{
+				component: normalize(request.Component),
+				service:   normalize(request.ServiceName),
+				object:    fromTAuthorizables(request.OldAuthorizables),
+			}
+			to := from
+			to.object = fromTAuthorizables(request.NewAuthorizables_)
+			st.renamePrivileges(from, to)
+			return nil
+		})
+	return response, nil
+}
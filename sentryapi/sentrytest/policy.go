@@ -0,0 +1,566 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentrytest
+
+import (
+	"strings"
+
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_common_service"
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_policy_service"
+)
+
+// configPrefix is the prefix of configuration properties clients may read
+const configPrefix = "sentry."
+
+// policyHandler implements the legacy Sentry policy service
+type policyHandler struct {
+	server *Server
+}
+
+// normalize trims and lower-cases name
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// value returns the string or empty string for nil
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// fromTPrivilege converts legacy Thrift privilege to privilege. Like
+// Sentry, it ignores case of everything but URI.
+func fromTPrivilege(tPriv *sentry_policy_service.TSentryPrivilege) privilege {
+	return privilege{
+		service: normalize(tPriv.ServerName),
+		object: objectPath("db", normalize(tPriv.DbName),
+			"table", normalize(tPriv.TableName),
+			"column", normalize(tPriv.ColumnName),
+			"uri", strings.TrimSpace(tPriv.URI)),
+		action:      normalize(tPriv.Action),
+		grantOption: tPriv.GrantOption == sentry_policy_service.TSentryGrantOption_TRUE,
+	}
+}
+
+// fromTAuthorizable converts legacy Thrift authorizable to privilege
+// without action
+func fromTAuthorizable(auth *sentry_policy_service.TSentryAuthorizable) privilege {
+	return privilege{
+		service: normalize(auth.Server),
+		object: objectPath("db", normalize(value(auth.Db)),
+			"table", normalize(value(auth.Table)),
+			"column", normalize(value(auth.Column)),
+			"uri", strings.TrimSpace(value(auth.URI))),
+	}
+}
+
+// scope returns legacy privilege scope for the object path
+func scope(parts map[string]string) string {
+	switch {
+	case parts["column"] != "":
+		return "COLUMN"
+	case parts["table"] != "":
+		return "TABLE"
+	case parts["db"] != "":
+		return "DATABASE"
+	case parts["uri"] != "":
+		return "URI"
+	}
+	return "SERVER"
+}
+
+// toTPrivilege converts privilege to legacy Thrift privilege
+func toTPrivilege(p privilege, created int64) *sentry_policy_service.TSentryPrivilege {
+	parts := splitPath(p.object)
+	tPriv := sentry_policy_service.NewTSentryPrivilege()
+	tPriv.PrivilegeScope = scope(parts)
+	tPriv.ServerName = p.service
+	tPriv.DbName = parts["db"]
+	tPriv.TableName = parts["table"]
+	tPriv.ColumnName = parts["column"]
+	tPriv.URI = parts["uri"]
+	tPriv.Action = p.action
+	tPriv.CreateTime = &created
+	if p.grantOption {
+		tPriv.GrantOption = sentry_policy_service.TSentryGrantOption_TRUE
+	}
+	return tPriv
+}
+
+// authorizableString returns privilege in the form used by authorization
+// providers, e.g. server=s1->db=db1->action=select
+func authorizableString(p privilege) string {
+	parts := splitPath(p.object)
+	path := []string{"server=" + p.service}
+	if parts["uri"] != "" {
+		path = append(path, "uri="+parts["uri"])
+	} else {
+		for _, part := range pathParts(p.object) {
+			path = append(path, part[0]+"="+part[1])
+		}
+	}
+	if !isAll(p.action) {
+		path = append(path, "action="+p.action)
+	}
+	return strings.Join(path, "->")
+}
+
+// privileges returns privileges from request fields
+func privileges(tPriv *sentry_policy_service.TSentryPrivilege,
+	tPrivs map[*sentry_policy_service.TSentryPrivilege]bool) []*sentry_policy_service.TSentryPrivilege {
+	var result []*sentry_policy_service.TSentryPrivilege
+	if tPriv != nil {
+		result = append(result, tPriv)
+	}
+	for p := range tPrivs {
+		result = append(result, p)
+	}
+	return result
+}
+
+// groupNames returns names of Thrift groups
+func groupNames(groups map[*sentry_policy_service.TSentryGroup]bool) []string {
+	names := make([]string, 0, len(groups))
+	for group := range groups {
+		names = append(names, group.GroupName)
+	}
+	return names
+}
+
+// toTRole converts role to legacy Thrift role
+func toTRole(r *role) *sentry_policy_service.TSentryRole {
+	groups := make(map[*sentry_policy_service.TSentryGroup]bool, len(r.groups))
+	for _, group := range sortedKeys(r.groups) {
+		groups[&sentry_policy_service.TSentryGroup{GroupName: group}] = true
+	}
+	return &sentry_policy_service.TSentryRole{RoleName: r.name, Groups: groups}
+}
+
+// activeRoles returns normalized active role names
+func activeRoles(roleSet map[string]bool) map[string]bool {
+	active := make(map[string]bool, len(roleSet))
+	for name := range roleSet {
+		active[roleName(name)] = true
+	}
+	return active
+}
+
+// CreateSentryRole implements SentryPolicyService
+func (h *policyHandler) CreateSentryRole(request *sentry_policy_service.TCreateSentryRoleRequest) (*sentry_policy_service.TCreateSentryRoleResponse, error) {
+	response := sentry_policy_service.NewTCreateSentryRoleResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			return st.createRole(request.RoleName)
+		})
+	return response, nil
+}
+
+// DropSentryRole implements SentryPolicyService
+func (h *policyHandler) DropSentryRole(request *sentry_policy_service.TDropSentryRoleRequest) (*sentry_policy_service.TDropSentryRoleResponse, error) {
+	response := sentry_policy_service.NewTDropSentryRoleResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			return st.dropRole(request.RoleName)
+		})
+	return response, nil
+}
+
+// AlterSentryRoleGrantPrivilege implements SentryPolicyService
+func (h *policyHandler) AlterSentryRoleGrantPrivilege(request *sentry_policy_service.TAlterSentryRoleGrantPrivilegeRequest) (*sentry_policy_service.TAlterSentryRoleGrantPrivilegeResponse, error) {
+	response := sentry_policy_service.NewTAlterSentryRoleGrantPrivilegeResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			for _, tPriv := range privileges(request.Privilege, request.Privileges) {
+				if err := st.grant(request.RoleName, fromTPrivilege(tPriv)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	response.Privilege = request.Privilege
+	response.Privileges = request.Privileges
+	return response, nil
+}
+
+// AlterSentryRoleRevokePrivilege implements SentryPolicyService
+func (h *policyHandler) AlterSentryRoleRevokePrivilege(request *sentry_policy_service.TAlterSentryRoleRevokePrivilegeRequest) (*sentry_policy_service.TAlterSentryRoleRevokePrivilegeResponse, error) {
+	response := sentry_policy_service.NewTAlterSentryRoleRevokePrivilegeResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			for _, tPriv := range privileges(request.Privilege, request.Privileges) {
+				anyGrantOption := tPriv.GrantOption ==
+					sentry_policy_service.TSentryGrantOption_UNSET
+				if err := st.revoke(request.RoleName, fromTPrivilege(tPriv),
+					anyGrantOption); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	return response, nil
+}
+
+// AlterSentryRoleAddGroups implements SentryPolicyService
+func (h *policyHandler) AlterSentryRoleAddGroups(request *sentry_policy_service.TAlterSentryRoleAddGroupsRequest) (*sentry_policy_service.TAlterSentryRoleAddGroupsResponse, error) {
+	response := sentry_policy_service.NewTAlterSentryRoleAddGroupsResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			return st.alterGroups(request.RoleName, groupNames(request.Groups), true)
+		})
+	return response, nil
+}
+
+// AlterSentryRoleDeleteGroups implements SentryPolicyService
+func (h *policyHandler) AlterSentryRoleDeleteGroups(request *sentry_policy_service.TAlterSentryRoleDeleteGroupsRequest) (*sentry_policy_service.TAlterSentryRoleDeleteGroupsResponse, error) {
+	response := sentry_policy_service.NewTAlterSentryRoleDeleteGroupsResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			return st.alterGroups(request.RoleName, groupNames(request.Groups), false)
+		})
+	return response, nil
+}
+
+// AlterSentryRoleAddUsers implements SentryPolicyService
+func (h *policyHandler) AlterSentryRoleAddUsers(request *sentry_policy_service.TAlterSentryRoleAddUsersRequest) (*sentry_policy_service.TAlterSentryRoleAddUsersResponse, error) {
+	response := sentry_policy_service.NewTAlterSentryRoleAddUsersResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			return st.alterUsers(request.RoleName, sortedKeys(request.Users), true)
+		})
+	return response, nil
+}
+
+// AlterSentryRoleDeleteUsers implements SentryPolicyService
+func (h *policyHandler) AlterSentryRoleDeleteUsers(request *sentry_policy_service.TAlterSentryRoleDeleteUsersRequest) (*sentry_policy_service.TAlterSentryRoleDeleteUsersResponse, error) {
+	response := sentry_policy_service.NewTAlterSentryRoleDeleteUsersResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			return st.alterUsers(request.RoleName, sortedKeys(request.Users), false)
+		})
+	return response, nil
+}
+
+// ListSentryRolesByGroup implements SentryPolicyService. Listing all roles
+// requires administrator rights.
+func (h *policyHandler) ListSentryRolesByGroup(request *sentry_policy_service.TListSentryRolesRequest) (*sentry_policy_service.TListSentryRolesResponse, error) {
+	response := sentry_policy_service.NewTListSentryRolesResponse()
+	group := value(request.GroupName)
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, group == "", func(st *store) error {
+			if group != "" && !st.groups[group] {
+				return newError(sentry_common_service.TSENTRY_STATUS_NO_SUCH_OBJECT,
+					"Group %s doesn't exist", group)
+			}
+			response.Roles = make(map[*sentry_policy_service.TSentryRole]bool)
+			for _, r := range st.sortedRoles() {
+				if group == "" || r.groups[group] {
+					response.Roles[toTRole(r)] = true
+				}
+			}
+			return nil
+		})
+	return response, nil
+}
+
+// ListSentryRolesByUser implements SentryPolicyService. Users may list their
+// own roles, other users require administrator rights.
+func (h *policyHandler) ListSentryRolesByUser(request *sentry_policy_service.TListSentryRolesForUserRequest) (*sentry_policy_service.TListSentryRolesResponse, error) {
+	response := sentry_policy_service.NewTListSentryRolesResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName,
+		request.UserName != request.RequestorUserName, func(st *store) error {
+			if !st.users[request.UserName] {
+				return newError(sentry_common_service.TSENTRY_STATUS_NO_SUCH_OBJECT,
+					"User %s doesn't exist", request.UserName)
+			}
+			response.Roles = make(map[*sentry_policy_service.TSentryRole]bool)
+			for _, r := range st.sortedRoles() {
+				if r.users[request.UserName] {
+					response.Roles[toTRole(r)] = true
+				}
+			}
+			return nil
+		})
+	return response, nil
+}
+
+// ListSentryPrivilegesByRole implements SentryPolicyService. If the
+// authorizable is specified, only privileges on its parents and children
+// are returned.
+func (h *policyHandler) ListSentryPrivilegesByRole(request *sentry_policy_service.TListSentryPrivilegesRequest) (*sentry_policy_service.TListSentryPrivilegesResponse, error) {
+	response := sentry_policy_service.NewTListSentryPrivilegesResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			r, err := st.getRole(request.RoleName)
+			if err != nil {
+				return err
+			}
+			response.Privileges = make(map[*sentry_policy_service.TSentryPrivilege]bool)
+			for p, created := range r.privileges {
+				if p.component != "" {
+					continue
+				}
+				if request.AuthorizableHierarchy != nil &&
+					!p.related(fromTAuthorizable(request.AuthorizableHierarchy)) {
+					continue
+				}
+				response.Privileges[toTPrivilege(p, created)] = true
+			}
+			return nil
+		})
+	return response, nil
+}
+
+// ListSentryPrivilegesForProvider implements SentryPolicyService
+func (h *policyHandler) ListSentryPrivilegesForProvider(request *sentry_policy_service.TListSentryPrivilegesForProviderRequest) (*sentry_policy_service.TListSentryPrivilegesForProviderResponse, error) {
+	response := sentry_policy_service.NewTListSentryPrivilegesForProviderResponse()
+	response.Privileges = make(map[string]bool)
+	response.Status = h.server.run(request.ProtocolVersion, "", false,
+		func(st *store) error {
+			all := request.RoleSet == nil || request.RoleSet.All
+			var active map[string]bool
+			if request.RoleSet != nil {
+				active = activeRoles(request.RoleSet.Roles)
+			}
+			for _, r := range st.rolesFor(sortedKeys(request.Groups),
+				sortedKeys(request.Users), all, active) {
+				for p := range r.privileges {
+					if p.component != "" {
+						continue
+					}
+					if request.AuthorizableHierarchy != nil &&
+						!p.related(fromTAuthorizable(request.AuthorizableHierarchy)) {
+						continue
+					}
+					response.Privileges[authorizableString(p)] = true
+				}
+			}
+			return nil
+		})
+	return response, nil
+}
+
+// DropSentryPrivilege implements SentryPolicyService
+func (h *policyHandler) DropSentryPrivilege(request *sentry_policy_service.TDropPrivilegesRequest) (*sentry_policy_service.TDropPrivilegesResponse, error) {
+	response := sentry_policy_service.NewTDropPrivilegesResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			if request.Authorizable == nil {
+				return newError(sentry_common_service.TSENTRY_STATUS_INVALID_INPUT,
+					"Authorizable is required")
+			}
+			st.dropPrivileges(fromTAuthorizable(request.Authorizable))
+			return nil
+		})
+	return response, nil
+}
+
+// RenameSentryPrivilege implements SentryPolicyService
+func (h *policyHandler) RenameSentryPrivilege(request *sentry_policy_service.TRenamePrivilegesRequest) (*sentry_policy_service.TRenamePrivilegesResponse, error) {
+	response := sentry_policy_service.NewTRenamePrivilegesResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			if request.OldAuthorizable == nil || request.NewAuthorizable_ == nil {
+				return newError(sentry_common_service.TSENTRY_STATUS_INVALID_INPUT,
+					"Old and new authorizables are required")
+			}
+			st.renamePrivileges(fromTAuthorizable(request.OldAuthorizable),
+				fromTAuthorizable(request.NewAuthorizable_))
+			return nil
+		})
+	return response, nil
+}
+
+// ListSentryPrivilegesByAuthorizable implements SentryPolicyService. For
+// each authorizable it returns privileges on the object itself and, for
+// tables, on their columns.
+func (h *policyHandler) ListSentryPrivilegesByAuthorizable(request *sentry_policy_service.TListSentryPrivilegesByAuthRequest) (*sentry_policy_service.TListSentryPrivilegesByAuthResponse, error) {
+	response := sentry_policy_service.NewTListSentryPrivilegesByAuthResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			all := request.RoleSet == nil || request.RoleSet.All
+			var active map[string]bool
+			if request.RoleSet != nil {
+				active = activeRoles(request.RoleSet.Roles)
+			}
+			roles := filterRoles(st.sortedRoles(), all, active)
+			if request.Groups != nil {
+				roles = st.rolesFor(sortedKeys(request.Groups), nil, all, active)
+			}
+			response.PrivilegesMapByAuth = make(map[*sentry_policy_service.TSentryAuthorizable]*sentry_policy_service.TSentryPrivilegeMap)
+			for auth := range request.AuthorizableSet {
+				object := fromTAuthorizable(auth)
+				isTable := splitPath(object.object)["table"] != ""
+				privMap := sentry_policy_service.NewTSentryPrivilegeMap()
+				privMap.PrivilegeMap = make(map[string]map[*sentry_policy_service.TSentryPrivilege]bool)
+				for _, r := range roles {
+					for p, created := range r.privileges {
+						if p.component != "" ||
+							!(p.sameObject(object) || (isTable && p.within(object))) {
+							continue
+						}
+						if privMap.PrivilegeMap[r.name] == nil {
+							privMap.PrivilegeMap[r.name] = make(map[*sentry_policy_service.TSentryPrivilege]bool)
+						}
+						privMap.PrivilegeMap[r.name][toTPrivilege(p, created)] = true
+					}
+				}
+				response.PrivilegesMapByAuth[auth] = privMap
+			}
+			return nil
+		})
+	return response, nil
+}
+
+// GetSentryConfigValue implements SentryPolicyService. Only properties
+// starting with "sentry." may be read.
+func (h *policyHandler) GetSentryConfigValue(request *sentry_policy_service.TSentryConfigValueRequest) (*sentry_policy_service.TSentryConfigValueResponse, error) {
+	response := sentry_policy_service.NewTSentryConfigValueResponse()
+	response.Status = h.server.run(request.ProtocolVersion, "", false,
+		func(st *store) error {
+			if !strings.HasPrefix(request.PropertyName, configPrefix) {
+				return newError(sentry_common_service.TSENTRY_STATUS_ACCESS_DENIED,
+					"Attempted access of the configuration property %s was denied",
+					request.PropertyName)
+			}
+			result, ok := h.server.config[request.PropertyName]
+			if !ok {
+				result = value(request.DefaultValue)
+			}
+			response.Value = &result
+			return nil
+		})
+	return response, nil
+}
+
+// ExportSentryMappingData implements SentryPolicyService. The object path
+// has the form db=name->table=name and restricts export to privileges on
+// the object and roles having them.
+func (h *policyHandler) ExportSentryMappingData(request *sentry_policy_service.TSentryExportMappingDataRequest) (*sentry_policy_service.TSentryExportMappingDataResponse, error) {
+	response := sentry_policy_service.NewTSentryExportMappingDataResponse()
+	data := sentry_policy_service.NewTSentryMappingData()
+	data.GroupRolesMap = make(map[string]map[string]bool)
+	data.UserRolesMap = make(map[string]map[string]bool)
+	data.RolePrivilegesMap = make(map[string]map[*sentry_policy_service.TSentryPrivilege]bool)
+	response.MappingData = data
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			objectPathFilter := ""
+			if request.ObjectPath != nil {
+				parts := splitPath(normalize(*request.ObjectPath))
+				objectPathFilter = objectPath("db", parts["db"],
+					"table", parts["table"])
+			}
+			for _, r := range st.sortedRoles() {
+				privs := make(map[*sentry_policy_service.TSentryPrivilege]bool)
+				for p, created := range r.privileges {
+					if p.component != "" {
+						continue
+					}
+					if objectPathFilter != "" && !p.within(privilege{
+						service: p.service, object: objectPathFilter}) {
+						continue
+					}
+					privs[toTPrivilege(p, created)] = true
+				}
+				if objectPathFilter != "" && len(privs) == 0 {
+					continue
+				}
+				if len(privs) != 0 {
+					data.RolePrivilegesMap[r.name] = privs
+				}
+				for group := range r.groups {
+					if data.GroupRolesMap[group] == nil {
+						data.GroupRolesMap[group] = make(map[string]bool)
+					}
+					data.GroupRolesMap[group][r.name] = true
+				}
+				for user := range r.users {
+					if data.UserRolesMap[user] == nil {
+						data.UserRolesMap[user] = make(map[string]bool)
+					}
+					data.UserRolesMap[user][r.name] = true
+				}
+			}
+			return nil
+		})
+	return response, nil
+}
+
+// ImportSentryMappingData implements SentryPolicyService. Missing roles are
+// created. With overwriteRole privileges of existing roles are replaced by
+// the imported ones, otherwise they are added.
+func (h *policyHandler) ImportSentryMappingData(request *sentry_policy_service.TSentryImportMappingDataRequest) (*sentry_policy_service.TSentryImportMappingDataResponse, error) {
+	response := sentry_policy_service.NewTSentryImportMappingDataResponse()
+	response.Status = h.server.run(request.ProtocolVersion,
+		request.RequestorUserName, true, func(st *store) error {
+			data := request.MappingData
+			if data == nil {
+				return nil
+			}
+			roles := make(map[string]bool)
+			for _, set := range data.GroupRolesMap {
+				for name := range set {
+					roles[roleName(name)] = true
+				}
+			}
+			for _, set := range data.UserRolesMap {
+				for name := range set {
+					roles[roleName(name)] = true
+				}
+			}
+			for name := range data.RolePrivilegesMap {
+				roles[roleName(name)] = true
+			}
+			for name := range roles {
+				if r, err := st.getRole(name); err == nil {
+					if request.OverwriteRole {
+						for p := range r.privileges {
+							if p.component == "" {
+								delete(r.privileges, p)
+							}
+						}
+					}
+				} else if err := st.createRole(name); err != nil {
+					return err
+				}
+			}
+			for group, set := range data.GroupRolesMap {
+				for name := range set {
+					if err := st.alterGroups(name, []string{group}, true); err != nil {
+						return err
+					}
+				}
+			}
+			for user, set := range data.UserRolesMap {
+				for name := range set {
+					if err := st.alterUsers(name, []string{user}, true); err != nil {
+						return err
+					}
+				}
+			}
+			for name, tPrivs := range data.RolePrivilegesMap {
+				for tPriv := range tPrivs {
+					if err := st.grant(name, fromTPrivilege(tPriv)); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	return response, nil
+}
@@ -0,0 +1,321 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sentrytest provides an in-memory Sentry server for tests.
+//
+// The server provides both the legacy SentryPolicyService and the
+// SentryGenericPolicyService on an ephemeral local port. Both services share
+// roles and group mappings, as the real Sentry does, and report failures with
+// the same status codes.
+//
+//	server, err := sentrytest.NewServer()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer server.Close()
+//	client, err := sentryapi.GetClient(sentryapi.PolicyProtocol,
+//		server.Host, server.Port, "", "admin")
+package sentrytest
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_common_service"
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_generic_policy_service"
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_policy_service"
+)
+
+// Multiplexed service names
+const (
+	PolicyService        = "SentryPolicyService"
+	GenericPolicyService = "SentryGenericPolicyService"
+)
+
+// Option configures the server
+type Option func(*Server)
+
+// WithProtocolVersion sets the only protocol version accepted by the server.
+// Requests with other versions fail with THRIFT_VERSION_MISMATCH status. The
+// default is TSENTRY_SERVICE_V2.
+func WithProtocolVersion(version int32) Option {
+	return func(s *Server) {
+		s.version = version
+	}
+}
+
+// WithAdminUsers restricts modifications and listing of all roles to the
+// specified users. Other users get ACCESS_DENIED status. By default all
+// users are administrators.
+func WithAdminUsers(users ...string) Option {
+	return func(s *Server) {
+		for _, user := range users {
+			s.admins[user] = true
+		}
+	}
+}
+
+// WithConfig sets server configuration properties returned by the
+// configuration value request
+func WithConfig(config map[string]string) Option {
+	return func(s *Server) {
+		for k, v := range config {
+			s.config[k] = v
+		}
+	}
+}
+
+// WithoutService disables the multiplexed service, e.g. GenericPolicyService.
+// Requests to it fail as for a server which doesn't provide the service.
+func WithoutService(service string) Option {
+	return func(s *Server) {
+		s.disabled[service] = true
+	}
+}
+
+// Server is an in-memory Sentry server.
+// Attributes:
+//   Host - listening host
+//   Port - listening port
+type Server struct {
+	Host      string
+	Port      int
+	version   int32
+	admins    map[string]bool
+	config    map[string]string
+	disabled  map[string]bool
+	store     *store
+	transport *serverTransport
+	server    *thrift.TSimpleServer
+	done      chan struct{}
+}
+
+// NewServer starts a new server on an ephemeral port of the loopback
+// interface
+func NewServer(opts ...Option) (*Server, error) {
+	s := &Server{
+		version:  sentry_common_service.TSENTRY_SERVICE_V2,
+		admins:   make(map[string]bool),
+		config:   make(map[string]string),
+		disabled: make(map[string]bool),
+		store:    newStore(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	s.Host = addr.IP.String()
+	s.Port = addr.Port
+	s.transport = &serverTransport{
+		listener: listener,
+		clients:  make(map[*clientTransport]bool),
+	}
+
+	processor := thrift.NewTMultiplexedProcessor()
+	if !s.disabled[PolicyService] {
+		processor.RegisterProcessor(PolicyService,
+			sentry_policy_service.NewSentryPolicyServiceProcessor(
+				&policyHandler{server: s}))
+	}
+	if !s.disabled[GenericPolicyService] {
+		processor.RegisterProcessor(GenericPolicyService,
+			sentry_generic_policy_service.NewSentryGenericPolicyServiceProcessor(
+				&genericHandler{server: s}))
+	}
+	s.server = thrift.NewTSimpleServer4(processor, s.transport,
+		thrift.NewTBufferedTransportFactory(1024),
+		thrift.NewTBinaryProtocolFactoryDefault())
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		s.server.Serve()
+	}()
+	return s, nil
+}
+
+// Addr returns server address as host:port
+func (s *Server) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+// Close stops the server and closes all client connections. It returns
+// after all connection handlers finish.
+func (s *Server) Close() {
+	s.server.Stop()
+	<-s.done
+	s.transport.Close()
+}
+
+// Reset removes all roles, groups, users and privileges
+func (s *Server) Reset() {
+	s.store.reset()
+}
+
+// checkVersion verifies that the request protocol version is supported
+func (s *Server) checkVersion(version int32) error {
+	if version != s.version {
+		return newError(sentry_common_service.TSENTRY_STATUS_THRIFT_VERSION_MISMATCH,
+			"Sentry thrift API protocol version mismatch: Client thrift version is: %d , server thrift version is %d",
+			version, s.version)
+	}
+	return nil
+}
+
+// checkAdmin verifies that the requestor is allowed to administer the server
+func (s *Server) checkAdmin(user string) error {
+	if len(s.admins) != 0 && !s.admins[user] {
+		return newError(sentry_common_service.TSENTRY_STATUS_ACCESS_DENIED,
+			"Access denied to %s", user)
+	}
+	return nil
+}
+
+// run verifies the request version and, if admin is true, that the
+// requestor is an administrator. Then it calls fn with the locked store.
+// The result is returned as the response status.
+func (s *Server) run(version int32, user string, admin bool,
+	fn func(st *store) error) *sentry_common_service.TSentryResponseStatus {
+	if err := s.checkVersion(version); err != nil {
+		return status(err)
+	}
+	if admin {
+		if err := s.checkAdmin(user); err != nil {
+			return status(err)
+		}
+	}
+	s.store.Lock()
+	defer s.store.Unlock()
+	return status(fn(s.store))
+}
+
+// serverTransport is a server socket which shuts down accepted connections
+// when it is closed. Connections are shut down for reading, so handlers see
+// EOF and close their sockets themselves, and Close waits for them.
+type serverTransport struct {
+	listener net.Listener
+	lock     sync.Mutex
+	closed   bool
+	clients  map[*clientTransport]bool
+	handlers sync.WaitGroup
+}
+
+// Listen implements thrift.TServerTransport.Listen(). The socket is already
+// listening when the server is created.
+func (t *serverTransport) Listen() error {
+	return nil
+}
+
+// Accept implements thrift.TServerTransport.Accept()
+func (t *serverTransport) Accept() (thrift.TTransport, error) {
+	conn, err := t.listener.Accept()
+	if err != nil {
+		return nil, thrift.NewTTransportExceptionFromError(err)
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		conn.Close()
+		return nil, thrift.NewTTransportException(thrift.NOT_OPEN,
+			"server is closed")
+	}
+	client := &clientTransport{
+		TTransport: thrift.NewTSocketFromConnTimeout(conn, 0),
+		conn:       conn,
+		server:     t,
+	}
+	t.clients[client] = true
+	t.handlers.Add(1)
+	return client, nil
+}
+
+// Interrupt implements thrift.TServerTransport.Interrupt(). It stops
+// accepting new connections.
+func (t *serverTransport) Interrupt() error {
+	return t.listener.Close()
+}
+
+// Close implements thrift.TServerTransport.Close(). It stops accepting new
+// connections, shuts down accepted ones and waits until their handlers
+// finish.
+func (t *serverTransport) Close() error {
+	t.listener.Close()
+	t.lock.Lock()
+	t.closed = true
+	for client := range t.clients {
+		client.shutdown()
+	}
+	t.lock.Unlock()
+	t.handlers.Wait()
+	return nil
+}
+
+// remove forgets the connection closed by its handler
+func (t *serverTransport) remove(client *clientTransport) {
+	t.lock.Lock()
+	delete(t.clients, client)
+	t.lock.Unlock()
+	t.handlers.Done()
+}
+
+// clientTransport is an accepted connection which reports EOF to the server
+// once it is shut down, so that shutting down the server is not logged as an
+// error
+type clientTransport struct {
+	thrift.TTransport
+	conn     net.Conn
+	server   *serverTransport
+	shutDown int32
+	once     sync.Once
+}
+
+// Read implements io.Reader
+func (t *clientTransport) Read(buf []byte) (int, error) {
+	n, err := t.TTransport.Read(buf)
+	if err != nil && atomic.LoadInt32(&t.shutDown) != 0 {
+		return n, io.EOF
+	}
+	return n, err
+}
+
+// Close implements io.Closer. It is called by the connection handler, possibly
+// several times for the input and output transports.
+func (t *clientTransport) Close() error {
+	var err error
+	t.once.Do(func() {
+		err = t.TTransport.Close()
+		t.server.remove(t)
+	})
+	return err
+}
+
+// shutdown unblocks the handler reading from the connection. The socket
+// itself is closed by the handler.
+func (t *clientTransport) shutdown() {
+	atomic.StoreInt32(&t.shutDown, 1)
+	if conn, ok := t.conn.(*net.TCPConn); ok {
+		conn.CloseRead()
+		return
+	}
+	t.conn.SetReadDeadline(time.Now())
+}
@@ -0,0 +1,265 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentrytest_test
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/akolb1/sentrytool/sentryapi"
+	"github.com/akolb1/sentrytool/sentryapi/sentrytest"
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_common_service"
+)
+
+func newClient(t *testing.T, server *sentrytest.Server, protocol sentryapi.ProtocolType,
+	component string, user string) sentryapi.ClientAPI {
+	client, err := sentryapi.GetClient(protocol, server.Host, server.Port,
+		component, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func actions(privs []*sentryapi.Privilege) []string {
+	result := make([]string, 0, len(privs))
+	for _, priv := range privs {
+		result = append(result, priv.Action)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestServer_Roles(t *testing.T) {
+	server, err := sentrytest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client := newClient(t, server, sentryapi.PolicyProtocol, "", "admin")
+	defer client.Close()
+
+	if err := client.CreateRole("Admins"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateRole("admins"); !errors.Is(err, sentryapi.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+	if err := client.AddGroupsToRole("ADMINS", []string{"g1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddGroupsToRole("missing", []string{"g1"}); !errors.Is(err,
+		sentryapi.ErrNoSuchObject) {
+		t.Errorf("expected ErrNoSuchObject, got %v", err)
+	}
+	names, roles, err := client.ListRoleByGroup("g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"admins"}) ||
+		!reflect.DeepEqual(roles[0].Groups, []string{"g1"}) {
+		t.Errorf("unexpected roles %v", names)
+	}
+	if _, _, err := client.ListRoleByGroup("g2"); !errors.Is(err,
+		sentryapi.ErrNoSuchObject) {
+		t.Errorf("expected ErrNoSuchObject for unknown group, got %v", err)
+	}
+
+	// Roles are shared with the generic service
+	generic := newClient(t, server, sentryapi.GenericPolicyProtocol, "solr", "admin")
+	defer generic.Close()
+	if names, _, err = generic.ListRoleByGroup(""); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"admins"}) {
+		t.Errorf("unexpected generic roles %v", names)
+	}
+	if err := generic.RemoveRole("admins"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.RemoveRole("admins"); !errors.Is(err, sentryapi.ErrNoSuchObject) {
+		t.Errorf("expected ErrNoSuchObject, got %v", err)
+	}
+}
+
+func TestServer_Privileges(t *testing.T) {
+	server, err := sentrytest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client := newClient(t, server, sentryapi.PolicyProtocol, "", "admin")
+	defer client.Close()
+
+	if err := client.CreateRole("r1"); err != nil {
+		t.Fatal(err)
+	}
+	table := &sentryapi.Privilege{Server: "server1", Database: "db1",
+		Table: "T1", Action: "all"}
+	if err := client.GrantPrivilege("r1", table); err != nil {
+		t.Fatal(err)
+	}
+	privs, err := client.ListPrivilegesByRole("r1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(privs) != 1 || privs[0].Table != "t1" ||
+		privs[0].Scope != sentryapi.ScopeTable {
+		t.Fatalf("unexpected privileges %v", privs)
+	}
+
+	// Revoking a single action from ALL leaves the other actions
+	revoked := *table
+	revoked.Action = "select"
+	if err := client.RevokePrivilege("r1", &revoked); err != nil {
+		t.Fatal(err)
+	}
+	if privs, err = client.ListPrivilegesByRole("r1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(privs); !reflect.DeepEqual(got, []string{"insert"}) {
+		t.Errorf("expected insert after partial revoke, got %v", got)
+	}
+
+	// Revoking database privilege revokes table privileges as well
+	db := &sentryapi.Privilege{Server: "server1", Database: "db1", Action: "all"}
+	if err := client.RevokePrivilege("r1", db); err != nil {
+		t.Fatal(err)
+	}
+	if privs, err = client.ListPrivilegesByRole("r1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(privs) != 0 {
+		t.Errorf("expected no privileges, got %v", privs)
+	}
+	if _, err := client.ListPrivilegesByRole("missing", nil); !errors.Is(err,
+		sentryapi.ErrNoSuchObject) {
+		t.Errorf("expected ErrNoSuchObject, got %v", err)
+	}
+}
+
+func TestServer_ProtocolVersion(t *testing.T) {
+	server, err := sentrytest.NewServer(
+		sentrytest.WithProtocolVersion(sentry_common_service.TSENTRY_SERVICE_V1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client, err := sentryapi.GetClient(sentryapi.PolicyProtocol, server.Host,
+		server.Port, "", "admin",
+		sentryapi.WithProtocolVersion(sentryapi.ProtocolVersion2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.CreateRole("r1"); !errors.Is(err,
+		sentryapi.ErrThriftVersionMismatch) {
+		t.Errorf("expected ErrThriftVersionMismatch, got %v", err)
+	}
+
+	// Negotiation falls back to the older version
+	negotiated := newClient(t, server, sentryapi.PolicyProtocol, "", "admin")
+	defer negotiated.Close()
+	if err := negotiated.CreateRole("r1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServer_AccessDenied(t *testing.T) {
+	server, err := sentrytest.NewServer(sentrytest.WithAdminUsers("admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client := newClient(t, server, sentryapi.PolicyProtocol, "", "guest")
+	defer client.Close()
+	if err := client.CreateRole("r1"); !errors.Is(err, sentryapi.ErrAccessDenied) {
+		t.Errorf("expected ErrAccessDenied, got %v", err)
+	}
+}
+
+func TestServer_ExportImport(t *testing.T) {
+	server, err := sentrytest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client := newClient(t, server, sentryapi.PolicyProtocol, "", "admin")
+	defer client.Close()
+	policyIO, ok := client.(sentryapi.PolicyIO)
+	if !ok {
+		t.Fatal("legacy client doesn't implement PolicyIO")
+	}
+
+	policy := sentryapi.NewPolicy()
+	policy.Groups["g1"] = []string{"r1"}
+	policy.Users["u1"] = []string{"r1"}
+	policy.Privileges["r1"] = []*sentryapi.Privilege{
+		{Server: "server1", Database: "db1", Action: "select"},
+	}
+	if err := policyIO.ImportPolicy(policy, false); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := policyIO.ExportPolicy("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exported.Groups, policy.Groups) ||
+		!reflect.DeepEqual(exported.Users, policy.Users) ||
+		len(exported.Privileges["r1"]) != 1 {
+		t.Errorf("unexpected exported policy %v", exported)
+	}
+	if exported, err = policyIO.ExportPolicy("db=db2"); err != nil {
+		t.Fatal(err)
+	}
+	if len(exported.Privileges) != 0 {
+		t.Errorf("unexpected privileges for db2: %v", exported.Privileges)
+	}
+}
+
+func TestServer_Config(t *testing.T) {
+	server, err := sentrytest.NewServer(sentrytest.WithConfig(
+		map[string]string{"sentry.service.admin.group": "admins"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client := newClient(t, server, sentryapi.PolicyProtocol, "", "admin")
+	defer client.Close()
+
+	value, err := client.GetConfigValue("sentry.service.admin.group", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "admins" {
+		t.Errorf("expected admins, got %q", value)
+	}
+}
+
+func TestServer_WithoutService(t *testing.T) {
+	server, err := sentrytest.NewServer(
+		sentrytest.WithoutService(sentrytest.GenericPolicyService))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	_, err = sentryapi.GetClient(sentryapi.GenericPolicyProtocol, server.Host,
+		server.Port, "solr", "admin")
+	if !sentryapi.IsTransportError(err) {
+		t.Errorf("expected transport error, got %v", err)
+	}
+}
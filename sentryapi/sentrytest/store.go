@@ -0,0 +1,423 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentrytest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_common_service"
+)
+
+// allAction is the action matching all actions
+const allAction = "*"
+
+// hiveActions are actions implied by the legacy ALL action
+var hiveActions = []string{"select", "insert"}
+
+// componentActions are actions implied by the generic ALL action
+var componentActions = map[string][]string{
+	"kafka": {"read", "write", "create", "delete", "alter", "describe",
+		"clusteraction"},
+	"solr":  {"query", "update"},
+	"sqoop": {"read", "write"},
+}
+
+// sentryError is a failure reported to the client in the response status
+type sentryError struct {
+	code    int32
+	message string
+}
+
+func (e *sentryError) Error() string {
+	return e.message
+}
+
+func newError(code int32, format string, args ...interface{}) error {
+	return &sentryError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+// status converts error to the Thrift response status
+func status(err error) *sentry_common_service.TSentryResponseStatus {
+	result := sentry_common_service.NewTSentryResponseStatus()
+	if err == nil {
+		result.Value = sentry_common_service.TSENTRY_STATUS_OK
+		return result
+	}
+	result.Value = sentry_common_service.TSENTRY_STATUS_RUNTIME_ERROR
+	if e, ok := err.(*sentryError); ok {
+		result.Value = e.code
+	}
+	result.Message = err.Error()
+	return result
+}
+
+// privilege is a normalized privilege of either service. Objects are
+// represented as type=name->type=name paths below the server or service,
+// e.g. db=db1->table=t1.
+// Attributes:
+//   component - generic component, empty for the legacy service
+//   service - server name for the legacy service, service name otherwise
+//   object - path of the object below the server or service
+//   action - lower case action
+//   grantOption - true if the privilege may be granted further
+type privilege struct {
+	component   string
+	service     string
+	object      string
+	action      string
+	grantOption bool
+}
+
+// sameObject returns true if both privileges are on the same object
+func (p privilege) sameObject(q privilege) bool {
+	return p.component == q.component && p.service == q.service &&
+		p.object == q.object
+}
+
+// within returns true if the privilege is on the parent object or one of its
+// children
+func (p privilege) within(parent privilege) bool {
+	if p.component != parent.component || p.service != parent.service {
+		return false
+	}
+	return parent.object == "" || p.object == parent.object ||
+		strings.HasPrefix(p.object, parent.object+"->")
+}
+
+// related returns true if either privilege is within the other one
+func (p privilege) related(q privilege) bool {
+	return p.within(q) || q.within(p)
+}
+
+// isAll returns true if the action matches all actions
+func isAll(action string) bool {
+	return action == allAction || action == "all"
+}
+
+// impliedActions returns actions replacing ALL when some action is revoked
+func (p privilege) impliedActions() []string {
+	if p.component == "" {
+		return hiveActions
+	}
+	return componentActions[p.component]
+}
+
+// objectPath builds object path from type and name pairs skipping empty
+// names
+func objectPath(parts ...string) string {
+	path := make([]string, 0, len(parts)/2)
+	for i := 0; i+1 < len(parts); i += 2 {
+		if parts[i+1] != "" {
+			path = append(path, parts[i]+"="+parts[i+1])
+		}
+	}
+	return strings.Join(path, "->")
+}
+
+// pathParts returns type and name pairs of the object path in order
+func pathParts(object string) [][2]string {
+	var parts [][2]string
+	if object == "" {
+		return parts
+	}
+	for _, part := range strings.Split(object, "->") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			parts = append(parts, [2]string{kv[0], kv[1]})
+		}
+	}
+	return parts
+}
+
+// splitPath returns map from types to names for the object path
+func splitPath(object string) map[string]string {
+	result := make(map[string]string)
+	for _, part := range pathParts(object) {
+		result[part[0]] = part[1]
+	}
+	return result
+}
+
+// role is a Sentry role with its groups, users and privileges. Privileges
+// are mapped to their creation time.
+type role struct {
+	name       string
+	groups     map[string]bool
+	users      map[string]bool
+	privileges map[privilege]int64
+}
+
+// store keeps roles shared by both services. Like Sentry, it remembers all
+// groups and users ever mapped to roles.
+type store struct {
+	sync.Mutex
+	roles  map[string]*role
+	groups map[string]bool
+	users  map[string]bool
+}
+
+func newStore() *store {
+	return &store{
+		roles:  make(map[string]*role),
+		groups: make(map[string]bool),
+		users:  make(map[string]bool),
+	}
+}
+
+// roleName normalizes role name. Sentry role names are case-insensitive.
+func roleName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// getRole returns existing role. Must be called with store lock held.
+func (s *store) getRole(name string) (*role, error) {
+	r, ok := s.roles[roleName(name)]
+	if !ok {
+		return nil, newError(sentry_common_service.TSENTRY_STATUS_NO_SUCH_OBJECT,
+			"Role %s doesn't exist", roleName(name))
+	}
+	return r, nil
+}
+
+// createRole creates a new role. Must be called with store lock held.
+func (s *store) createRole(name string) error {
+	name = roleName(name)
+	if name == "" {
+		return newError(sentry_common_service.TSENTRY_STATUS_INVALID_INPUT,
+			"Role name is empty")
+	}
+	if _, ok := s.roles[name]; ok {
+		return newError(sentry_common_service.TSENTRY_STATUS_ALREADY_EXISTS,
+			"Role: %s already exists", name)
+	}
+	s.roles[name] = &role{
+		name:       name,
+		groups:     make(map[string]bool),
+		users:      make(map[string]bool),
+		privileges: make(map[privilege]int64),
+	}
+	return nil
+}
+
+// dropRole removes role. Must be called with store lock held.
+func (s *store) dropRole(name string) error {
+	r, err := s.getRole(name)
+	if err != nil {
+		return err
+	}
+	delete(s.roles, r.name)
+	return nil
+}
+
+// alterGroups adds or removes groups of the role. Must be called with store
+// lock held.
+func (s *store) alterGroups(name string, groups []string, add bool) error {
+	r, err := s.getRole(name)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if add {
+			r.groups[group] = true
+			s.groups[group] = true
+		} else {
+			delete(r.groups, group)
+		}
+	}
+	return nil
+}
+
+// alterUsers adds or removes users of the role. Must be called with store
+// lock held.
+func (s *store) alterUsers(name string, users []string, add bool) error {
+	r, err := s.getRole(name)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if add {
+			r.users[user] = true
+			s.users[user] = true
+		} else {
+			delete(r.users, user)
+		}
+	}
+	return nil
+}
+
+// sortedRoles returns all roles ordered by name. Must be called with store
+// lock held.
+func (s *store) sortedRoles() []*role {
+	roles := make([]*role, 0, len(s.roles))
+	for _, r := range s.roles {
+		roles = append(roles, r)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].name < roles[j].name })
+	return roles
+}
+
+// grantedTo returns true if the role is granted to any of the groups or users
+func (r *role) grantedTo(groups []string, users []string) bool {
+	for _, group := range groups {
+		if r.groups[group] {
+			return true
+		}
+	}
+	for _, user := range users {
+		if r.users[user] {
+			return true
+		}
+	}
+	return false
+}
+
+// rolesFor returns roles granted to any of the groups or users, restricted
+// to active roles unless all roles are active. Must be called with store
+// lock held.
+func (s *store) rolesFor(groups []string, users []string, all bool,
+	active map[string]bool) []*role {
+	var result []*role
+	for _, r := range s.sortedRoles() {
+		if (all || active[r.name]) && r.grantedTo(groups, users) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// filterRoles returns active roles
+func filterRoles(roles []*role, all bool, active map[string]bool) []*role {
+	var result []*role
+	for _, r := range roles {
+		if all || active[r.name] {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// grant adds privilege to the role. Granting ALL replaces other actions on
+// the same object, other actions are ignored if ALL is already granted.
+// Must be called with store lock held.
+func (s *store) grant(name string, p privilege) error {
+	r, err := s.getRole(name)
+	if err != nil {
+		return err
+	}
+	if p.service == "" || p.action == "" {
+		return newError(sentry_common_service.TSENTRY_STATUS_INVALID_INPUT,
+			"Privilege requires server or service name and action")
+	}
+	for q := range r.privileges {
+		if !q.sameObject(p) || q.grantOption != p.grantOption {
+			continue
+		}
+		if isAll(q.action) && !isAll(p.action) {
+			return nil
+		}
+		if isAll(p.action) && !isAll(q.action) {
+			delete(r.privileges, q)
+		}
+	}
+	if _, ok := r.privileges[p]; !ok {
+		r.privileges[p] = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	return nil
+}
+
+// revoke removes privilege from the role together with matching privileges
+// on child objects. Revoking a single action from ALL leaves the remaining
+// actions. If anyGrantOption is true, privileges are revoked regardless of
+// the grant option. Must be called with store lock held.
+func (s *store) revoke(name string, p privilege, anyGrantOption bool) error {
+	r, err := s.getRole(name)
+	if err != nil {
+		return err
+	}
+	for q, created := range r.privileges {
+		if !q.within(p) || (!anyGrantOption && q.grantOption != p.grantOption) {
+			continue
+		}
+		switch {
+		case isAll(p.action) || q.action == p.action:
+			delete(r.privileges, q)
+		case isAll(q.action) && q.sameObject(p):
+			delete(r.privileges, q)
+			for _, action := range q.impliedActions() {
+				if action != p.action {
+					remaining := q
+					remaining.action = action
+					r.privileges[remaining] = created
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dropPrivileges removes privileges matching the object from all roles.
+// Must be called with store lock held.
+func (s *store) dropPrivileges(p privilege) {
+	for _, r := range s.roles {
+		for q := range r.privileges {
+			if q.within(p) && (isAll(p.action) || p.action == "" ||
+				q.action == p.action) {
+				delete(r.privileges, q)
+			}
+		}
+	}
+}
+
+// renamePrivileges moves privileges on the old object and its children to
+// the new object. Must be called with store lock held.
+func (s *store) renamePrivileges(from privilege, to privilege) {
+	if from.object == "" || from.sameObject(to) {
+		return
+	}
+	for _, r := range s.roles {
+		for q, created := range r.privileges {
+			if !q.within(from) {
+				continue
+			}
+			delete(r.privileges, q)
+			q.service = to.service
+			q.object = to.object + strings.TrimPrefix(q.object, from.object)
+			r.privileges[q] = created
+		}
+	}
+}
+
+// reset removes all roles, groups and users
+func (s *store) reset() {
+	s.Lock()
+	defer s.Unlock()
+	s.roles = make(map[string]*role)
+	s.groups = make(map[string]bool)
+	s.users = make(map[string]bool)
+}
+
+// sortedKeys returns set elements in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
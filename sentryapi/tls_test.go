@@ -0,0 +1,246 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA is a certificate authority used to issue test certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	dir, err := ioutil.TempDir("", "sentrytls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template,
+		&key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &testCA{cert: cert, key: key, dir: dir}
+	ca.writePEM(t, "ca.pem", "CERTIFICATE", der)
+	return ca
+}
+
+// issue creates certificate for the name and returns paths to the
+// certificate and key files
+func (ca *testCA) issue(t *testing.T, name string,
+	usage x509.ExtKeyUsage) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert,
+		&key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca.writePEM(t, name+".pem", "CERTIFICATE", der),
+		ca.writePEM(t, name+".key", "EC PRIVATE KEY", keyDer)
+}
+
+func (ca *testCA) writePEM(t *testing.T, name string, kind string,
+	der []byte) string {
+	path := filepath.Join(ca.dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der})
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// startTLSServer starts TLS listener which accepts a single connection
+// and echoes everything back
+func startTLSServer(t *testing.T, ca *testCA,
+	requireClientCert bool) (int, chan error) {
+	certFile, keyFile := ca.issue(t, "sentry.example.com",
+		x509.ExtKeyUsageServerAuth)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if requireClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = x509.NewCertPool()
+		config.ClientCAs.AddCert(ca.cert)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		listener.Close()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+		_, err = io.Copy(conn, conn)
+		done <- err
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, done
+}
+
+// echo sends message over the transport and verifies the reply
+func echo(t *testing.T, config *tls.Config, port int) error {
+	options := newClientOptions([]ClientOption{WithTLS(config)})
+	transport, err := openTransport("127.0.0.1", port, options)
+	if err != nil {
+		return err
+	}
+	defer transport.Close()
+	message := []byte("hello sentry")
+	if _, err := transport.Write(message); err != nil {
+		return err
+	}
+	if err := transport.Flush(); err != nil {
+		return err
+	}
+	reply := make([]byte, len(message))
+	if _, err := io.ReadFull(transport, reply); err != nil {
+		return err
+	}
+	if string(reply) != string(message) {
+		t.Errorf("expected %q, got %q", message, reply)
+	}
+	return nil
+}
+
+func TestTLSTransport(t *testing.T) {
+	ca := newTestCA(t)
+	defer os.RemoveAll(ca.dir)
+	port, done := startTLSServer(t, ca, false)
+	config, err := NewTLSConfig(filepath.Join(ca.dir, "ca.pem"), "", "",
+		"sentry.example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := echo(t, config, port); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTLSTransport_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	defer os.RemoveAll(ca.dir)
+	port, done := startTLSServer(t, ca, true)
+	certFile, keyFile := ca.issue(t, "client", x509.ExtKeyUsageClientAuth)
+	config, err := NewTLSConfig(filepath.Join(ca.dir, "ca.pem"),
+		certFile, keyFile, "sentry.example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := echo(t, config, port); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTLSTransport_Verification(t *testing.T) {
+	ca := newTestCA(t)
+	defer os.RemoveAll(ca.dir)
+
+	// Server name doesn't match the certificate
+	port, done := startTLSServer(t, ca, false)
+	config, err := NewTLSConfig(filepath.Join(ca.dir, "ca.pem"), "", "",
+		"other.example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := echo(t, config, port); err == nil {
+		t.Error("expected server name verification failure")
+	}
+	<-done
+
+	// Verification is skipped for insecure config
+	port, done = startTLSServer(t, ca, false)
+	config, err = NewTLSConfig("", "", "", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := echo(t, config, port); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewTLSConfig_Errors(t *testing.T) {
+	if _, err := NewTLSConfig("/nonexistent/ca.pem", "", "", "", false); err == nil {
+		t.Error("expected error for missing CA file")
+	}
+	if _, err := NewTLSConfig("", "client.pem", "", "", false); err == nil {
+		t.Error("expected error for certificate without key")
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+)
+
+// openTransport opens Thrift transport to the Sentry server at host:port.
+// TLS socket is used when TLS config is specified in options. SASL transport
+// is used when SASL mechanism is specified in options, otherwise plain
+// buffered transport is used. Connect timeout applies to opening the
+// transport, the regular timeout applies to all reads and writes after that.
+// Calls are recorded with the recorder from options, and with the recording
+// from options no connection is made at all.
+func openTransport(host string, port int,
+	options *clientOptions) (thrift.TTransport, error) {
+	if options.recording != nil {
+		transport := newReplayTransport(options.recording)
+		transport.Open()
+		return &trackedTransport{TTransport: transport}, nil
+	}
+	address := fmt.Sprintf("%s:%d", host, port)
+	conn, raw, err := dial(address, host, options)
+	if err != nil {
+		return nil, newTransportError(err, "failed to connect to %s", address)
+	}
+	socket := thrift.NewTSocketFromConnTimeout(conn, options.connectTimeout)
+	var transport thrift.TTransport
+	if options.sasl != nil {
+		// SASL mechanism keeps negotiation state, so it can only be used
+		// by a single connection at a time
+		options.saslLock.Lock()
+		defer options.saslLock.Unlock()
+		transport = newTSaslClientTransport(socket, host, options.sasl)
+		if err := transport.Open(); err != nil {
+			return nil, newTransportError(err, "failed to connect to %s", address)
+		}
+	} else {
+		transport = thrift.NewTBufferedTransport(socket, 1024)
+	}
+	if err := socket.SetTimeout(options.timeout); err != nil {
+		transport.Close()
+		return nil, newTransportError(err, "failed to connect to %s", address)
+	}
+	if options.recorder != nil {
+		transport = newRecordingTransport(transport, options.recorder)
+	}
+	return &trackedTransport{TTransport: transport, conn: raw}, nil
+}
+
+// dial connects to the address, performing TLS handshake when TLS config is
+// specified in options. Connect timeout applies to both. It returns the
+// connection to use and the underlying TCP connection for cancellation.
+func dial(address string, host string,
+	options *clientOptions) (net.Conn, *cancelConn, error) {
+	tcpConn, err := net.DialTimeout("tcp", address, options.connectTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw := &cancelConn{Conn: tcpConn}
+	if options.tlsConfig == nil {
+		return raw, raw, nil
+	}
+	config := options.tlsConfig.Clone()
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	conn := tls.Client(raw, config)
+	if options.connectTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(options.connectTimeout))
+	}
+	if err := conn.Handshake(); err != nil {
+		tcpConn.Close()
+		return nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, raw, nil
+}
+
+// cancelConn is a connection which can be cancelled from another goroutine
+// without closing it. Cancellation sets a deadline in the past which socket
+// timeouts can't move, so the pending and all later reads and writes fail.
+// TLS connections set deadlines on the underlying connection, so they are
+// cancelled as well.
+type cancelConn struct {
+	net.Conn
+	lock      sync.Mutex
+	cancelled bool
+}
+
+// cancelledDeadline is a deadline in the past
+var cancelledDeadline = time.Unix(1, 0)
+
+// SetDeadline implements net.Conn.SetDeadline()
+func (c *cancelConn) SetDeadline(t time.Time) error {
+	return c.setDeadline(c.Conn.SetDeadline, t)
+}
+
+// SetReadDeadline implements net.Conn.SetReadDeadline()
+func (c *cancelConn) SetReadDeadline(t time.Time) error {
+	return c.setDeadline(c.Conn.SetReadDeadline, t)
+}
+
+// SetWriteDeadline implements net.Conn.SetWriteDeadline()
+func (c *cancelConn) SetWriteDeadline(t time.Time) error {
+	return c.setDeadline(c.Conn.SetWriteDeadline, t)
+}
+
+// setDeadline sets the deadline unless the connection is cancelled
+func (c *cancelConn) setDeadline(set func(time.Time) error, t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.cancelled {
+		t = cancelledDeadline
+	}
+	return set(t)
+}
+
+// cancel fails pending and future I/O on the connection. The connection
+// should still be closed.
+func (c *cancelConn) cancel() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cancelled = true
+	c.Conn.SetDeadline(cancelledDeadline)
+}
+
+// trackedTransport remembers I/O failures, so that broken connections can be
+// told apart from errors reported by the server
+type trackedTransport struct {
+	thrift.TTransport
+	conn   *cancelConn
+	failed bool
+}
+
+// cancel aborts the pending call, leaving the transport broken. Replayed
+// transports have no connection and nothing to cancel.
+func (t *trackedTransport) cancel() {
+	if t.conn != nil {
+		t.conn.cancel()
+	}
+}
+
+// Read implements io.Reader
+func (t *trackedTransport) Read(p []byte) (int, error) {
+	n, err := t.TTransport.Read(p)
+	if err != nil {
+		t.failed = true
+	}
+	return n, err
+}
+
+// Write implements io.Writer
+func (t *trackedTransport) Write(p []byte) (int, error) {
+	n, err := t.TTransport.Write(p)
+	if err != nil {
+		t.failed = true
+	}
+	return n, err
+}
+
+// Flush implements thrift.TTransport.Flush()
+func (t *trackedTransport) Flush() error {
+	err := t.TTransport.Flush()
+	if err != nil {
+		t.failed = true
+	}
+	return err
+}
+
+// cancelTransport aborts the pending call on the transport
+func cancelTransport(transport thrift.TTransport) {
+	if t, ok := transport.(*trackedTransport); ok {
+		t.cancel()
+	}
+}
+
+// transportBroken returns true if I/O on the transport failed
+func transportBroken(transport thrift.TTransport) bool {
+	t, ok := transport.(*trackedTransport)
+	return ok && t.failed
+}
@@ -0,0 +1,253 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_common_service"
+)
+
+// Sentry Thrift protocol versions
+const (
+	ProtocolVersion1 int32 = sentry_common_service.TSENTRY_SERVICE_V1
+	ProtocolVersion2 int32 = sentry_common_service.TSENTRY_SERVICE_V2
+)
+
+// probeName is a group or object name used to check which calls the server
+// supports. It is not expected to exist.
+const probeName = "sentrytool-probe"
+
+// versionedClient is a client which supports protocol version negotiation
+type versionedClient interface {
+	ClientAPI
+	setProtocolVersion(version int32)
+}
+
+// negotiatedVersions caches protocol versions negotiated with Sentry
+// services, keyed by versionKey(), so the version is only negotiated on the
+// first connection to the service
+var negotiatedVersions sync.Map
+
+// versionKey returns negotiatedVersions key for the service at the endpoint
+func versionKey(protocol ProtocolType, host string, port int) string {
+	return fmt.Sprintf("%s@%s:%d", protocol, host, port)
+}
+
+// negotiateVersion sets the client protocol version. Unless the version is
+// set by options, the newest version accepted by the server is used. The
+// negotiated version is cached with the given key.
+func negotiateVersion(client versionedClient, options *clientOptions,
+	key string) error {
+	if options.version != 0 {
+		client.setProtocolVersion(options.version)
+		return nil
+	}
+	if version, ok := negotiatedVersions.Load(key); ok && options.cacheable() {
+		client.setProtocolVersion(version.(int32))
+		return nil
+	}
+	var version int32
+	var err error
+	for _, version = range []int32{ProtocolVersion2, ProtocolVersion1} {
+		client.setProtocolVersion(version)
+		if _, _, err = client.ListRoleByGroup(probeName); !errors.Is(err,
+			ErrThriftVersionMismatch) {
+			break
+		}
+	}
+	// Any other server error means that the version is accepted
+	if IsTransportError(err) || errors.Is(err, ErrThriftVersionMismatch) {
+		return err
+	}
+	negotiatedVersions.Store(key, version)
+	return nil
+}
+
+// cacheable returns true if negotiated versions and protocols may be taken
+// from the cache. Recorded and replayed sessions always negotiate, so the
+// recording doesn't depend on earlier connections.
+func (o *clientOptions) cacheable() bool {
+	return o.recorder == nil && o.recording == nil
+}
+
+// autoProtocols caches protocols chosen by getAutoClient() for the endpoint
+// and the model, so the services are only probed on the first connection
+var autoProtocols sync.Map
+
+// getAutoClient returns client for the Sentry service which answers at the
+// endpoint. Both services are probed: the legacy one serves requests without
+// component and the generic one serves requests with component. If the
+// service required for the request doesn't answer, the error lists available
+// services. The chosen protocol is cached for the endpoint.
+func getAutoClient(host string, port int, component string, user string,
+	opts []ClientOption) (ClientAPI, error) {
+	key := fmt.Sprintf("%s:%d/%t", host, port, component != "")
+	if protocol, ok := autoProtocols.Load(key); ok &&
+		newClientOptions(opts).cacheable() {
+		return GetClient(protocol.(ProtocolType), host, port, component, user,
+			opts...)
+	}
+	probeComponent := component
+	if probeComponent == "" {
+		probeComponent = probeName
+	}
+	clients := make(map[ProtocolType]ClientAPI)
+	errs := make(map[ProtocolType]error)
+	for _, protocol := range []ProtocolType{PolicyProtocol,
+		GenericPolicyProtocol} {
+		client, err := GetClient(protocol, host, port, probeComponent, user,
+			opts...)
+		if err != nil && !IsTransportError(err) &&
+			!errors.Is(err, ErrThriftVersionMismatch) {
+			for _, c := range clients {
+				c.Close()
+			}
+			return nil, err
+		}
+		if err != nil {
+			errs[protocol] = err
+			continue
+		}
+		clients[protocol] = client
+	}
+
+	protocol, other := PolicyProtocol, GenericPolicyProtocol
+	if component != "" {
+		protocol, other = GenericPolicyProtocol, PolicyProtocol
+	}
+	if otherClient, ok := clients[other]; ok {
+		otherClient.Close()
+	}
+	client, ok := clients[protocol]
+	if !ok {
+		if _, ok := errs[other]; ok {
+			return nil, errs[protocol]
+		}
+		return nil, fmt.Errorf("Sentry server at %s:%d doesn't provide %s, only %s is available: %s",
+			host, port, protocol, other, errs[protocol])
+	}
+	autoProtocols.Store(key, protocol)
+	return client, nil
+}
+
+// ServiceCapabilities describes what the Sentry service supports.
+// Attributes:
+//   Service - multiplexed service name, e.g. SentryPolicyService
+//   Available - true if the service answers
+//   ProtocolVersion - negotiated protocol version
+//   Operations - ClientAPI operations mapped to true if supported
+//   Error - why the service is not available
+type ServiceCapabilities struct {
+	Service         string          `json:"service"`
+	Available       bool            `json:"available"`
+	ProtocolVersion int32           `json:"protocolVersion,omitempty"`
+	Operations      map[string]bool `json:"operations,omitempty"`
+	Error           string          `json:"error,omitempty"`
+}
+
+// Capabilities describes both Sentry services of the server
+type Capabilities struct {
+	Policy  ServiceCapabilities `json:"policy"`
+	Generic ServiceCapabilities `json:"generic"`
+}
+
+// coreOperations are supported by every version of both services
+var coreOperations = []string{"CreateRole", "RemoveRole", "ListRoleByGroup",
+	"AddGroupsToRole", "RemoveGroupsFromRole", "GrantPrivilege",
+	"RevokePrivilege", "ListPrivilegesByRole", "DropPrivilegesOnObject",
+	"RenamePrivilegesOnObject", "EffectivePrivileges"}
+
+// GetCapabilities checks which services and calls the Sentry server
+// supports. Arguments are the same as for GetClient(). Each service is
+// checked using a separate connection. Calls which modify the policy are
+// never issued: their support is derived from related read calls.
+func GetCapabilities(host string, port int, component string, user string,
+	opts ...ClientOption) (*Capabilities, error) {
+	if component == "" {
+		component = probeName
+	}
+	caps := &Capabilities{}
+	for _, service := range []struct {
+		protocol ProtocolType
+		caps     *ServiceCapabilities
+	}{
+		{PolicyProtocol, &caps.Policy},
+		{GenericPolicyProtocol, &caps.Generic},
+	} {
+		service.caps.Service = service.protocol.String()
+		client, err := GetClient(service.protocol, host, port, component,
+			user, opts...)
+		if err != nil {
+			if !IsTransportError(err) &&
+				!errors.Is(err, ErrThriftVersionMismatch) {
+				return nil, err
+			}
+			service.caps.Error = err.Error()
+			continue
+		}
+		service.caps.Available = true
+		service.caps.ProtocolVersion = client.(interface {
+			version() int32
+		}).version()
+		service.caps.Operations = probeOperations(client, service.protocol)
+		client.Close()
+	}
+	return caps, nil
+}
+
+// probeOperations returns operations supported by the client
+func probeOperations(client ClientAPI,
+	protocol ProtocolType) map[string]bool {
+	ops := make(map[string]bool)
+	for _, op := range coreOperations {
+		ops[op] = true
+	}
+	if protocol == GenericPolicyProtocol {
+		for _, op := range []string{"ListPrivilegesByObject"} {
+			ops[op] = true
+		}
+		for _, op := range []string{"ListRoleByUser", "AddUsersToRole",
+			"RemoveUsersFromRole", "GetConfigValue", "ExportPolicy",
+			"ImportPolicy"} {
+			ops[op] = false
+		}
+		return ops
+	}
+
+	_, _, err := client.ListRoleByUser(probeName)
+	ops["ListRoleByUser"] = !isUnknownMethod(err)
+	ops["AddUsersToRole"] = ops["ListRoleByUser"]
+	ops["RemoveUsersFromRole"] = ops["ListRoleByUser"]
+	_, err = client.GetConfigValue(probeName, "")
+	ops["GetConfigValue"] = !isUnknownMethod(err)
+	if policyIO, ok := client.(PolicyIO); ok {
+		_, err = policyIO.ExportPolicy("db=" + probeName)
+		ops["ExportPolicy"] = !isUnknownMethod(err)
+	}
+	ops["ImportPolicy"] = ops["ExportPolicy"]
+	_, err = client.ListPrivilegesByObject(&Privilege{Server: probeName}, nil)
+	ops["ListPrivilegesByObject"] = !isUnknownMethod(err)
+	return ops
+}
+
+// isUnknownMethod returns true if the server doesn't know the called method
+func isUnknownMethod(err error) bool {
+	var appErr thrift.TApplicationException
+	return errors.As(err, &appErr) && appErr.TypeId() == thrift.UNKNOWN_METHOD
+}
@@ -0,0 +1,181 @@
+// Copyright © 2016 Alex Kolbasov
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sentryapi
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"git.apache.org/thrift.git/lib/go/thrift"
+	"github.com/akolb1/sentrytool/sentryapi/sentrytest"
+	"github.com/akolb1/sentrytool/sentryapi/thrift/sentry_common_service"
+)
+
+// versionFakeClient accepts only the listed protocol versions
+type versionFakeClient struct {
+	ClientAPI
+	accepted []int32
+	version  int32
+	err      error
+}
+
+func (c *versionFakeClient) setProtocolVersion(version int32) {
+	c.version = version
+}
+
+func (c *versionFakeClient) ListRoleByGroup(groupName string) ([]string, []*Role, error) {
+	if c.err != nil {
+		return nil, nil, c.err
+	}
+	for _, v := range c.accepted {
+		if v == c.version {
+			return nil, nil, nil
+		}
+	}
+	return nil, nil, newAPIError(
+		sentry_common_service.TSENTRY_STATUS_THRIFT_VERSION_MISMATCH,
+		"version mismatch", nil)
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		accepted []int32
+		version  int32
+		expected int32
+		mismatch bool
+	}{
+		{"v2", []int32{ProtocolVersion1, ProtocolVersion2}, 0, ProtocolVersion2, false},
+		{"fallback", []int32{ProtocolVersion1}, 0, ProtocolVersion1, false},
+		{"forced", []int32{ProtocolVersion2}, ProtocolVersion1, ProtocolVersion1, false},
+		{"unsupported", []int32{}, 0, ProtocolVersion1, true},
+	}
+	for _, tt := range tests {
+		client := &versionFakeClient{accepted: tt.accepted}
+		err := negotiateVersion(client,
+			newClientOptions([]ClientOption{WithProtocolVersion(tt.version)}),
+			"negotiate/"+tt.name)
+		if errors.Is(err, ErrThriftVersionMismatch) != tt.mismatch {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if client.version != tt.expected {
+			t.Errorf("%s: expected version %d, got %d",
+				tt.name, tt.expected, client.version)
+		}
+	}
+}
+
+func TestNegotiateVersion_Errors(t *testing.T) {
+	client := &versionFakeClient{err: newAPIError(
+		sentry_common_service.TSENTRY_STATUS_ACCESS_DENIED, "denied", nil)}
+	if err := negotiateVersion(client, newClientOptions(nil),
+		"errors/denied"); err != nil {
+		t.Errorf("server error should accept the version, got %v", err)
+	}
+	client = &versionFakeClient{err: newTransportError(io.EOF, "failed")}
+	if err := negotiateVersion(client, newClientOptions(nil),
+		"errors/transport"); !IsTransportError(err) {
+		t.Errorf("expected transport error, got %v", err)
+	}
+}
+
+func TestNegotiateVersion_Cache(t *testing.T) {
+	client := &versionFakeClient{accepted: []int32{ProtocolVersion1}}
+	if err := negotiateVersion(client, newClientOptions(nil), "cache"); err != nil {
+		t.Fatal(err)
+	}
+	// The cached version is used without asking the server
+	client = &versionFakeClient{err: newTransportError(io.EOF, "failed")}
+	if err := negotiateVersion(client, newClientOptions(nil), "cache"); err != nil {
+		t.Fatal(err)
+	}
+	if client.version != ProtocolVersion1 {
+		t.Errorf("expected cached version %d, got %d", ProtocolVersion1,
+			client.version)
+	}
+	// Failed negotiation isn't cached
+	if err := negotiateVersion(client, newClientOptions(nil),
+		"cache/failed"); err == nil {
+		t.Fatal("expected error")
+	}
+	client.err = nil
+	client.accepted = []int32{ProtocolVersion2}
+	if err := negotiateVersion(client, newClientOptions(nil),
+		"cache/failed"); err != nil || client.version != ProtocolVersion2 {
+		t.Errorf("expected version %d, got %d, %v", ProtocolVersion2,
+			client.version, err)
+	}
+}
+
+func TestGetAutoClient(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		without   string
+		component string
+		expected  ProtocolType
+		fails     bool
+	}{
+		{"legacy", "", "", PolicyProtocol, false},
+		{"generic", "", "solr", GenericPolicyProtocol, false},
+		{"legacy only", sentrytest.GenericPolicyService, "", PolicyProtocol, false},
+		{"generic only", sentrytest.PolicyService, "solr", GenericPolicyProtocol, false},
+		{"missing generic", sentrytest.GenericPolicyService, "solr", 0, true},
+		{"missing legacy", sentrytest.PolicyService, "", 0, true},
+	} {
+		var opts []sentrytest.Option
+		if tt.without != "" {
+			opts = append(opts, sentrytest.WithoutService(tt.without))
+		}
+		server, err := sentrytest.NewServer(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			client, err := GetClient(AutoProtocol, server.Host, server.Port,
+				tt.component, "admin")
+			if tt.fails {
+				if err == nil || IsTransportError(err) {
+					t.Errorf("%s: expected error listing services, got %v",
+						tt.name, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			// Only the generic client doesn't support config values
+			_, err = client.GetConfigValue(probeName, "")
+			if errors.Is(err, ErrNotSupported) != (tt.expected == GenericPolicyProtocol) {
+				t.Errorf("%s: expected %s client", tt.name, tt.expected)
+			}
+			client.Close()
+		}
+		server.Close()
+	}
+}
+
+func TestIsUnknownMethod(t *testing.T) {
+	err := newTransportError(thrift.NewTApplicationException(
+		thrift.UNKNOWN_METHOD, "Unknown function list_sentry_roles_by_user"),
+		"failed to list roles")
+	if !isUnknownMethod(err) {
+		t.Error("expected unknown method")
+	}
+	if isUnknownMethod(newTransportError(io.EOF, "failed")) {
+		t.Error("unexpected unknown method")
+	}
+}